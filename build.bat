@@ -39,6 +39,16 @@ go build -o bin\worker.exe .\cmd\worker
 if errorlevel 1 goto BUILD_FAILED
 echo   [OK] worker.exe
 
+echo   Building reviewctl...
+go build -o bin\reviewctl.exe .\cmd\reviewctl
+if errorlevel 1 goto BUILD_FAILED
+echo   [OK] reviewctl.exe
+
+echo   Building review-local...
+go build -o bin\review-local.exe .\cmd\review-local
+if errorlevel 1 goto BUILD_FAILED
+echo   [OK] review-local.exe
+
 echo.
 echo [OK] Build completed successfully!
 echo.
@@ -39,6 +39,16 @@ function Build-Project {
         go build -o bin/worker.exe ./cmd/worker
         Write-Success "  ✓ worker.exe"
 
+        # Build reviewctl
+        Write-Info "  Building reviewctl..."
+        go build -o bin/reviewctl.exe ./cmd/reviewctl
+        Write-Success "  ✓ reviewctl.exe"
+
+        # Build review-local
+        Write-Info "  Building review-local..."
+        go build -o bin/review-local.exe ./cmd/review-local
+        Write-Success "  ✓ review-local.exe"
+
         Write-Success "`n✓ Build completed successfully!`n"
         return $true
     }
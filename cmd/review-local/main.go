@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/carlr/codereviewtool/internal/analyzer"
+	"github.com/carlr/codereviewtool/internal/ci"
+	"github.com/carlr/codereviewtool/internal/config"
+	"github.com/carlr/codereviewtool/internal/lint"
+	"github.com/carlr/codereviewtool/internal/scm"
+	"github.com/carlr/codereviewtool/pkg/llm"
+	"github.com/joho/godotenv"
+)
+
+// Exit codes, so CI pipelines can tell findings apart from failures to review
+const (
+	exitFindings = 1 // Findings at or above the -fail-on severity
+	exitError    = 2 // The review couldn't run or its response couldn't be parsed
+)
+
+func main() {
+	repoDir := flag.String("repo", ".", "repository the diff applies to")
+	base := flag.String("base", "", "commit to compare against (default HEAD)")
+	head := flag.String("head", "", "commit to review; empty reviews the working tree against -base")
+	staged := flag.Bool("staged", false, "review staged changes, e.g. from a pre-commit hook")
+	prNumber := flag.Int("pr", 0, "review a GitHub pull request instead of a local diff (needs GITHUB_TOKEN)")
+	repoName := flag.String("repo-name", "", "owner/repo of -pr (default from the CI environment)")
+	title := flag.String("title", "", "description of the change for the model")
+	format := flag.String("format", "text", "output format: text, json, markdown or sarif")
+	failOn := flag.String("fail-on", "error", "exit with status 1 if findings are at least this severe: info, warning, error or none")
+	verbose := flag.Bool("v", false, "log analysis progress to stderr")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n  git diff | review-local [flags]\n  review-local [-base ref] [-head ref] [-staged] [flags]\n  review-local -pr number [-repo-name owner/repo] [flags]\n\n"+
+			"In CI, pull requests are detected from GitHub Actions, CircleCI, Travis CI,\nBuildkite and Jenkins environment variables.\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+	switch *format {
+	case "text", "json", "markdown", "sarif":
+	default:
+		fatalf("invalid -format %q (must be text, json, markdown or sarif)", *format)
+	}
+	switch *failOn {
+	case "info", "warning", "error", "none":
+	default:
+		fatalf("invalid -fail-on %q (must be info, warning, error or none)", *failOn)
+	}
+
+	// Load .env file
+	godotenv.Load()
+
+	// A diff piped in takes precedence over the refs and the CI environment. CI
+	// runners often attach stdin to an empty pipe, so only a non-empty one counts
+	localFlags := *base != "" || *head != "" || *staged
+	piped := ""
+	if !localFlags && *prNumber == 0 && stdinPiped() {
+		diff, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fatalf("Failed to read diff: %v", err)
+		}
+		piped = string(diff)
+	}
+
+	ctx := context.Background()
+	var review *llm.CodeReviewResponse
+	if pr, ok := pullRequest(*prNumber, *repoName, localFlags || strings.TrimSpace(piped) != ""); ok {
+		review = reviewPullRequest(ctx, pr)
+	} else {
+		change, err := localChange(ctx, *repoDir, *base, *head, *staged, piped)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		if strings.TrimSpace(change.Diff) == "" {
+			fmt.Fprintln(os.Stderr, "No changes to review")
+			return
+		}
+		change.Title = *title
+		review = reviewLocal(ctx, change)
+	}
+
+	if err := writeReview(os.Stdout, *format, review); err != nil {
+		fatalf("Failed to write review: %v", err)
+	}
+	// A response that couldn't be parsed says nothing about the change, so it must
+	// not pass the pipeline as a clean review
+	if review.Unparsed {
+		fmt.Fprintln(os.Stderr, "The review response could not be parsed")
+		os.Exit(exitError)
+	}
+	if failed(review, *failOn) {
+		os.Exit(exitFindings)
+	}
+}
+
+// pullRequest returns the pull request to review: the one given by -pr, or the
+// one a CI build runs for unless a local diff was asked for
+func pullRequest(number int, repoName string, local bool) (ci.PullRequest, bool) {
+	detected, inCI := ci.Detect(os.Getenv)
+	if number == 0 {
+		if local {
+			return ci.PullRequest{}, false
+		}
+		return detected, inCI
+	}
+
+	pr := ci.PullRequest{Owner: detected.Owner, Repo: detected.Repo, Number: number}
+	if repoName == "" {
+		repoName = os.Getenv("GITHUB_REPOSITORY")
+	}
+	if repoName != "" {
+		pr.Owner, pr.Repo, _ = strings.Cut(repoName, "/")
+	}
+	if pr.Owner == "" || pr.Repo == "" {
+		fatalf("-pr needs -repo-name owner/repo outside of CI")
+	}
+	return pr, true
+}
+
+// reviewPullRequest reviews a GitHub pull request without posting anything
+func reviewPullRequest(ctx context.Context, pr ci.PullRequest) *llm.CodeReviewResponse {
+	cfg, err := config.LoadFor(config.RoleCI)
+	if err != nil {
+		fatalf("Failed to load configuration: %v", err)
+	}
+
+	githubClient, err := scm.NewGitHubClient(cfg.GitHubToken, scm.ClientOptions{
+		MaxRateLimitWait: time.Duration(cfg.GitHubRateLimitWait) * time.Second,
+		BaseURL:          cfg.GitHubBaseURL,
+		UploadURL:        cfg.GitHubUploadURL,
+		CAFile:           cfg.GitHubCABundle,
+	})
+	if err != nil {
+		fatalf("Failed to create GitHub client: %v", err)
+	}
+	details, err := githubClient.GetPullRequest(ctx, pr.Owner, pr.Repo, pr.Number)
+	if err != nil {
+		fatalf("Failed to get %s/%s#%d: %v", pr.Owner, pr.Repo, pr.Number, err)
+	}
+
+	codeAnalyzer := newAnalyzer(cfg, githubClient)
+	review, err := codeAnalyzer.AnalyzePullRequest(ctx, analyzer.PullRequest{
+		Owner:       pr.Owner,
+		Repo:        pr.Repo,
+		Number:      pr.Number,
+		Title:       details.GetTitle(),
+		Description: details.GetBody(),
+		Author:      details.GetUser().GetLogin(),
+		HeadSHA:     details.GetHead().GetSHA(),
+		BaseSHA:     details.GetBase().GetSHA(),
+	})
+	if err != nil {
+		fatalf("Review failed: %v", err)
+	}
+	return review
+}
+
+// reviewLocal reviews a local diff; only the LLM provider needs to be configured
+func reviewLocal(ctx context.Context, change analyzer.LocalChange) *llm.CodeReviewResponse {
+	cfg, err := config.LoadFor(config.RoleLocal)
+	if err != nil {
+		fatalf("Failed to load configuration: %v", err)
+	}
+
+	review, err := newAnalyzer(cfg, nil).AnalyzeLocal(ctx, change)
+	if err != nil {
+		fatalf("Review failed: %v", err)
+	}
+	return review
+}
+
+// localChange uses the diff piped in, if any, or otherwise runs git diff for the
+// staged changes or between the given refs
+func localChange(ctx context.Context, repoDir, base, head string, staged bool, piped string) (analyzer.LocalChange, error) {
+	change := analyzer.LocalChange{Dir: repoDir, Staged: staged}
+	if strings.TrimSpace(piped) != "" {
+		change.Diff = piped
+		return change, nil
+	}
+
+	if base == "" {
+		base = "HEAD"
+	}
+	change.Base = base
+	args := []string{"diff", "--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/"}
+	switch {
+	case staged:
+		args = append(args, "--cached", base)
+	case head != "":
+		// Like a pull request, review what head adds since it diverged from base
+		mergeBase, err := git(ctx, repoDir, "merge-base", base, head)
+		if err != nil {
+			return change, err
+		}
+		change.Base = strings.TrimSpace(mergeBase)
+		change.Head = head
+		args = append(args, change.Base, head)
+	default:
+		args = append(args, base)
+	}
+
+	diff, err := git(ctx, repoDir, args...)
+	if err != nil {
+		return change, err
+	}
+	change.Diff = diff
+	return change, nil
+}
+
+// newAnalyzer creates an analyzer for the configured LLM provider; githubClient
+// may be nil for local reviews
+func newAnalyzer(cfg *config.Config, githubClient *scm.GitHubClient) *analyzer.Analyzer {
+	llmConfig := map[string]string{}
+	switch cfg.LLMProvider {
+	case "openai":
+		llmConfig["api_key"] = cfg.OpenAIAPIKey
+		llmConfig["model"] = cfg.OpenAIModel
+	case "anthropic":
+		llmConfig["api_key"] = cfg.AnthropicAPIKey
+		llmConfig["model"] = cfg.AnthropicModel
+	case "ollama":
+		llmConfig["url"] = cfg.OllamaURL
+		llmConfig["model"] = cfg.OllamaModel
+	}
+
+	llmProvider, err := llm.NewFactory().CreateProvider(cfg.LLMProvider, llmConfig)
+	if err != nil {
+		fatalf("Failed to create LLM provider: %v", err)
+	}
+
+	options := analyzer.Options{
+		ContextTokenBudget:    cfg.ContextTokenBudget,
+		GoAPIAnalysis:         cfg.GoAPIAnalysis,
+		StaticAnalysisTimeout: time.Duration(cfg.StaticAnalysisTimeout) * time.Second,
+	}
+	if cfg.StaticAnalysisEnabled {
+		options.Linters, err = lint.Lookup(cfg.StaticAnalysisLinters)
+		if err != nil {
+			fatalf("Invalid STATIC_ANALYSIS_LINTERS: %v", err)
+		}
+	}
+	return analyzer.NewAnalyzer(llmProvider, githubClient, options)
+}
+
+// failed reports whether the review has findings at least as severe as failOn
+func failed(review *llm.CodeReviewResponse, failOn string) bool {
+	highest := review.HighestSeverity()
+	if failOn == "none" || highest == "" {
+		return false
+	}
+	return llm.SeverityRank(highest) >= llm.SeverityRank(failOn)
+}
+
+// stdinPiped reports whether stdin is a pipe or file rather than a terminal
+func stdinPiped() bool {
+	stat, err := os.Stdin.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice == 0
+}
+
+// git runs a git command in dir and returns its output
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %w", args[0], err)
+	}
+	return string(out), nil
+}
+
+// fatalf reports an error on stderr, which is shown even when logging is off,
+// and exits with the status for failed reviews
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(exitError)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/carlr/codereviewtool/internal/sarif"
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+// sarifToolName is the tool name in SARIF output, matching the worker's uploads
+const sarifToolName = "AI Code Review"
+
+// jsonReview is the JSON output, including the sections the analyzer adds
+type jsonReview struct {
+	Summary      string              `json:"summary"`
+	Comments     []llm.ReviewComment `json:"comments"`
+	APIChanges   []llm.APIChange     `json:"api_changes,omitempty"`
+	SkippedFiles []llm.SkippedFile   `json:"skipped_files,omitempty"`
+}
+
+// writeReview prints the review in the given format
+func writeReview(w io.Writer, format string, review *llm.CodeReviewResponse) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(jsonReview{
+			Summary:      review.Summary,
+			Comments:     review.Comments,
+			APIChanges:   review.APIChanges,
+			SkippedFiles: review.SkippedFiles,
+		})
+	case "markdown":
+		_, err := io.WriteString(w, formatMarkdown(review))
+		return err
+	case "sarif":
+		data, err := sarif.Marshal(sarif.FromReview(review, sarifToolName, ""))
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+	default:
+		_, err := io.WriteString(w, formatText(review))
+		return err
+	}
+}
+
+// formatText renders the review for a terminal, with locations in the
+// file:line form editors can jump to
+func formatText(review *llm.CodeReviewResponse) string {
+	var b strings.Builder
+	b.WriteString(review.Summary + "\n")
+
+	for _, comment := range review.Comments {
+		b.WriteString("\n")
+		if location := commentLocation(comment); location != "" {
+			b.WriteString(location + ": ")
+		}
+		fmt.Fprintf(&b, "%s: %s\n", comment.Severity, comment.Body)
+		if comment.Suggestion != nil {
+			b.WriteString("  Suggested change:\n")
+			for _, line := range strings.Split(strings.TrimRight(comment.Suggestion.Replacement, "\n"), "\n") {
+				b.WriteString("    " + line + "\n")
+			}
+		}
+	}
+
+	if len(review.APIChanges) > 0 {
+		b.WriteString("\nAPI changes:\n")
+		for _, change := range review.APIChanges {
+			breaking := ""
+			if change.Breaking {
+				breaking = " (breaking)"
+			}
+			fmt.Fprintf(&b, "  %s %s %s in %s%s\n", change.Change, change.Kind, change.Symbol, change.Filename, breaking)
+		}
+	}
+	if len(review.SkippedFiles) > 0 {
+		b.WriteString("\nNot reviewed:\n")
+		for _, file := range review.SkippedFiles {
+			fmt.Fprintf(&b, "  %s (%s)\n", file.Filename, file.Reason)
+		}
+	}
+	return b.String()
+}
+
+// formatMarkdown renders the review as a markdown document, e.g. for a PR description
+func formatMarkdown(review *llm.CodeReviewResponse) string {
+	var b strings.Builder
+	b.WriteString("## AI Code Review\n\n" + review.Summary + "\n")
+
+	if len(review.Comments) > 0 {
+		b.WriteString("\n### Comments\n\n")
+		for _, comment := range review.Comments {
+			location := ""
+			if l := commentLocation(comment); l != "" {
+				location = "**" + l + "** - "
+			}
+			fmt.Fprintf(&b, "- [%s] %s%s\n", strings.ToUpper(comment.Severity), location, comment.Body)
+			if comment.Suggestion != nil {
+				b.WriteString("\n  ```\n")
+				for _, line := range strings.Split(strings.TrimRight(comment.Suggestion.Replacement, "\n"), "\n") {
+					b.WriteString("  " + line + "\n")
+				}
+				b.WriteString("  ```\n")
+			}
+		}
+	}
+
+	if len(review.APIChanges) > 0 {
+		b.WriteString("\n### API changes\n\n")
+		for _, change := range review.APIChanges {
+			breaking := ""
+			if change.Breaking {
+				breaking = "**BREAKING** "
+			}
+			fmt.Fprintf(&b, "- %s%s %s `%s` in `%s`\n", breaking, change.Change, change.Kind, change.Symbol, change.Filename)
+		}
+	}
+	if len(review.SkippedFiles) > 0 {
+		b.WriteString("\n### Files skipped\n\n")
+		for _, file := range review.SkippedFiles {
+			fmt.Fprintf(&b, "- `%s`: %s\n", file.Filename, file.Reason)
+		}
+	}
+	return b.String()
+}
+
+// commentLocation returns file, file:line or file:start-end for a comment, or ""
+// for general comments
+func commentLocation(comment llm.ReviewComment) string {
+	switch {
+	case comment.Filename == "":
+		return ""
+	case comment.Line <= 0:
+		return comment.Filename
+	case comment.StartLine > 0 && comment.StartLine < comment.Line:
+		return fmt.Sprintf("%s:%d-%d", comment.Filename, comment.StartLine, comment.Line)
+	default:
+		return fmt.Sprintf("%s:%d", comment.Filename, comment.Line)
+	}
+}
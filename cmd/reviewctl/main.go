@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/carlr/codereviewtool/internal/config"
+	"github.com/carlr/codereviewtool/internal/dedup"
+	"github.com/carlr/codereviewtool/internal/queue"
+	"github.com/carlr/codereviewtool/internal/scm"
+	"github.com/carlr/codereviewtool/internal/webhook"
+	"github.com/google/go-github/v57/github"
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+)
+
+const usage = `Usage: reviewctl <command> [arguments]
+
+Commands:
+  enqueue owner/repo#number...   Review pull requests now, including drafts and
+                                 commits that were already reviewed
+  backfill [-dry-run] target...  Review every open pull request of owner/repo
+                                 targets, or of every repository of owner targets
+  replay file...                 Publish stored pull_request webhook payloads
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// Load .env file
+	if err := godotenv.Load(); err != nil {
+		log.Println("Warning: .env file not found, using environment variables")
+	}
+
+	// Load configuration
+	cfg, err := config.LoadFor(config.RoleControl)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	ctl, err := newController(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer ctl.queue.Close()
+
+	ctx := context.Background()
+	command, args := os.Args[1], os.Args[2:]
+	switch command {
+	case "enqueue":
+		err = ctl.enqueue(ctx, args)
+	case "backfill":
+		err = ctl.backfill(ctx, args)
+	case "replay":
+		err = ctl.replay(args)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// controller publishes review jobs through the same queue as the webhook listener
+type controller struct {
+	github     *scm.GitHubClient
+	queue      queue.Queue
+	store      dedup.Store // Optional; records heads like the webhook listener does
+	priorities webhook.PriorityRules
+}
+
+// newController connects to GitHub, the queue and the deduplication store
+func newController(cfg *config.Config) (*controller, error) {
+	githubClient, err := scm.NewGitHubClient(cfg.GitHubToken, scm.ClientOptions{
+		MaxRateLimitWait: time.Duration(cfg.GitHubRateLimitWait) * time.Second,
+		BaseURL:          cfg.GitHubBaseURL,
+		UploadURL:        cfg.GitHubUploadURL,
+		CAFile:           cfg.GitHubCABundle,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GitHub client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	store, err := dedup.Open(ctx, cfg.DedupStore, cfg.PostgresURL, cfg.SQLitePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create deduplication store: %w", err)
+	}
+
+	// An in-memory queue would be lost when reviewctl exits
+	if cfg.QueueBackend == "memory" {
+		return nil, fmt.Errorf("reviewctl needs a shared queue; QUEUE_BACKEND=memory only works within one process")
+	}
+	jobs, err := queue.Open(queue.Options{
+		Backend:     cfg.QueueBackend,
+		RabbitMQURL: cfg.RabbitMQURL,
+		RedisURL:    cfg.RedisURL,
+		NATSURL:     cfg.NATSURL,
+		SQLitePath:  cfg.SQLitePath,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to queue: %w", err)
+	}
+
+	priorities := webhook.PriorityRules{
+		Branches:     cfg.PriorityBranches,
+		Labels:       cfg.PriorityLabels,
+		SmallDiff:    cfg.PrioritySmallDiff,
+		TriggerLabel: cfg.ReviewTriggerLabel,
+	}
+	return &controller{github: githubClient, queue: jobs, store: store, priorities: priorities}, nil
+}
+
+// enqueue publishes on-demand reviews of the given pull requests
+func (c *controller) enqueue(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("enqueue needs at least one owner/repo#number")
+	}
+
+	for _, arg := range args {
+		owner, repo, number, err := parsePullRequest(arg)
+		if err != nil {
+			return err
+		}
+		pr, err := c.github.GetPullRequest(ctx, owner, repo, number)
+		if err != nil {
+			return fmt.Errorf("%s: %w", arg, err)
+		}
+		event := pullRequestEvent(pr, webhook.ActionRequested)
+		if err := c.publish(ctx, event, "enqueue", c.priorities.Options(&event), true); err != nil {
+			return fmt.Errorf("%s: %w", arg, err)
+		}
+		log.Printf("Queued review of %s/%s#%d at %s", owner, repo, number, pr.GetHead().GetSHA())
+	}
+	return nil
+}
+
+// backfill publishes reviews of every open pull request of the given repositories
+// or owners. They are queued like newly opened pull requests, so drafts, opted-out
+// pull requests and commits that were already reviewed are skipped by the worker
+func (c *controller) backfill(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "list the pull requests without queuing reviews")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("backfill needs at least one owner/repo or owner")
+	}
+
+	queued := 0
+	for _, target := range flags.Args() {
+		owner, repo, _ := strings.Cut(target, "/")
+		repos := []string{repo}
+		if repo == "" {
+			var err error
+			if repos, err = c.github.ListRepositories(ctx, owner); err != nil {
+				return fmt.Errorf("%s: %w", target, err)
+			}
+		}
+
+		for _, repo := range repos {
+			prs, err := c.github.ListOpenPullRequests(ctx, owner, repo)
+			if err != nil {
+				return fmt.Errorf("%s/%s: %w", owner, repo, err)
+			}
+			for _, pr := range prs {
+				if *dryRun {
+					fmt.Printf("%s/%s#%d\t%s\n", owner, repo, pr.GetNumber(), pr.GetTitle())
+					continue
+				}
+				// Backfills must not hold up reviews of new pushes
+				opts := queue.PublishOptions{Route: queue.RouteReview, Priority: queue.PriorityLow}
+				if err := c.publish(ctx, pullRequestEvent(pr, "opened"), "backfill", opts, true); err != nil {
+					return fmt.Errorf("%s/%s#%d: %w", owner, repo, pr.GetNumber(), err)
+				}
+				queued++
+			}
+		}
+	}
+
+	if !*dryRun {
+		log.Printf("Queued %d pull request(s) for review", queued)
+	}
+	return nil
+}
+
+// replay publishes stored pull_request webhook payloads, e.g. saved from the
+// webhook's recent deliveries page, as if GitHub had delivered them again
+func (c *controller) replay(files []string) error {
+	if len(files) == 0 {
+		return fmt.Errorf("replay needs at least one payload file")
+	}
+
+	for _, file := range files {
+		body, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read payload: %w", err)
+		}
+		var event webhook.GitHubPullRequestEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			return fmt.Errorf("%s: failed to parse payload: %w", file, err)
+		}
+		if event.Action == "" || event.PullRequest.Number == 0 || event.Repository.Name == "" {
+			return fmt.Errorf("%s: not a pull_request webhook payload", file)
+		}
+
+		// The payload's head may be outdated, so it must not supersede newer pushes
+		if err := c.publish(context.Background(), event, "replay", c.priorities.Options(&event), false); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		log.Printf("Replayed %s event for %s#%d", event.Action, event.Repository.FullName, event.PullRequest.Number)
+	}
+	return nil
+}
+
+// publish queues a job for an event, first recording its head as the pull
+// request's newest when it was just read from GitHub
+func (c *controller) publish(ctx context.Context, event webhook.GitHubPullRequestEvent, trigger string, opts queue.PublishOptions, currentHead bool) error {
+	if c.store != nil && currentHead {
+		key := dedup.Key(event.Repository.Owner.Login, event.Repository.Name, event.PullRequest.Number)
+		if err := c.store.SetHead(ctx, key, event.PullRequest.Head.Sha); err != nil {
+			log.Printf("Failed to record head of PR #%d: %v", event.PullRequest.Number, err)
+		}
+	}
+	j, err := webhook.NewJob(&event, trigger, "")
+	if err != nil {
+		return err
+	}
+	return c.queue.PublishWith(j, opts)
+}
+
+// parsePullRequest splits an owner/repo#number reference
+func parsePullRequest(ref string) (owner, repo string, number int, err error) {
+	fullName, num, ok := strings.Cut(ref, "#")
+	owner, repo, _ = strings.Cut(fullName, "/")
+	number, convErr := strconv.Atoi(num)
+	if !ok || owner == "" || repo == "" || convErr != nil || number <= 0 {
+		return "", "", 0, fmt.Errorf("invalid pull request %q (expected owner/repo#number)", ref)
+	}
+	return owner, repo, number, nil
+}
+
+// pullRequestEvent converts a pull request read from the API into the webhook
+// event the worker consumes
+func pullRequestEvent(pr *github.PullRequest, action string) webhook.GitHubPullRequestEvent {
+	var event webhook.GitHubPullRequestEvent
+	event.Action = action
+	event.Number = pr.GetNumber()
+
+	event.PullRequest.ID = pr.GetID()
+	event.PullRequest.Number = pr.GetNumber()
+	event.PullRequest.Title = pr.GetTitle()
+	event.PullRequest.Body = pr.Body
+	event.PullRequest.Draft = pr.GetDraft()
+	event.PullRequest.Additions = pr.GetAdditions()
+	event.PullRequest.Deletions = pr.GetDeletions()
+	event.PullRequest.User.Login = pr.GetUser().GetLogin()
+	for _, label := range pr.Labels {
+		event.PullRequest.Labels = append(event.PullRequest.Labels, webhook.Label{Name: label.GetName()})
+	}
+
+	event.PullRequest.Head.Sha = pr.GetHead().GetSHA()
+	event.PullRequest.Head.Ref = pr.GetHead().GetRef()
+	event.PullRequest.Head.Repo.FullName = pr.GetHead().GetRepo().GetFullName()
+
+	baseRepo := pr.GetBase().GetRepo()
+	event.PullRequest.Base.Sha = pr.GetBase().GetSHA()
+	event.PullRequest.Base.Ref = pr.GetBase().GetRef()
+	event.PullRequest.Base.Repo.Name = baseRepo.GetName()
+	event.PullRequest.Base.Repo.FullName = baseRepo.GetFullName()
+	event.PullRequest.Base.Repo.Owner.Login = baseRepo.GetOwner().GetLogin()
+
+	event.Repository.Name = baseRepo.GetName()
+	event.Repository.FullName = baseRepo.GetFullName()
+	event.Repository.Owner.Login = baseRepo.GetOwner().GetLogin()
+	return event
+}
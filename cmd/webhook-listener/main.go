@@ -1,15 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/carlr/codereviewtool/internal/config"
+	"github.com/carlr/codereviewtool/internal/dedup"
 	"github.com/carlr/codereviewtool/internal/queue"
 	"github.com/carlr/codereviewtool/internal/webhook"
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
 )
 
 func main() {
@@ -21,22 +25,51 @@ func main() {
 	}
 
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := config.LoadFor(config.RoleListener)
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	// Initialize RabbitMQ
-	rabbitMQ, err := queue.NewRabbitMQ(cfg.RabbitMQURL)
+	// Initialize queue
+	jobs, err := queue.Open(queue.Options{
+		Backend:     cfg.QueueBackend,
+		RabbitMQURL: cfg.RabbitMQURL,
+		RedisURL:    cfg.RedisURL,
+		NATSURL:     cfg.NATSURL,
+		SQLitePath:  cfg.SQLitePath,
+	})
 	if err != nil {
-		log.Fatalf("Failed to connect to RabbitMQ: %v", err)
+		log.Fatalf("Failed to connect to queue: %v", err)
 	}
-	defer rabbitMQ.Close()
+	defer jobs.Close()
 
-	log.Println("Connected to RabbitMQ")
+	if cfg.QueueBackend == "memory" {
+		log.Println("Warning: QUEUE_BACKEND=memory only delivers jobs within this process")
+	}
+	log.Printf("Connected to %s queue", cfg.QueueBackend)
 
 	// Create webhook handler
-	webhookHandler := webhook.NewHandler(cfg.GitHubWebhookSecret, rabbitMQ)
+	webhookHandler := webhook.NewHandler(cfg.GitHubWebhookSecret, jobs)
+	webhookHandler.SetPriorities(webhook.PriorityRules{
+		Branches:     cfg.PriorityBranches,
+		Labels:       cfg.PriorityLabels,
+		SmallDiff:    cfg.PrioritySmallDiff,
+		TriggerLabel: cfg.ReviewTriggerLabel,
+	})
+
+	// Ignore redelivered webhooks and record each pull request's newest head
+	dedupCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	store, err := dedup.Open(dedupCtx, cfg.DedupStore, cfg.PostgresURL, cfg.SQLitePath)
+	cancel()
+	if err != nil {
+		log.Fatalf("Failed to create deduplication store: %v", err)
+	}
+	if store != nil {
+		webhookHandler.SetStore(store)
+	}
+	if cfg.DedupStore == "" || cfg.DedupStore == "memory" {
+		log.Println("Warning: DEDUP_STORE=memory isn't shared with workers, so they can't skip jobs for superseded commits; use postgres or sqlite")
+	}
 
 	// Setup HTTP router
 	router := mux.NewRouter()
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/carlr/codereviewtool/internal/analyzer"
+	"github.com/carlr/codereviewtool/internal/webhook"
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+// autoFixBranchPrefix names the branches auto-fix pushes, so the bot never tries
+// to fix its own follow-up pull requests
+const autoFixBranchPrefix = "ai-review/fix-"
+
+// autoFix opens a follow-up pull request against the PR branch with fixes for the
+// review's mechanical issues and links it from the review
+func (w *worker) autoFix(ctx context.Context, event *webhook.GitHubPullRequestEvent, pr analyzer.PullRequest, review *llm.CodeReviewResponse) error {
+	if !w.cfg.AutoFixEnabled {
+		return nil
+	}
+	head := event.PullRequest.Head
+	if strings.HasPrefix(head.Ref, autoFixBranchPrefix) {
+		return nil
+	}
+	if head.Repo.FullName != "" && head.Repo.FullName != event.Repository.FullName {
+		log.Printf("Skipping auto-fix for PR #%d: head branch is in fork %s", pr.Number, head.Repo.FullName)
+		return nil
+	}
+
+	shortSHA := pr.HeadSHA
+	if len(shortSHA) > 7 {
+		shortSHA = shortSHA[:7]
+	}
+	branch := fmt.Sprintf("%s%d-%s", autoFixBranchPrefix, pr.Number, shortSHA)
+
+	result, err := w.analyzer.AutoFix(ctx, pr, review, branch)
+	if err != nil || result == nil {
+		return err
+	}
+
+	number, url, err := w.github.CreatePullRequest(ctx, pr.Owner, pr.Repo, result.Summary, result.Branch, head.Ref, formatAutoFixBody(pr.Number, result))
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Opened auto-fix PR #%d for PR #%d", number, pr.Number)
+	review.FixPullRequestURL = url
+	return nil
+}
+
+// formatAutoFixBody describes the fixes in the follow-up pull request
+func formatAutoFixBody(prNumber int, result *analyzer.AutoFixResult) string {
+	body := fmt.Sprintf("Automated fixes for review findings in #%d. Merging this pull request updates its branch.\n\n", prNumber)
+	body += "### Fixed issues\n\n"
+	for _, c := range result.Fixed {
+		body += fmt.Sprintf("- **%s**:%d - %s\n", c.Filename, c.Line, c.Body)
+	}
+	return body
+}
+
+// formatAutoFix links the follow-up pull request from the review
+func formatAutoFix(review *llm.CodeReviewResponse) string {
+	if review.FixPullRequestURL == "" {
+		return ""
+	}
+	return "\n\n### Automatic fixes\n\nFixes for mechanical issues are ready to merge in " + review.FixPullRequestURL
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"unicode/utf8"
+
+	"github.com/carlr/codereviewtool/internal/policy"
+	"github.com/carlr/codereviewtool/internal/scm"
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+const (
+	// checkRunName is the name shown for the review in the PR's checks list
+	checkRunName = "AI Code Review"
+	// maxCheckSummaryLength keeps the summary under GitHub's 65535 character limit
+	maxCheckSummaryLength = 60000
+)
+
+// startCheckRun creates an in-progress check run, returning 0 if check runs are
+// disabled or could not be created
+func (w *worker) startCheckRun(ctx context.Context, owner, repo, headSHA string) int64 {
+	if !w.cfg.CheckRunsEnabled {
+		return 0
+	}
+
+	id, err := w.github.CreateCheckRun(ctx, owner, repo, checkRunName, headSHA)
+	if err != nil {
+		log.Printf("Failed to start check run: %v", err)
+		return 0
+	}
+	return id
+}
+
+// completeCheckRun reports the review on the check run with one annotation per comment.
+// When a merge policy decision is given it determines the conclusion
+func (w *worker) completeCheckRun(ctx context.Context, owner, repo string, checkRunID int64, review *llm.CodeReviewResponse, decision *policy.Decision) error {
+	if checkRunID == 0 {
+		return nil
+	}
+
+	conclusion := checkConclusion(review)
+	summary := formatReviewSummary(review)
+	if decision != nil {
+		conclusion = policyOutcome(decision)
+		summary = "**Merge policy:** " + decision.Description() + "\n\n" + summary
+	}
+
+	output := scm.CheckRunOutput{
+		Title:       checkTitle(review),
+		Summary:     truncate(summary, maxCheckSummaryLength),
+		Annotations: checkAnnotations(review),
+	}
+	return w.github.CompleteCheckRun(ctx, owner, repo, checkRunID, checkRunName, conclusion, output)
+}
+
+// failCheckRun closes the check run when the review could not be completed. The
+// conclusion is neutral so an outage of the reviewer doesn't block merges
+func (w *worker) failCheckRun(ctx context.Context, owner, repo string, checkRunID int64, reviewErr error) {
+	if checkRunID == 0 {
+		return
+	}
+
+	output := scm.CheckRunOutput{
+		Title:   "Review could not be completed",
+		Summary: fmt.Sprintf("The AI review failed and will be retried.\n\n```\n%v\n```", reviewErr),
+	}
+	if err := w.github.CompleteCheckRun(ctx, owner, repo, checkRunID, checkRunName, "neutral", output); err != nil {
+		log.Printf("Failed to complete check run: %v", err)
+	}
+}
+
+// cancelCheckRun closes the check run of a review made stale by a newer push or
+// by closing the pull request
+func (w *worker) cancelCheckRun(ctx context.Context, owner, repo string, checkRunID int64) {
+	if checkRunID == 0 {
+		return
+	}
+
+	output := scm.CheckRunOutput{
+		Title:   "Review cancelled",
+		Summary: "The review was cancelled because the pull request was updated or closed.",
+	}
+	if err := w.github.CompleteCheckRun(ctx, owner, repo, checkRunID, checkRunName, "cancelled", output); err != nil {
+		log.Printf("Failed to complete check run: %v", err)
+	}
+}
+
+// checkConclusion derives the check run conclusion from the most severe finding.
+// A response that couldn't be parsed is neutral, since nothing was reviewed
+func checkConclusion(review *llm.CodeReviewResponse) string {
+	if review.Unparsed {
+		return "neutral"
+	}
+	switch review.HighestSeverity() {
+	case "error":
+		return "failure"
+	case "warning":
+		return "neutral"
+	default:
+		return "success"
+	}
+}
+
+// checkTitle summarizes the finding counts in one line
+func checkTitle(review *llm.CodeReviewResponse) string {
+	if review.Unparsed {
+		return "Review output could not be parsed"
+	}
+	counts := review.SeverityCounts()
+	if len(review.Comments) == 0 {
+		return "No issues found"
+	}
+	return fmt.Sprintf("%d errors, %d warnings, %d info", counts["error"], counts["warning"], counts["info"])
+}
+
+// checkAnnotations converts line comments into check run annotations
+func checkAnnotations(review *llm.CodeReviewResponse) []scm.CheckAnnotation {
+	annotations := make([]scm.CheckAnnotation, 0, len(review.Comments))
+	for _, c := range review.Comments {
+		// Annotations need a line in the head version of the file; file-level
+		// comments and comments on removed lines stay in the summary
+		if c.Filename == "" || c.Line <= 0 || c.Side == "LEFT" {
+			continue
+		}
+		startLine := c.Line
+		if c.StartLine > 0 && c.StartLine < c.Line {
+			startLine = c.StartLine
+		}
+
+		title := c.Category
+		if c.Source != "" {
+			title = c.Source
+		}
+		annotations = append(annotations, scm.CheckAnnotation{
+			Path:      c.Filename,
+			StartLine: startLine,
+			EndLine:   c.Line,
+			Level:     annotationLevel(c.Severity),
+			Title:     title,
+			Message:   c.Body,
+		})
+	}
+	return annotations
+}
+
+// annotationLevel maps a review severity onto a check annotation level
+func annotationLevel(severity string) string {
+	switch severity {
+	case "error":
+		return "failure"
+	case "warning":
+		return "warning"
+	default:
+		return "notice"
+	}
+}
+
+// truncatedMarker is appended to text that was cut
+const truncatedMarker = "\n\n_(truncated)_"
+
+// truncate shortens s to at most n bytes including the marker, cutting on a rune
+// boundary so the result stays valid UTF-8
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	marker := truncatedMarker
+	if n < len(marker) {
+		marker = ""
+	}
+	cut := n - len(marker)
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + marker
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+func TestCheckConclusion(t *testing.T) {
+	tests := []struct {
+		name       string
+		review     llm.CodeReviewResponse
+		conclusion string
+		title      string
+	}{
+		{"clean", llm.CodeReviewResponse{}, "success", "No issues found"},
+		{"errors", llm.CodeReviewResponse{Comments: []llm.ReviewComment{{Severity: "error"}}}, "failure", "1 errors, 0 warnings, 0 info"},
+		{"warnings", llm.CodeReviewResponse{Comments: []llm.ReviewComment{{Severity: "warning"}}}, "neutral", "0 errors, 1 warnings, 0 info"},
+		{"unparsed", llm.CodeReviewResponse{Unparsed: true}, "neutral", "Review output could not be parsed"},
+	}
+	for _, tt := range tests {
+		if got := checkConclusion(&tt.review); got != tt.conclusion {
+			t.Errorf("%s: Expected conclusion %s, got %s", tt.name, tt.conclusion, got)
+		}
+		if got := checkTitle(&tt.review); got != tt.title {
+			t.Errorf("%s: Expected title %q, got %q", tt.name, tt.title, got)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("short", 10); got != "short" {
+		t.Errorf("Expected short text to be kept, got %q", got)
+	}
+
+	long := strings.Repeat("é", 40)
+	got := truncate(long, 31)
+	if len(got) > 31 || !utf8.ValidString(got) || !strings.HasSuffix(got, truncatedMarker) {
+		t.Errorf("Expected valid UTF-8 of at most 31 bytes ending in the marker, got %q", got)
+	}
+	if got := truncate(long, 5); len(got) > 5 || !utf8.ValidString(got) {
+		t.Errorf("Expected valid UTF-8 of at most 5 bytes, got %q", got)
+	}
+}
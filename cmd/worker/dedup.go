@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/carlr/codereviewtool/internal/dedup"
+	"github.com/carlr/codereviewtool/internal/webhook"
+)
+
+// claimReview decides whether the event's head commit is reviewed now. Jobs for
+// commits superseded by a newer push are skipped, as are commits that are already
+// reviewed or being reviewed, except when the trigger label or reviewctl explicitly
+// asks for a review. claimed reports whether the review must be finished with finishReview
+func (w *worker) claimReview(ctx context.Context, event *webhook.GitHubPullRequestEvent) (review, claimed bool) {
+	if w.store == nil {
+		return true, false
+	}
+	key := dedup.Key(event.Repository.Owner.Login, event.Repository.Name, event.PullRequest.Number)
+	sha := event.PullRequest.Head.Sha
+
+	// Store failures shouldn't stop reviews, so they are logged and ignored
+	head, err := w.store.Head(ctx, key)
+	if err != nil {
+		log.Printf("Failed to look up head of PR #%d: %v", event.PullRequest.Number, err)
+	} else if head != "" && head != sha {
+		log.Printf("Skipping PR #%d at %s: superseded by %s", event.PullRequest.Number, sha, head)
+		return false, false
+	}
+
+	claimed, err = w.store.Claim(ctx, key, sha)
+	if err != nil {
+		log.Printf("Failed to claim review of PR #%d: %v", event.PullRequest.Number, err)
+		return true, false
+	}
+	if !claimed && event.Action != "labeled" && event.Action != webhook.ActionRequested {
+		log.Printf("Skipping PR #%d at %s: already reviewed or in progress", event.PullRequest.Number, sha)
+		return false, false
+	}
+	return true, claimed
+}
+
+// isCurrentHead asks GitHub whether the pull request is still open and the event's
+// commit hasn't been superseded by a newer push, so stale jobs are skipped even when
+// the dedup store doesn't know about them. Lookup failures are logged and the commit
+// is reviewed
+func (w *worker) isCurrentHead(ctx context.Context, event *webhook.GitHubPullRequestEvent) bool {
+	prNumber := event.PullRequest.Number
+	sha := event.PullRequest.Head.Sha
+	pr, err := w.github.GetPullRequest(ctx, event.Repository.Owner.Login, event.Repository.Name, prNumber)
+	if err != nil {
+		log.Printf("Failed to look up PR #%d: %v", prNumber, err)
+		return true
+	}
+	if pr.GetState() == "closed" {
+		log.Printf("Skipping PR #%d at %s: closed", prNumber, sha)
+		return false
+	}
+	head := pr.GetHead().GetSHA()
+	if head == "" || head == sha {
+		return true
+	}
+
+	// GitHub may still report the previous head right after a push, so the job is
+	// only stale if the reported head was built on top of its commit
+	newer, err := w.github.IsAncestor(ctx, event.Repository.Owner.Login, event.Repository.Name, sha, head)
+	if err != nil {
+		log.Printf("Failed to compare PR #%d heads: %v", prNumber, err)
+		return true
+	}
+	if newer {
+		log.Printf("Skipping PR #%d at %s: superseded by %s", prNumber, sha, head)
+		return false
+	}
+	return true
+}
+
+// cancelledElsewhere reports whether the review became stale without reviewCtx
+// being cancelled, because a worker in another process recorded the pull request
+// as closed or the listener recorded a newer push. It is checked before anything
+// is posted; store failures are logged and the review goes ahead
+func (w *worker) cancelledElsewhere(ctx context.Context, event *webhook.GitHubPullRequestEvent) bool {
+	if w.store == nil {
+		return false
+	}
+	key := dedup.Key(event.Repository.Owner.Login, event.Repository.Name, event.PullRequest.Number)
+	sha := event.PullRequest.Head.Sha
+
+	cancelled, err := w.store.Cancelled(ctx, key, sha)
+	if err != nil {
+		log.Printf("Failed to look up cancellation of PR #%d: %v", event.PullRequest.Number, err)
+	}
+	if cancelled {
+		return true
+	}
+	head, err := w.store.Head(ctx, key)
+	if err != nil {
+		log.Printf("Failed to look up head of PR #%d: %v", event.PullRequest.Number, err)
+	}
+	return head != "" && head != sha
+}
+
+// finishReview marks a claimed review as done, or releases it after a failure so
+// the requeued job can retry. The store leaves cancelled reviews cancelled
+func (w *worker) finishReview(ctx context.Context, event *webhook.GitHubPullRequestEvent, reviewErr error) {
+	key := dedup.Key(event.Repository.Owner.Login, event.Repository.Name, event.PullRequest.Number)
+	sha := event.PullRequest.Head.Sha
+
+	var err error
+	if reviewErr != nil {
+		err = w.store.Release(ctx, key, sha)
+	} else {
+		err = w.store.Complete(ctx, key, sha)
+	}
+	if err != nil {
+		log.Printf("Failed to record review state of PR #%d: %v", event.PullRequest.Number, err)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/carlr/codereviewtool/internal/dedup"
+	"github.com/carlr/codereviewtool/internal/webhook"
+)
+
+func TestClaimReview(t *testing.T) {
+	ctx := context.Background()
+	event := func(action, sha string) *webhook.GitHubPullRequestEvent {
+		e := &webhook.GitHubPullRequestEvent{Action: action}
+		e.Repository.Owner.Login = "octo"
+		e.Repository.Name = "app"
+		e.PullRequest.Number = 1
+		e.PullRequest.Head.Sha = sha
+		return e
+	}
+
+	tests := []struct {
+		name            string
+		head            string // Recorded head; empty if unknown
+		reviewed        string // SHA already reviewed
+		event           *webhook.GitHubPullRequestEvent
+		review, claimed bool
+	}{
+		{"new head", "abc", "", event("synchronize", "abc"), true, true},
+		{"unknown head", "", "", event("synchronize", "abc"), true, true},
+		{"superseded", "def", "", event("synchronize", "abc"), false, false},
+		{"already reviewed", "abc", "abc", event("synchronize", "abc"), false, false},
+		{"requested again", "abc", "abc", event(webhook.ActionRequested, "abc"), true, false},
+		{"labeled again", "abc", "abc", event("labeled", "abc"), true, false},
+	}
+	for _, tt := range tests {
+		store := dedup.NewMemoryStore()
+		key := dedup.Key("octo", "app", 1)
+		if tt.head != "" {
+			store.SetHead(ctx, key, tt.head)
+		}
+		if tt.reviewed != "" {
+			store.Claim(ctx, key, tt.reviewed)
+			store.Complete(ctx, key, tt.reviewed)
+		}
+
+		w := &worker{store: store}
+		review, claimed := w.claimReview(ctx, tt.event)
+		if review != tt.review || claimed != tt.claimed {
+			t.Errorf("%s: expected review=%v claimed=%v, got review=%v claimed=%v", tt.name, tt.review, tt.claimed, review, claimed)
+		}
+	}
+
+	// Without a store every event is reviewed
+	if review, claimed := (&worker{}).claimReview(ctx, event("synchronize", "abc")); !review || claimed {
+		t.Errorf("Expected review without a claim when there is no store, got review=%v claimed=%v", review, claimed)
+	}
+}
+
+func TestCancelledElsewhere(t *testing.T) {
+	ctx := context.Background()
+	event := &webhook.GitHubPullRequestEvent{Action: "synchronize"}
+	event.Repository.Owner.Login = "octo"
+	event.Repository.Name = "app"
+	event.PullRequest.Number = 1
+	event.PullRequest.Head.Sha = "abc"
+	key := dedup.Key("octo", "app", 1)
+
+	store := dedup.NewMemoryStore()
+	w := &worker{store: store}
+	store.SetHead(ctx, key, "abc")
+	store.Claim(ctx, key, "abc")
+	if w.cancelledElsewhere(ctx, event) {
+		t.Error("Expected a running review of the head not to be cancelled")
+	}
+
+	// Another worker handled the pull request being closed
+	store.Cancel(ctx, key)
+	if !w.cancelledElsewhere(ctx, event) {
+		t.Error("Expected a review cancelled in the store to be cancelled")
+	}
+
+	store = dedup.NewMemoryStore()
+	w.store = store
+	store.Claim(ctx, key, "abc")
+	store.SetHead(ctx, key, "def")
+	if !w.cancelledElsewhere(ctx, event) {
+		t.Error("Expected a review of a superseded head to be cancelled")
+	}
+}
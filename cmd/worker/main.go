@@ -2,24 +2,42 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"database/sql"
+	"errors"
+	"expvar"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/carlr/codereviewtool/internal/analyzer"
 	"github.com/carlr/codereviewtool/internal/config"
+	"github.com/carlr/codereviewtool/internal/dedup"
+	"github.com/carlr/codereviewtool/internal/job"
+	"github.com/carlr/codereviewtool/internal/lint"
+	"github.com/carlr/codereviewtool/internal/policy"
 	"github.com/carlr/codereviewtool/internal/queue"
 	"github.com/carlr/codereviewtool/internal/scm"
 	"github.com/carlr/codereviewtool/internal/webhook"
 	"github.com/carlr/codereviewtool/pkg/llm"
 	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
 )
 
 func main() {
-	log.Println("Starting Code Review AI - Worker")
+	// "worker serve" also receives webhooks, running the tool as one process
+	serve := len(os.Args) > 1 && os.Args[1] == "serve"
+	role := config.RoleWorker
+	if serve {
+		role = config.RoleServe
+		log.Println("Starting Code Review AI - All-in-one")
+	} else {
+		log.Println("Starting Code Review AI - Worker")
+	}
 
 	// Load .env file
 	if err := godotenv.Load(); err != nil {
@@ -27,7 +45,7 @@ func main() {
 	}
 
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := config.LoadFor(role)
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
@@ -56,34 +74,116 @@ func main() {
 	log.Printf("Using LLM provider: %s", llmProvider.Name())
 
 	// Initialize GitHub client
-	githubClient := scm.NewGitHubClient(cfg.GitHubToken)
+	responseCache, err := newResponseCache(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create GitHub response cache: %v", err)
+	}
+	githubClient, err := scm.NewGitHubClient(cfg.GitHubToken, scm.ClientOptions{
+		Cache:            responseCache,
+		MaxRateLimitWait: time.Duration(cfg.GitHubRateLimitWait) * time.Second,
+		BaseURL:          cfg.GitHubBaseURL,
+		UploadURL:        cfg.GitHubUploadURL,
+		CAFile:           cfg.GitHubCABundle,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create GitHub client: %v", err)
+	}
+	if cfg.GitHubBaseURL != "" {
+		log.Printf("Using GitHub Enterprise Server at %s", cfg.GitHubBaseURL)
+	}
+
+	// Serve expvar metrics such as the remaining GitHub quota
+	if cfg.MetricsAddr != "" {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/debug/vars", expvar.Handler())
+			log.Printf("Serving metrics on %s/debug/vars", cfg.MetricsAddr)
+			if err := http.ListenAndServe(cfg.MetricsAddr, mux); err != nil {
+				log.Printf("Metrics server stopped: %v", err)
+			}
+		}()
+	}
 
 	// Initialize analyzer
-	codeAnalyzer := analyzer.NewAnalyzer(llmProvider, githubClient)
+	analyzerOptions := analyzer.Options{
+		ContextTokenBudget:    cfg.ContextTokenBudget,
+		GoAPIAnalysis:         cfg.GoAPIAnalysis,
+		StaticAnalysisTimeout: time.Duration(cfg.StaticAnalysisTimeout) * time.Second,
+		AutoFixCategories:     cfg.AutoFixCategories,
+		AutoFixTestCommand:    strings.Fields(cfg.AutoFixTestCommand),
+		AutoFixTimeout:        time.Duration(cfg.AutoFixTimeout) * time.Second,
+	}
+	if cfg.StaticAnalysisEnabled {
+		analyzerOptions.Linters, err = lint.Lookup(cfg.StaticAnalysisLinters)
+		if err != nil {
+			log.Fatalf("Invalid STATIC_ANALYSIS_LINTERS: %v", err)
+		}
+		log.Printf("Static analysis enabled with: %s", strings.Join(cfg.StaticAnalysisLinters, ", "))
+	}
+	codeAnalyzer := analyzer.NewAnalyzer(llmProvider, githubClient, analyzerOptions)
 
-	// Initialize RabbitMQ
-	rabbitMQ, err := queue.NewRabbitMQ(cfg.RabbitMQURL)
+	// Initialize queue
+	jobs, err := queue.Open(queue.Options{
+		Backend:     cfg.QueueBackend,
+		RabbitMQURL: cfg.RabbitMQURL,
+		RedisURL:    cfg.RedisURL,
+		NATSURL:     cfg.NATSURL,
+		SQLitePath:  cfg.SQLitePath,
+	})
 	if err != nil {
-		log.Fatalf("Failed to connect to RabbitMQ: %v", err)
+		log.Fatalf("Failed to connect to queue: %v", err)
 	}
-	defer rabbitMQ.Close()
+	defer jobs.Close()
 
-	log.Println("Connected to RabbitMQ")
+	if cfg.QueueBackend == "memory" && !serve {
+		log.Println("Warning: QUEUE_BACKEND=memory only delivers jobs within this process")
+	}
+	log.Printf("Connected to %s queue", cfg.QueueBackend)
 
 	// Setup graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// Load merge policies
+	policies, err := policy.Load(cfg.MergePolicyFile)
+	if err != nil {
+		log.Fatalf("Failed to load merge policy: %v", err)
+	}
+
+	// Skip reviews of commits that were already reviewed or superseded
+	dedupCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	store, err := dedup.Open(dedupCtx, cfg.DedupStore, cfg.PostgresURL, cfg.SQLitePath)
+	cancel()
+	if err != nil {
+		log.Fatalf("Failed to create deduplication store: %v", err)
+	}
+	if (cfg.DedupStore == "" || cfg.DedupStore == "memory") && !serve {
+		log.Println("Warning: DEDUP_STORE=memory isn't shared with the webhook listener, so jobs for superseded commits are still reviewed; use postgres or sqlite")
+	}
+
+	w := &worker{
+		cfg:        cfg,
+		analyzer:   codeAnalyzer,
+		github:     githubClient,
+		policies:   policies,
+		store:      store,
+		inflight:   dedup.NewRegistry(),
+		jobs:       jobs,
+		retryDelay: jobRetryDelay,
+	}
+
 	// Start consuming messages
 	go func() {
-		err := rabbitMQ.Consume(func(body []byte) error {
-			return processEvent(body, codeAnalyzer, githubClient)
-		})
+		err := jobs.Consume(cfg.WorkerConcurrency, w.processEvent)
 		if err != nil {
 			log.Fatalf("Failed to consume messages: %v", err)
 		}
 	}()
 
+	if serve {
+		go serveWebhooks(cfg, jobs, store)
+	}
+
 	log.Println("Worker is ready and waiting for pull requests...")
 
 	// Wait for shutdown signal
@@ -91,15 +191,167 @@ func main() {
 	log.Println("Shutting down worker...")
 }
 
-func processEvent(body []byte, codeAnalyzer *analyzer.Analyzer, githubClient *scm.GitHubClient) error {
-	// Parse the webhook event
-	var event webhook.GitHubPullRequestEvent
-	if err := json.Unmarshal(body, &event); err != nil {
+// memoryCacheSize bounds the in-process GitHub response cache
+const memoryCacheSize = 64 << 20
+
+// newResponseCache creates the configured cache for conditional GitHub requests
+func newResponseCache(cfg *config.Config) (scm.ResponseCache, error) {
+	switch cfg.GitHubCache {
+	case "none":
+		return nil, nil
+	case "postgres":
+		db, err := sql.Open("postgres", cfg.PostgresURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open Postgres: %w", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return scm.NewPostgresCache(ctx, db)
+	default:
+		return scm.NewMemoryCache(memoryCacheSize), nil
+	}
+}
+
+// worker processes queued pull request events
+type worker struct {
+	cfg      *config.Config
+	analyzer *analyzer.Analyzer
+	github   *scm.GitHubClient
+	policies *policy.Config
+	store    dedup.Store // Optional; skips duplicate and superseded reviews
+	inflight *dedup.Registry
+	jobs     queue.Queue // Failed jobs are queued here again
+
+	retryDelay time.Duration
+}
+
+// jobRetryDelay is how long a job waits after its first failure before it is
+// queued again; the delay doubles with each further attempt
+const jobRetryDelay = 5 * time.Second
+
+// processEvent handles a queued job, queueing it again if it fails
+func (w *worker) processEvent(body []byte) error {
+	err := w.handleJob(body)
+	if err == nil {
+		return nil
+	}
+	return w.retryJob(body, err)
+}
+
+// retryJob queues a failed job again with its attempt incremented, or drops it
+// once it was tried job.MaxAttempts times. Retries take the low priority so they
+// don't hold up new reviews. If the retry can't be queued, the error is returned
+// and the queue redelivers the failed job
+func (w *worker) retryJob(body []byte, jobErr error) error {
+	retry, err := job.NextAttempt(body)
+	if err != nil {
+		log.Printf("Dropping malformed job that failed with %v: %v", jobErr, err)
+		return nil
+	}
+	if retry.Attempt > job.MaxAttempts {
+		log.Printf("Dropping %s job %s after %d attempts: %v", retry.Type, retry.TraceID, job.MaxAttempts, jobErr)
+		return nil
+	}
+
+	delay := w.retryDelay << (retry.Attempt - 2)
+	log.Printf("Job %s failed, queueing attempt %d of %d in %s: %v", retry.TraceID, retry.Attempt, job.MaxAttempts, delay, jobErr)
+	time.Sleep(delay)
+
+	opts := queue.PublishOptions{Route: queue.RouteCommand, Priority: queue.PriorityLow}
+	if retry.Type == job.TypeReview {
+		opts.Route = queue.RouteReview
+	}
+	if err := w.jobs.PublishWith(retry.Body, opts); err != nil {
+		log.Printf("Failed to queue retry of job %s: %v", retry.TraceID, err)
+		return jobErr
+	}
+	return nil
+}
+
+// handleJob decodes a job and runs it
+func (w *worker) handleJob(body []byte) error {
+	j, err := job.Decode(body)
+	if err != nil {
+		// Jobs of newer versions are retried until an upgraded worker takes them
 		return err
 	}
+	if j.SCM != job.SCMGitHub {
+		log.Printf("Dropping %s job %s for unsupported SCM %q", j.Type, j.TraceID, j.SCM)
+		return nil
+	}
+	event, err := webhook.EventFromJob(j)
+	if err != nil {
+		return err
+	}
+	log.Printf("Processing %s job for %s/%s#%d (trigger %s, attempt %d, trace %s, queued %s ago)",
+		j.Type, j.Owner, j.Repo, j.PR, j.Trigger, j.Attempt, j.TraceID, time.Since(j.EnqueuedAt).Round(time.Millisecond))
 
 	ctx := context.Background()
 
+	switch j.Type {
+	case job.TypeReview:
+		// Reviewed below
+	case job.TypeLabel:
+		// Labels other than the review trigger may override the merge policy
+		if !w.isTriggerLabel(event) {
+			return w.handleLabeled(ctx, event)
+		}
+	case job.TypeCommand:
+		return w.handleCommand(ctx, event)
+	case job.TypeCancel:
+		// A closed PR's review would only be noise. Reviews running in other
+		// processes see the cancellation in the store before posting
+		key := dedup.Key(j.Owner, j.Repo, j.PR)
+		if n := w.inflight.Cancel(key); n > 0 {
+			log.Printf("Cancelled %d running review(s) of closed PR #%d", n, j.PR)
+		}
+		if w.store != nil {
+			if err := w.store.Cancel(ctx, key); err != nil {
+				log.Printf("Failed to record cancellation of PR #%d: %v", j.PR, err)
+			}
+		}
+		return nil
+	default:
+		log.Printf("Dropping job %s of unsupported type %q", j.TraceID, j.Type)
+		return nil
+	}
+
+	if !w.shouldReview(event) || !w.isCurrentHead(ctx, event) {
+		return nil
+	}
+
+	review, claimed := w.claimReview(ctx, event)
+	if !review {
+		return nil
+	}
+
+	// A newer push or closing the PR cancels the review through reviewCtx
+	key := dedup.Key(event.Repository.Owner.Login, event.Repository.Name, event.PullRequest.Number)
+	reviewCtx, done := w.inflight.Start(ctx, key, event.PullRequest.Head.Sha, j.EnqueuedAt)
+	if reviewCtx.Err() != nil {
+		// A job queued after this one is already being reviewed
+		err = errReviewCancelled
+	} else {
+		err = w.reviewPullRequest(ctx, reviewCtx, event)
+	}
+	done()
+	if claimed {
+		w.finishReview(ctx, event, err)
+	}
+	if errors.Is(err, errReviewCancelled) {
+		log.Printf("Cancelled stale review of PR #%d at %s", event.PullRequest.Number, event.PullRequest.Head.Sha)
+		return nil
+	}
+	return err
+}
+
+// errReviewCancelled is returned by reviewPullRequest when the review became stale
+var errReviewCancelled = errors.New("review cancelled")
+
+// reviewPullRequest analyzes the pull request's head and reports the results.
+// Analysis and fixes run under reviewCtx, and nothing is posted once it is cancelled;
+// ctx is used for reporting so the check run can still be closed
+func (w *worker) reviewPullRequest(ctx, reviewCtx context.Context, event *webhook.GitHubPullRequestEvent) error {
 	owner := event.Repository.Owner.Login
 	repo := event.Repository.Name
 	prNumber := event.PullRequest.Number
@@ -113,80 +365,279 @@ func processEvent(body []byte, codeAnalyzer *analyzer.Analyzer, githubClient *sc
 
 	log.Printf("Processing PR #%d: %s from %s/%s (author: %s)", prNumber, title, owner, repo, author)
 
+	// Report progress on the commit so branch protection can wait for the review
+	checkRunID := w.startCheckRun(ctx, owner, repo, commitID)
+	w.startPolicyStatus(ctx, owner, repo, commitID, checkRunID)
+
 	// Analyze the pull request
-	review, err := codeAnalyzer.AnalyzePullRequest(ctx, owner, repo, prNumber, title, description, author)
+	pr := analyzer.PullRequest{
+		Owner:       owner,
+		Repo:        repo,
+		Number:      prNumber,
+		Title:       title,
+		Description: description,
+		Author:      author,
+		HeadSHA:     commitID,
+		BaseSHA:     event.PullRequest.Base.Sha,
+	}
+	review, err := w.analyzer.AnalyzePullRequest(reviewCtx, pr)
+	if reviewCtx.Err() != nil || w.cancelledElsewhere(ctx, event) {
+		w.cancelCheckRun(ctx, owner, repo, checkRunID)
+		w.cancelPolicyStatus(ctx, owner, repo, commitID, checkRunID)
+		return errReviewCancelled
+	}
 	if err != nil {
 		log.Printf("Analysis failed: %v", err)
+		w.failCheckRun(ctx, owner, repo, checkRunID, err)
+		w.failPolicyStatus(ctx, owner, repo, commitID, checkRunID)
 		return err
 	}
 
 	log.Printf("Analysis complete. Summary: %s", review.Summary)
 	log.Printf("Found %d comments", len(review.Comments))
 
-	// Post review to GitHub
-	if len(review.Comments) > 0 {
-		// Create a review with all comments
-		githubReview := &scm.Review{
-			Summary:  review.Summary,
-			Comments: make([]scm.ReviewComment, 0, len(review.Comments)),
-			CommitID: commitID,
+	// Export findings for security tooling; failures here shouldn't block the review
+	if err := w.exportSARIF(ctx, owner, repo, prNumber, commitID, review); err != nil {
+		log.Printf("Failed to export SARIF: %v", err)
+	}
+
+	// Fixes are optional; the review is still posted if they can't be made
+	if err := w.autoFix(reviewCtx, event, pr, review); err != nil {
+		log.Printf("Auto-fix failed: %v", err)
+	}
+	if reviewCtx.Err() != nil || w.cancelledElsewhere(ctx, event) {
+		w.cancelCheckRun(ctx, owner, repo, checkRunID)
+		w.cancelPolicyStatus(ctx, owner, repo, commitID, checkRunID)
+		return errReviewCancelled
+	}
+
+	if w.cfg.PostReviewComments {
+		if err := w.postReview(ctx, owner, repo, prNumber, commitID, review); err != nil {
+			w.failCheckRun(ctx, owner, repo, checkRunID, err)
+			w.failPolicyStatus(ctx, owner, repo, commitID, checkRunID)
+			return err
 		}
+	}
 
-		for _, comment := range review.Comments {
-			githubReview.Comments = append(githubReview.Comments, scm.ReviewComment{
+	decision := w.evaluatePolicy(owner, repo, event.PullRequest.Labels, review)
+
+	if err := w.completeCheckRun(ctx, owner, repo, checkRunID, review, decision); err != nil {
+		log.Printf("Failed to complete check run: %v", err)
+		return err
+	}
+	if err := w.reportPolicyStatus(ctx, owner, repo, commitID, checkRunID, decision); err != nil {
+		log.Printf("Failed to report merge policy: %v", err)
+		return err
+	}
+
+	log.Printf("Successfully posted review for PR #%d", prNumber)
+	return nil
+}
+
+// postReview posts line comments as a review with general comments in its body and
+// file-level comments alongside it, falling back to a single summary comment. The
+// review event follows the most severe finding
+func (w *worker) postReview(ctx context.Context, owner, repo string, prNumber int, commitID string, review *llm.CodeReviewResponse) error {
+	var lineComments []scm.ReviewComment
+	var unposted []llm.ReviewComment
+	for _, comment := range review.Comments {
+		switch comment.Scope {
+		case llm.ScopeGeneral:
+			// Rendered in the review body
+		case llm.ScopeFile:
+			err := w.github.PostReviewComment(ctx, owner, repo, prNumber, &scm.ReviewComment{
 				Filename: comment.Filename,
-				Line:     comment.Line,
 				Body:     formatComment(comment),
 				CommitID: commitID,
 			})
-		}
-
-		if err := githubClient.CreateReview(ctx, owner, repo, prNumber, githubReview); err != nil {
-			log.Printf("Failed to create review: %v", err)
-			// Fallback: post as summary comment
-			if err := githubClient.PostReviewSummary(ctx, owner, repo, prNumber, formatReviewSummary(review)); err != nil {
-				log.Printf("Failed to post review summary: %v", err)
-				return err
+			if err != nil {
+				log.Printf("Failed to post file comment on %s: %v", comment.Filename, err)
+				unposted = append(unposted, comment)
 			}
+		default:
+			lineComments = append(lineComments, scm.ReviewComment{
+				Filename:  comment.Filename,
+				StartLine: comment.StartLine,
+				EndLine:   comment.Line,
+				Side:      comment.Side,
+				Body:      formatComment(comment),
+				CommitID:  commitID,
+			})
 		}
-	} else {
+	}
+
+	body := formatReviewBody(review) + formatCommentList("File comments", unposted)
+	event := w.reviewEvent(review)
+
+	// Approving or requesting changes needs a review even without line comments
+	if len(lineComments) == 0 && event == scm.ReviewEventComment {
 		// Just post the summary
-		if err := githubClient.PostReviewSummary(ctx, owner, repo, prNumber, review.Summary); err != nil {
+		if err := w.github.PostReviewSummary(ctx, owner, repo, prNumber, body); err != nil {
 			log.Printf("Failed to post review summary: %v", err)
 			return err
 		}
+		w.dismissResolvedReviews(ctx, owner, repo, prNumber, commitID, event, review)
+		return nil
 	}
 
-	log.Printf("Successfully posted review for PR #%d", prNumber)
+	githubReview := &scm.Review{
+		Summary:  body + "\n\n" + reviewMarker,
+		Comments: lineComments,
+		CommitID: commitID,
+		Event:    event,
+	}
+	err := w.github.CreateReview(ctx, owner, repo, prNumber, githubReview)
+	if err != nil && event != scm.ReviewEventComment {
+		// GitHub refuses some events, such as approving the token owner's own PR
+		log.Printf("Failed to submit %s review, retrying as a comment: %v", event, err)
+		githubReview.Event = scm.ReviewEventComment
+		err = w.github.CreateReview(ctx, owner, repo, prNumber, githubReview)
+	}
+	if err != nil {
+		log.Printf("Failed to create review: %v", err)
+		// Fallback: post as summary comment
+		if err := w.github.PostReviewSummary(ctx, owner, repo, prNumber, formatReviewSummary(review)); err != nil {
+			log.Printf("Failed to post review summary: %v", err)
+			return err
+		}
+	}
+
+	w.dismissResolvedReviews(ctx, owner, repo, prNumber, commitID, event, review)
 	return nil
 }
 
-func formatComment(comment llm.ReviewComment) string {
-	prefix := "[INFO]"
-	switch comment.Severity {
+// severityPrefix returns the marker shown before a comment of the given severity
+func severityPrefix(severity string) string {
+	switch severity {
 	case "warning":
-		prefix = "[WARNING]"
+		return "[WARNING]"
 	case "error":
-		prefix = "[ERROR]"
+		return "[ERROR]"
+	}
+	return "[INFO]"
+}
+
+func formatComment(comment llm.ReviewComment) string {
+	prefix := severityPrefix(comment.Severity)
+	body := prefix + " " + comment.Body
+	if comment.Scope == llm.ScopeFile && comment.Line > 0 {
+		// The line isn't part of the diff, so say which one is meant
+		body = fmt.Sprintf("%s Line %s: %s", prefix, formatLines(comment), comment.Body)
+	}
+	if comment.Suggestion != nil {
+		body += "\n\n" + formatSuggestion(comment.Suggestion.Replacement)
+	}
+	return body
+}
+
+// formatSuggestion renders a replacement as a GitHub suggestion block, using a
+// longer fence if the code itself contains backticks
+func formatSuggestion(replacement string) string {
+	fence := "```"
+	for strings.Contains(replacement, fence) {
+		fence += "`"
+	}
+	replacement = strings.TrimRight(replacement, "\n")
+	if replacement != "" {
+		replacement += "\n"
 	}
-	return prefix + " " + comment.Body
+	return fence + "suggestion\n" + replacement + fence
+}
+
+// formatReviewBody renders the top-level review text with general comments and the
+// sections computed by the analyzer
+func formatReviewBody(review *llm.CodeReviewResponse) string {
+	var general []llm.ReviewComment
+	for _, comment := range review.Comments {
+		if comment.Scope == llm.ScopeGeneral {
+			general = append(general, comment)
+		}
+	}
+	return review.Summary + formatCommentList("General comments", general) + formatAPIChanges(review.APIChanges) + formatSkippedFiles(review.SkippedFiles) + formatAutoFix(review)
+}
+
+// formatSkippedFiles lists the changed files the review couldn't cover
+func formatSkippedFiles(files []llm.SkippedFile) string {
+	if len(files) == 0 {
+		return ""
+	}
+	section := "\n\n### Files skipped\n\nThese files were not reviewed:\n\n"
+	for _, f := range files {
+		section += fmt.Sprintf("- `%s` (%s)\n", f.Filename, f.Reason)
+	}
+	return section
+}
+
+// formatCommentList renders comments as a markdown section
+func formatCommentList(title string, comments []llm.ReviewComment) string {
+	if len(comments) == 0 {
+		return ""
+	}
+	section := "\n\n### " + title + "\n\n"
+	for _, comment := range comments {
+		section += formatCommentItem(comment)
+	}
+	return section
+}
+
+// formatCommentItem renders a comment as a list item, with its location if it has one
+func formatCommentItem(comment llm.ReviewComment) string {
+	prefix := severityPrefix(comment.Severity)
+	switch {
+	case comment.Filename == "":
+		return fmt.Sprintf("- %s %s\n", prefix, comment.Body)
+	case comment.Line <= 0:
+		return fmt.Sprintf("- %s **%s** - %s\n", prefix, comment.Filename, comment.Body)
+	default:
+		return fmt.Sprintf("- %s **%s**:%s - %s\n", prefix, comment.Filename, formatLines(comment), comment.Body)
+	}
+}
+
+// formatAPIChanges renders detected exported API changes as a markdown section
+func formatAPIChanges(changes []llm.APIChange) string {
+	if len(changes) == 0 {
+		return ""
+	}
+
+	section := "\n\n### API changes\n\n"
+	for _, change := range changes {
+		prefix := ""
+		if change.Breaking {
+			prefix = "**BREAKING** "
+		}
+		section += fmt.Sprintf("- %s%s %s `%s` in `%s`\n", prefix, change.Change, change.Kind, change.Symbol, change.Filename)
+		if change.Change == "changed" {
+			section += "  ```diff\n" + diffLines("-", change.Before) + diffLines("+", change.After) + "  ```\n"
+		}
+	}
+	return section
+}
+
+// diffLines prefixes every line of code with a diff marker, indented to stay inside a list item
+func diffLines(marker, code string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(code, "\n") {
+		b.WriteString("  " + marker + " " + line + "\n")
+	}
+	return b.String()
+}
+
+// formatLines renders the line or range of lines a comment covers
+func formatLines(comment llm.ReviewComment) string {
+	if comment.StartLine > 0 && comment.StartLine < comment.Line {
+		return fmt.Sprintf("%d-%d", comment.StartLine, comment.Line)
+	}
+	return fmt.Sprintf("%d", comment.Line)
 }
 
 func formatReviewSummary(review *llm.CodeReviewResponse) string {
 	summary := "## AI Code Review\n\n"
-	summary += review.Summary + "\n\n"
+	summary += review.Summary + formatAPIChanges(review.APIChanges) + formatSkippedFiles(review.SkippedFiles) + formatAutoFix(review) + "\n\n"
 
 	if len(review.Comments) > 0 {
 		summary += "### Comments\n\n"
 		for _, comment := range review.Comments {
-			prefix := "[INFO]"
-			switch comment.Severity {
-			case "warning":
-				prefix = "[WARNING]"
-			case "error":
-				prefix = "[ERROR]"
-			}
-			summary += fmt.Sprintf("- %s **%s**:%d - %s\n", prefix, comment.Filename, comment.Line, comment.Body)
+			summary += formatCommentItem(comment)
 		}
 	}
 
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/carlr/codereviewtool/internal/queue"
+)
+
+// recordingQueue keeps published messages instead of delivering them
+type recordingQueue struct {
+	published [][]byte
+	options   []queue.PublishOptions
+}
+
+func (q *recordingQueue) Publish(event interface{}) error {
+	return q.PublishWith(event, queue.PublishOptions{})
+}
+
+func (q *recordingQueue) PublishWith(event interface{}, opts queue.PublishOptions) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	q.published = append(q.published, body)
+	q.options = append(q.options, opts)
+	return nil
+}
+
+func (q *recordingQueue) Consume(concurrency int, handler func([]byte) error) error { return nil }
+
+func (q *recordingQueue) Close() error { return nil }
+
+func TestRetryJob(t *testing.T) {
+	jobs := &recordingQueue{}
+	w := &worker{jobs: jobs}
+	failure := errors.New("GitHub is down")
+
+	// Jobs of newer versions are retried like any other failure
+	if err := w.retryJob([]byte(`{"version":99,"type":"review","attempt":1,"trace_id":"t1"}`), failure); err != nil {
+		t.Fatalf("Expected the retry to be queued, got %v", err)
+	}
+	if len(jobs.published) != 1 {
+		t.Fatalf("Expected 1 retry, got %d", len(jobs.published))
+	}
+	var retried struct {
+		Version int `json:"version"`
+		Attempt int `json:"attempt"`
+	}
+	json.Unmarshal(jobs.published[0], &retried)
+	if retried.Attempt != 2 || retried.Version != 99 {
+		t.Errorf("Expected attempt 2 of the version 99 job, got %s", jobs.published[0])
+	}
+	if jobs.options[0].Route != queue.RouteReview || jobs.options[0].Priority != queue.PriorityLow {
+		t.Errorf("Expected a low priority review, got %+v", jobs.options[0])
+	}
+
+	// The last attempt is dropped rather than queued again
+	last := []byte(`{"version":1,"type":"command","attempt":5,"trace_id":"t2"}`)
+	if err := w.retryJob(last, failure); err != nil {
+		t.Errorf("Expected the job to be dropped, got %v", err)
+	}
+	if err := w.retryJob([]byte("not json"), failure); err != nil {
+		t.Errorf("Expected a malformed job to be dropped, got %v", err)
+	}
+	if len(jobs.published) != 1 {
+		t.Errorf("Expected no further retries, got %d", len(jobs.published))
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/carlr/codereviewtool/internal/policy"
+	"github.com/carlr/codereviewtool/internal/webhook"
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+const (
+	// policyStatusContext names the commit status set by the merge policy
+	policyStatusContext = "ai-code-review/policy"
+	// maxStatusDescriptionLength is GitHub's limit on commit status descriptions
+	maxStatusDescriptionLength = 140
+)
+
+// startPolicyStatus marks the merge policy as pending while a review runs. It is
+// skipped when the result will be reported through a check run instead
+func (w *worker) startPolicyStatus(ctx context.Context, owner, repo, sha string, checkRunID int64) {
+	if !w.cfg.MergePolicyEnabled || checkRunID != 0 {
+		return
+	}
+	if err := w.github.SetCommitStatus(ctx, owner, repo, sha, policyStatusContext, "pending", "AI review in progress"); err != nil {
+		log.Printf("Failed to set pending policy status: %v", err)
+	}
+}
+
+// evaluatePolicy checks the review against the repository's merge policy. Labels
+// already on the pull request can override a failing result
+func (w *worker) evaluatePolicy(owner, repo string, labels []webhook.Label, review *llm.CodeReviewResponse) *policy.Decision {
+	if !w.cfg.MergePolicyEnabled {
+		return nil
+	}
+
+	p := w.policies.For(owner + "/" + repo)
+	decision := p.Evaluate(review)
+
+	names := make([]string, 0, len(labels))
+	for _, label := range labels {
+		names = append(names, label.Name)
+	}
+	if !decision.Passed && p.HasOverrideLabel(names) {
+		decision.Overridden = true
+		decision.OverriddenBy = "label " + p.OverrideLabel
+	}
+
+	log.Printf("Merge policy for %s/%s: %s", owner, repo, decision.Description())
+	return &decision
+}
+
+// reportPolicyStatus publishes the decision as a commit status when check runs
+// aren't carrying it
+func (w *worker) reportPolicyStatus(ctx context.Context, owner, repo, sha string, checkRunID int64, decision *policy.Decision) error {
+	if decision == nil || checkRunID != 0 {
+		return nil
+	}
+	return w.github.SetCommitStatus(ctx, owner, repo, sha, policyStatusContext, policyOutcome(decision), truncate(decision.Description(), maxStatusDescriptionLength))
+}
+
+// failPolicyStatus replaces a pending policy status when the review fails, so a
+// broken review doesn't leave the merge waiting forever
+func (w *worker) failPolicyStatus(ctx context.Context, owner, repo, sha string, checkRunID int64) {
+	if !w.cfg.MergePolicyEnabled || checkRunID != 0 {
+		return
+	}
+	if err := w.github.SetCommitStatus(ctx, owner, repo, sha, policyStatusContext, "error", "AI review failed and will be retried"); err != nil {
+		log.Printf("Failed to set policy status: %v", err)
+	}
+}
+
+// cancelPolicyStatus replaces a pending policy status when the review is cancelled
+// because the pull request was updated or closed
+func (w *worker) cancelPolicyStatus(ctx context.Context, owner, repo, sha string, checkRunID int64) {
+	if !w.cfg.MergePolicyEnabled || checkRunID != 0 {
+		return
+	}
+	if err := w.github.SetCommitStatus(ctx, owner, repo, sha, policyStatusContext, "error", "AI review cancelled: the pull request was updated or closed"); err != nil {
+		log.Printf("Failed to set policy status: %v", err)
+	}
+}
+
+// handleLabeled overrides a failing policy when a maintainer applies the override label
+func (w *worker) handleLabeled(ctx context.Context, event *webhook.GitHubPullRequestEvent) error {
+	owner, repo := event.Repository.Owner.Login, event.Repository.Name
+	if !w.cfg.MergePolicyEnabled || event.Label == nil {
+		return nil
+	}
+
+	p := w.policies.For(owner + "/" + repo)
+	if !p.HasOverrideLabel([]string{event.Label.Name}) {
+		log.Printf("Ignoring label %q on PR #%d", event.Label.Name, event.PullRequest.Number)
+		return nil
+	}
+
+	// Applying labels already requires triage access, so no further permission check is needed
+	return w.overridePolicy(ctx, owner, repo, event.PullRequest.Number, event.PullRequest.Head.Sha, "@"+event.Sender.Login+" (label)")
+}
+
+// handleCommand runs slash commands posted as PR comments
+func (w *worker) handleCommand(ctx context.Context, event *webhook.GitHubPullRequestEvent) error {
+	owner, repo, prNumber := event.Repository.Owner.Login, event.Repository.Name, event.PullRequest.Number
+	if event.Comment == nil {
+		return nil
+	}
+
+	p := w.policies.For(owner + "/" + repo)
+	if !w.cfg.MergePolicyEnabled || !p.IsOverrideCommand(event.Comment.Body) {
+		log.Printf("Ignoring unknown command on PR #%d", prNumber)
+		return nil
+	}
+
+	// Anyone can comment, so only honor overrides from users who could merge anyway
+	permission, err := w.github.GetPermissionLevel(ctx, owner, repo, event.Sender.Login)
+	if err != nil {
+		return err
+	}
+	if permission != "admin" && permission != "write" {
+		log.Printf("Ignoring override from %s on PR #%d: permission %s", event.Sender.Login, prNumber, permission)
+		return w.github.PostReviewSummary(ctx, owner, repo, prNumber,
+			fmt.Sprintf("@%s only maintainers with write access can override the AI review merge policy.", event.Sender.Login))
+	}
+
+	sha, err := w.github.GetPullRequestHead(ctx, owner, repo, prNumber)
+	if err != nil {
+		return err
+	}
+	return w.overridePolicy(ctx, owner, repo, prNumber, sha, "@"+event.Sender.Login)
+}
+
+// overridePolicy marks the policy as passing for the given commit and records who overrode it
+func (w *worker) overridePolicy(ctx context.Context, owner, repo string, prNumber int, sha, by string) error {
+	decision := &policy.Decision{Passed: true, Overridden: true, OverriddenBy: by}
+	log.Printf("Merge policy for PR #%d overridden by %s", prNumber, by)
+
+	// A new check run with the same name supersedes the failing one
+	checkRunID := w.startCheckRun(ctx, owner, repo, sha)
+	if checkRunID != 0 {
+		review := &llm.CodeReviewResponse{Summary: decision.Description() + "."}
+		if err := w.completeCheckRun(ctx, owner, repo, checkRunID, review, decision); err != nil {
+			return err
+		}
+	} else if err := w.reportPolicyStatus(ctx, owner, repo, sha, 0, decision); err != nil {
+		return err
+	}
+
+	return w.github.PostReviewSummary(ctx, owner, repo, prNumber,
+		fmt.Sprintf("AI review merge policy overridden by %s.", by))
+}
+
+// policyOutcome maps a decision onto a commit status state, which uses the same
+// values as a check run conclusion
+func policyOutcome(decision *policy.Decision) string {
+	if decision.Passed || decision.Overridden {
+		return "success"
+	}
+	return "failure"
+}
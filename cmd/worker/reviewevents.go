@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/carlr/codereviewtool/internal/policy"
+	"github.com/carlr/codereviewtool/internal/scm"
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+// reviewMarker is hidden in the body of every review the bot submits so it can
+// find its own reviews again, whichever account the token belongs to
+const reviewMarker = "<!-- ai-code-review -->"
+
+// reviewEvent picks the review event configured for the review's most severe
+// finding. A response that couldn't be parsed has no findings but reviewed
+// nothing, so it is only ever posted as a comment
+func (w *worker) reviewEvent(review *llm.CodeReviewResponse) string {
+	if review.Unparsed {
+		return scm.ReviewEventComment
+	}
+
+	var event string
+	switch review.HighestSeverity() {
+	case "error":
+		event = w.cfg.ReviewEventOnError
+	case "warning":
+		event = w.cfg.ReviewEventOnWarning
+	default:
+		event = w.cfg.ReviewEventOnClean
+	}
+
+	// Files left out of the prompt weren't reviewed, so they can't be approved
+	if event == scm.ReviewEventApprove && (len(review.SkippedFiles) > 0 || !approvable(w.cfg.ReviewApprovePaths, review.ChangedFiles)) {
+		return scm.ReviewEventComment
+	}
+	if event == "" {
+		return scm.ReviewEventComment
+	}
+	return event
+}
+
+// approvable reports whether every changed file matches one of the patterns; no
+// patterns or no files never approve
+func approvable(patterns, files []string) bool {
+	if len(patterns) == 0 || len(files) == 0 {
+		return false
+	}
+	for _, file := range files {
+		matched := false
+		for _, pattern := range patterns {
+			if policy.MatchPath(pattern, file) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// dismissResolvedReviews dismisses the bot's earlier change requests once a later
+// review no longer requests changes. A response that couldn't be parsed doesn't
+// show that anything was resolved
+func (w *worker) dismissResolvedReviews(ctx context.Context, owner, repo string, prNumber int, commitID, event string, review *llm.CodeReviewResponse) {
+	if event == scm.ReviewEventRequestChanges || review.Unparsed {
+		return
+	}
+
+	message := fmt.Sprintf("Resolved as of %s", commitID)
+	dismissed, err := w.github.DismissReviews(ctx, owner, repo, prNumber, reviewMarker, message)
+	if err != nil {
+		log.Printf("Failed to dismiss earlier reviews: %v", err)
+		return
+	}
+	if dismissed > 0 {
+		log.Printf("Dismissed %d earlier change requests on PR #%d", dismissed, prNumber)
+	}
+}
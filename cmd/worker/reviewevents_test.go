@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/carlr/codereviewtool/internal/config"
+	"github.com/carlr/codereviewtool/internal/scm"
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+func TestReviewEvent(t *testing.T) {
+	w := &worker{cfg: &config.Config{
+		ReviewEventOnError:   scm.ReviewEventRequestChanges,
+		ReviewEventOnWarning: scm.ReviewEventComment,
+		ReviewEventOnClean:   scm.ReviewEventApprove,
+		ReviewApprovePaths:   []string{"docs/**"},
+	}}
+	errorComment := []llm.ReviewComment{{Severity: "error"}}
+	warningComment := []llm.ReviewComment{{Severity: "warning"}}
+
+	tests := []struct {
+		name     string
+		review   llm.CodeReviewResponse
+		expected string
+	}{
+		{"error", llm.CodeReviewResponse{Comments: errorComment, ChangedFiles: []string{"main.go"}}, scm.ReviewEventRequestChanges},
+		{"warning", llm.CodeReviewResponse{Comments: warningComment, ChangedFiles: []string{"docs/a.md"}}, scm.ReviewEventComment},
+		{"clean docs", llm.CodeReviewResponse{ChangedFiles: []string{"docs/a.md"}}, scm.ReviewEventApprove},
+		{"clean code", llm.CodeReviewResponse{ChangedFiles: []string{"docs/a.md", "main.go"}}, scm.ReviewEventComment},
+		{"skipped files", llm.CodeReviewResponse{ChangedFiles: []string{"docs/a.md"}, SkippedFiles: []llm.SkippedFile{{Filename: "docs/big.md"}}}, scm.ReviewEventComment},
+		{"unparsed", llm.CodeReviewResponse{ChangedFiles: []string{"docs/a.md"}, Unparsed: true}, scm.ReviewEventComment},
+		{"unparsed with errors", llm.CodeReviewResponse{Comments: errorComment, Unparsed: true}, scm.ReviewEventComment},
+	}
+	for _, tt := range tests {
+		if got := w.reviewEvent(&tt.review); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestApprovable(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		files    []string
+		expected bool
+	}{
+		{nil, []string{"docs/a.md"}, false},
+		{[]string{"docs/**"}, nil, false},
+		{[]string{"docs/**"}, []string{"docs/a.md", "docs/b/c.md"}, true},
+		{[]string{"docs/**"}, []string{"docs/a.md", "main.go"}, false},
+		{[]string{"docs/**", "*.md"}, []string{"README.md", "docs/a.md"}, true},
+	}
+	for _, tt := range tests {
+		if got := approvable(tt.patterns, tt.files); got != tt.expected {
+			t.Errorf("approvable(%v, %v): expected %v, got %v", tt.patterns, tt.files, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/carlr/codereviewtool/internal/sarif"
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+// sarifToolName identifies this tool in SARIF logs and code scanning alerts
+const sarifToolName = "AI Code Review"
+
+// exportSARIF writes the review as a SARIF log to the configured directory and/or
+// uploads it to GitHub code scanning
+func (w *worker) exportSARIF(ctx context.Context, owner, repo string, prNumber int, commitID string, review *llm.CodeReviewResponse) error {
+	if w.cfg.SARIFOutputDir == "" && !w.cfg.SARIFUpload {
+		return nil
+	}
+
+	data, err := sarif.Marshal(sarif.FromReview(review, sarifToolName, ""))
+	if err != nil {
+		return fmt.Errorf("failed to encode SARIF: %w", err)
+	}
+
+	if w.cfg.SARIFOutputDir != "" {
+		if err := os.MkdirAll(w.cfg.SARIFOutputDir, 0o755); err != nil {
+			return fmt.Errorf("failed to create SARIF directory: %w", err)
+		}
+		shortSHA := commitID
+		if len(shortSHA) > 7 {
+			shortSHA = shortSHA[:7]
+		}
+		path := filepath.Join(w.cfg.SARIFOutputDir, fmt.Sprintf("%s-%s-pr%d-%s.sarif", owner, repo, prNumber, shortSHA))
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return fmt.Errorf("failed to write SARIF: %w", err)
+		}
+		log.Printf("Wrote SARIF log to %s", path)
+	}
+
+	if w.cfg.SARIFUpload {
+		ref := fmt.Sprintf("refs/pull/%d/head", prNumber)
+		if err := w.github.UploadSARIF(ctx, owner, repo, commitID, ref, data); err != nil {
+			return err
+		}
+		log.Printf("Uploaded SARIF for PR #%d to code scanning", prNumber)
+	}
+
+	return nil
+}
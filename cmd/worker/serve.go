@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/carlr/codereviewtool/internal/config"
+	"github.com/carlr/codereviewtool/internal/dedup"
+	"github.com/carlr/codereviewtool/internal/queue"
+	"github.com/carlr/codereviewtool/internal/webhook"
+	"github.com/gorilla/mux"
+)
+
+// serveWebhooks runs the webhook listener in the worker's process, publishing
+// to the worker's queue and sharing its deduplication store
+func serveWebhooks(cfg *config.Config, jobs queue.Queue, store dedup.Store) {
+	webhookHandler := webhook.NewHandler(cfg.GitHubWebhookSecret, jobs)
+	webhookHandler.SetPriorities(webhook.PriorityRules{
+		Branches:     cfg.PriorityBranches,
+		Labels:       cfg.PriorityLabels,
+		SmallDiff:    cfg.PrioritySmallDiff,
+		TriggerLabel: cfg.ReviewTriggerLabel,
+	})
+	if store != nil {
+		webhookHandler.SetStore(store)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/webhook/github", webhookHandler.HandleGitHub).Methods("POST")
+	router.HandleFunc("/health", healthHandler).Methods("GET")
+
+	addr := fmt.Sprintf(":%s", cfg.WebhookPort)
+	log.Printf("Webhook listener starting on %s", addr)
+	log.Printf("GitHub webhook endpoint: http://localhost%s/webhook/github", addr)
+
+	if err := http.ListenAndServe(addr, router); err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+}
+
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "OK")
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"log"
+
+	"github.com/carlr/codereviewtool/internal/policy"
+	"github.com/carlr/codereviewtool/internal/webhook"
+)
+
+// isTriggerLabel reports whether a labeled event applied the review trigger label
+func (w *worker) isTriggerLabel(event *webhook.GitHubPullRequestEvent) bool {
+	return w.cfg.ReviewTriggerLabel != "" && event.Label != nil && policy.LabelsEqual(event.Label.Name, w.cfg.ReviewTriggerLabel)
+}
+
+// shouldReview decides whether a pull request event starts a review
+func (w *worker) shouldReview(event *webhook.GitHubPullRequestEvent) bool {
+	prNumber := event.PullRequest.Number
+	if w.hasLabel(event, w.cfg.ReviewSkipLabel) {
+		log.Printf("Skipping PR #%d: labeled %q", prNumber, w.cfg.ReviewSkipLabel)
+		return false
+	}
+	// The trigger label is an explicit request, so it reviews drafts too
+	if event.Action == "labeled" {
+		return w.isTriggerLabel(event)
+	}
+	if event.Action == webhook.ActionRequested {
+		return true
+	}
+	if event.PullRequest.Draft && !w.cfg.ReviewDrafts {
+		log.Printf("Skipping draft PR #%d until it is ready for review", prNumber)
+		return false
+	}
+	return true
+}
+
+// hasLabel reports whether the pull request carries the named label
+func (w *worker) hasLabel(event *webhook.GitHubPullRequestEvent, name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, label := range event.PullRequest.Labels {
+		if policy.LabelsEqual(label.Name, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/carlr/codereviewtool/internal/config"
+	"github.com/carlr/codereviewtool/internal/webhook"
+)
+
+func TestShouldReview(t *testing.T) {
+	w := &worker{cfg: &config.Config{ReviewTriggerLabel: "ai-review", ReviewSkipLabel: "skip-ai-review"}}
+
+	event := func(action string, draft bool, label string, labels ...string) *webhook.GitHubPullRequestEvent {
+		e := &webhook.GitHubPullRequestEvent{Action: action}
+		e.PullRequest.Draft = draft
+		if label != "" {
+			e.Label = &webhook.Label{Name: label}
+		}
+		for _, name := range labels {
+			e.PullRequest.Labels = append(e.PullRequest.Labels, webhook.Label{Name: name})
+		}
+		return e
+	}
+
+	tests := []struct {
+		name     string
+		event    *webhook.GitHubPullRequestEvent
+		expected bool
+	}{
+		{"opened", event("opened", false, ""), true},
+		{"draft", event("synchronize", true, ""), false},
+		{"requested draft", event(webhook.ActionRequested, true, ""), true},
+		{"trigger label on draft", event("labeled", true, "ai-review", "ai-review"), true},
+		{"other label", event("labeled", false, "bug", "bug"), false},
+		{"skip label", event("opened", false, "", "skip-ai-review"), false},
+		{"skip label in other case", event("opened", false, "", "Skip-AI-Review"), false},
+		{"trigger label in other case", event("labeled", false, "AI-Review", "AI-Review"), true},
+		{"skip label beats request", event(webhook.ActionRequested, false, "", "skip-ai-review"), false},
+	}
+	for _, tt := range tests {
+		if got := w.shouldReview(tt.event); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+
+	w.cfg.ReviewDrafts = true
+	if !w.shouldReview(event("synchronize", true, "")) {
+		t.Error("Expected drafts to be reviewed with REVIEW_DRAFTS")
+	}
+}
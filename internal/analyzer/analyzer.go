@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
+	"github.com/carlr/codereviewtool/internal/lint"
 	"github.com/carlr/codereviewtool/internal/scm"
 	"github.com/carlr/codereviewtool/pkg/llm"
 )
@@ -14,24 +17,70 @@ import (
 type Analyzer struct {
 	llmProvider  llm.Provider
 	githubClient *scm.GitHubClient
+	options      Options
+}
+
+// Options controls the optional analysis stages
+type Options struct {
+	// ContextTokenBudget is the approximate number of tokens of surrounding code added
+	// to the prompt; zero disables context retrieval
+	ContextTokenBudget int
+	// GoAPIAnalysis reports changes to the exported API of Go packages
+	GoAPIAnalysis bool
+	// Linters are run on a checkout of the PR head; none disables static analysis
+	Linters []lint.Linter
+	// StaticAnalysisTimeout bounds the checkout and all linter runs
+	StaticAnalysisTimeout time.Duration
+	// AutoFixCategories are the comment categories AutoFix attempts to fix, in
+	// addition to comments that carry a suggestion
+	AutoFixCategories []string
+	// AutoFixTestCommand is run after fixes are applied; empty skips tests
+	AutoFixTestCommand []string
+	// AutoFixTimeout bounds the checkout, fix and validation of AutoFix
+	AutoFixTimeout time.Duration
+}
+
+// PullRequest identifies the pull request under review
+type PullRequest struct {
+	Owner       string
+	Repo        string
+	Number      int
+	Title       string
+	Description string
+	Author      string
+	HeadSHA     string
+	BaseSHA     string
+}
+
+// fullName returns the owner/repo name of the repository, or just the repository
+// name for local changes, which have no owner
+func (pr PullRequest) fullName() string {
+	if pr.Owner == "" {
+		return pr.Repo
+	}
+	return pr.Owner + "/" + pr.Repo
 }
 
 // NewAnalyzer creates a new code analyzer
-func NewAnalyzer(llmProvider llm.Provider, githubClient *scm.GitHubClient) *Analyzer {
+func NewAnalyzer(llmProvider llm.Provider, githubClient *scm.GitHubClient, options Options) *Analyzer {
 	return &Analyzer{
 		llmProvider:  llmProvider,
 		githubClient: githubClient,
+		options:      options,
 	}
 }
 
 // AnalyzePullRequest analyzes a pull request and returns review feedback
-func (a *Analyzer) AnalyzePullRequest(ctx context.Context, owner, repo string, prNumber int, title, description, author string) (*llm.CodeReviewResponse, error) {
+func (a *Analyzer) AnalyzePullRequest(ctx context.Context, pr PullRequest) (*llm.CodeReviewResponse, error) {
+	owner, repo, prNumber := pr.Owner, pr.Repo, pr.Number
 	log.Printf("Analyzing PR #%d in %s/%s", prNumber, owner, repo)
 
-	// Get PR diff
+	// Get PR diff; GitHub refuses very large diffs, in which case it is rebuilt
+	// from the per-file patches below
 	diff, err := a.githubClient.GetPullRequestDiff(ctx, owner, repo, prNumber)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get PR diff: %w", err)
+		log.Printf("Failed to get PR diff, using per-file patches: %v", err)
+		diff = ""
 	}
 
 	// Get changed files
@@ -44,25 +93,87 @@ func (a *Analyzer) AnalyzePullRequest(ctx context.Context, owner, repo string, p
 	fileChanges := make([]llm.FileChange, 0, len(files))
 	for _, file := range files {
 		fc := llm.FileChange{
-			Filename:  file.GetFilename(),
-			Status:    file.GetStatus(),
-			Additions: file.GetAdditions(),
-			Deletions: file.GetDeletions(),
-			Changes:   file.GetChanges(),
-			Patch:     file.GetPatch(),
+			Filename:         file.GetFilename(),
+			PreviousFilename: file.GetPreviousFilename(),
+			Status:           file.GetStatus(),
+			Additions:        file.GetAdditions(),
+			Deletions:        file.GetDeletions(),
+			Changes:          file.GetChanges(),
+			Patch:            file.GetPatch(),
 		}
 		fileChanges = append(fileChanges, fc)
 	}
 
+	fetcher := newCachingFetcher(a.githubClient)
+	checkout := func(ctx context.Context) (string, func(), error) {
+		ws, err := a.githubClient.Checkout(ctx, pr.Owner, pr.Repo, pr.HeadSHA)
+		if err != nil {
+			return "", nil, err
+		}
+		return ws.Dir, func() { ws.Close() }, nil
+	}
+	return a.review(ctx, pr, fetcher, checkout, diff, fileChanges)
+}
+
+// checkoutFunc provides a directory holding the head of the change under review,
+// and a function to clean it up once linters are done with it
+type checkoutFunc func(ctx context.Context) (dir string, cleanup func(), err error)
+
+// review asks the model to review a change, given its diff (possibly empty) and
+// changed files, and post-processes the comments. Files are read with fetcher at
+// pr.HeadSHA and linters run in the directory returned by checkout
+func (a *Analyzer) review(ctx context.Context, pr PullRequest, fetcher fileFetcher, checkout checkoutFunc, diff string, fileChanges []llm.FileChange) (*llm.CodeReviewResponse, error) {
+	// Fill in patches GitHub left out for binary and very large files
+	fullFiles, skippedFiles := completePatches(ctx, fetcher, pr, fileChanges)
+	if diff == "" {
+		diff = diffFromPatches(fileChanges)
+	} else {
+		for _, change := range fileChanges {
+			if change.Status == "added" && change.Patch != "" && !strings.Contains(diff, "+++ b/"+change.Filename+"\n") {
+				diff += fileDiff(change)
+			}
+		}
+	}
+	if len(skippedFiles) > 0 {
+		log.Printf("Skipping %d files that can't be reviewed", len(skippedFiles))
+	}
+
 	// Build review request
 	request := llm.CodeReviewRequest{
-		RepositoryName: fmt.Sprintf("%s/%s", owner, repo),
-		PullRequestID:  prNumber,
+		RepositoryName: pr.fullName(),
+		PullRequestID:  pr.Number,
 		Diff:           diff,
 		FileChanges:    fileChanges,
-		Author:         author,
-		Title:          title,
-		Description:    description,
+		Author:         pr.Author,
+		Title:          pr.Title,
+		Description:    pr.Description,
+		SkippedFiles:   skippedFiles,
+	}
+
+	// Add surrounding code so the model can see what the hunks belong to
+	request.Context = append(fullFiles, buildContext(ctx, fetcher, pr, fileChanges, a.options.ContextTokenBudget)...)
+	if len(request.Context) > 0 {
+		log.Printf("Added %d context snippets to the prompt", len(request.Context))
+	}
+
+	// Summarize changes to exported Go declarations
+	if a.options.GoAPIAnalysis {
+		request.APIChanges = analyzeGoAPI(ctx, fetcher, pr, fileChanges)
+		if len(request.APIChanges) > 0 {
+			log.Printf("Detected %d exported API changes", len(request.APIChanges))
+		}
+	}
+
+	// Run linters so the model can explain their findings instead of rediscovering them
+	if len(a.options.Linters) > 0 {
+		findings, err := a.runStaticAnalysis(ctx, checkout, fileChanges)
+		if err != nil {
+			log.Printf("Static analysis failed, continuing without it: %v", err)
+		}
+		request.StaticFindings = findings
+		if len(findings) > 0 {
+			log.Printf("Static analysis reported %d findings on changed lines", len(findings))
+		}
 	}
 
 	// Build prompt
@@ -89,10 +200,31 @@ func (a *Analyzer) AnalyzePullRequest(ctx context.Context, owner, repo string, p
 			reviewResponse = llm.CodeReviewResponse{
 				Summary:  response,
 				Comments: []llm.ReviewComment{},
+				Unparsed: true,
 			}
 		}
 	}
 
+	// Route comments GitHub can't attach to the diff, and pin ranges it would
+	// reject to a single line
+	normalizeScopes(fileChanges, &reviewResponse)
+	normalizeRanges(fileChanges, &reviewResponse)
+
+	// Only keep suggested changes GitHub can apply to the head of the PR
+	validateSuggestions(ctx, fetcher, pr, fileChanges, &reviewResponse)
+
+	reviewResponse.APIChanges = request.APIChanges
+	reviewResponse.SkippedFiles = skippedFiles
+	for _, change := range fileChanges {
+		reviewResponse.ChangedFiles = append(reviewResponse.ChangedFiles, change.Filename)
+		// A file renamed out of a path changes that path too
+		if change.PreviousFilename != "" && change.PreviousFilename != change.Filename {
+			reviewResponse.ChangedFiles = append(reviewResponse.ChangedFiles, change.PreviousFilename)
+		}
+	}
+	mergeStaticFindings(&reviewResponse, request.StaticFindings)
+	attachSnippets(ctx, fetcher, pr, &reviewResponse)
+
 	return &reviewResponse, nil
 }
 
@@ -0,0 +1,336 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/carlr/codereviewtool/internal/workspace"
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+const (
+	autoFixAuthorName  = "AI Code Review"
+	autoFixAuthorEmail = "ai-code-review@users.noreply.github.com"
+)
+
+// AutoFixResult describes a branch pushed by AutoFix
+type AutoFixResult struct {
+	Branch  string
+	Summary string
+	Files   []string
+	Fixed   []llm.ReviewComment // Comments the fixes address
+}
+
+// AutoFix asks the model to fix mechanical issues found by a review, validates the
+// fixes on a checkout of the PR head and pushes them to a new branch. It returns
+// nil if there was nothing to fix
+func (a *Analyzer) AutoFix(ctx context.Context, pr PullRequest, review *llm.CodeReviewResponse, branch string) (*AutoFixResult, error) {
+	issues := fixableComments(review, a.options.AutoFixCategories)
+	if len(issues) == 0 {
+		return nil, nil
+	}
+
+	if a.options.AutoFixTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.options.AutoFixTimeout)
+		defer cancel()
+	}
+
+	ws, err := a.githubClient.Checkout(ctx, pr.Owner, pr.Repo, pr.HeadSHA)
+	if err != nil {
+		return nil, err
+	}
+	defer ws.Close()
+
+	request := llm.FixRequest{
+		RepositoryName: fmt.Sprintf("%s/%s", pr.Owner, pr.Repo),
+		PullRequestID:  pr.Number,
+		Title:          pr.Title,
+		Issues:         issues,
+	}
+	files := make(map[string]string)
+	for _, issue := range issues {
+		if _, ok := files[issue.Filename]; ok {
+			continue
+		}
+		content, err := readWorkspaceFile(ws, issue.Filename)
+		if err != nil {
+			return nil, err
+		}
+		files[issue.Filename] = content
+		request.Files = append(request.Files, llm.SourceFile{Filename: issue.Filename, Content: content})
+	}
+
+	response, err := a.llmProvider.Analyze(ctx, llm.BuildFixPrompt(request))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fixes from LLM: %w", err)
+	}
+	var fix llm.FixResponse
+	if err := json.Unmarshal([]byte(response), &fix); err != nil {
+		if err := json.Unmarshal([]byte(extractJSONFromMarkdown(response)), &fix); err != nil {
+			return nil, fmt.Errorf("failed to parse fixes: %w", err)
+		}
+	}
+	if len(fix.Edits) == 0 {
+		log.Printf("No fixes proposed for PR #%d", pr.Number)
+		return nil, nil
+	}
+
+	changed, err := applyEdits(files, fix.Edits)
+	if err != nil {
+		return nil, err
+	}
+	if err := formatGoFiles(files, changed); err != nil {
+		return nil, err
+	}
+	if err := writeWorkspaceFiles(ws, files, changed); err != nil {
+		return nil, err
+	}
+
+	if err := a.validateFixes(ctx, ws, changed); err != nil {
+		return nil, err
+	}
+
+	// Validation ran the repository's code, which may have tampered with the
+	// checkout, its hooks or its git config, so the fixes are committed from a
+	// fresh checkout before the token is used to push
+	clean, err := a.githubClient.Checkout(ctx, pr.Owner, pr.Repo, pr.HeadSHA)
+	if err != nil {
+		return nil, err
+	}
+	defer clean.Close()
+	if err := writeWorkspaceFiles(clean, files, changed); err != nil {
+		return nil, err
+	}
+
+	summary := fix.Summary
+	if summary == "" {
+		summary = fmt.Sprintf("Fix review findings in #%d", pr.Number)
+	}
+	if err := commitAndPush(ctx, clean, branch, summary, changed); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Pushed %d fixed files to %s", len(changed), branch)
+	return &AutoFixResult{
+		Branch:  branch,
+		Summary: summary,
+		Files:   changed,
+		Fixed:   commentsInFiles(issues, changed),
+	}, nil
+}
+
+// fixableComments returns the located comments that have a suggestion or belong
+// to one of the given categories
+func fixableComments(review *llm.CodeReviewResponse, categories []string) []llm.ReviewComment {
+	wanted := make(map[string]bool, len(categories))
+	for _, category := range categories {
+		wanted[strings.ToLower(strings.TrimSpace(category))] = true
+	}
+
+	var fixable []llm.ReviewComment
+	for _, c := range review.Comments {
+		if c.Filename == "" || c.Line <= 0 {
+			continue
+		}
+		if c.Suggestion != nil || wanted[strings.ToLower(c.Category)] {
+			fixable = append(fixable, c)
+		}
+	}
+	return fixable
+}
+
+// applyEdits applies line edits to the given file contents in place and returns the
+// names of the files that changed. Edits must reference known files, repeat the
+// current text of the lines they replace, and not overlap
+func applyEdits(files map[string]string, edits []llm.FixEdit) ([]string, error) {
+	byFile := make(map[string][]llm.FixEdit)
+	for _, edit := range edits {
+		if _, ok := files[edit.Filename]; !ok {
+			return nil, fmt.Errorf("fix edits unexpected file %s", edit.Filename)
+		}
+		if edit.EndLine == 0 {
+			edit.EndLine = edit.StartLine
+		}
+		byFile[edit.Filename] = append(byFile[edit.Filename], edit)
+	}
+
+	var changed []string
+	for filename, fileEdits := range byFile {
+		// Apply from the bottom up so earlier line numbers stay valid
+		sort.Slice(fileEdits, func(i, j int) bool { return fileEdits[i].StartLine > fileEdits[j].StartLine })
+
+		lines := strings.Split(files[filename], "\n")
+		next := len(lines) + 1
+		for _, edit := range fileEdits {
+			if edit.StartLine < 1 || edit.EndLine < edit.StartLine || edit.EndLine > len(lines) {
+				return nil, fmt.Errorf("fix for %s has invalid lines %d-%d", filename, edit.StartLine, edit.EndLine)
+			}
+			if edit.EndLine >= next {
+				return nil, fmt.Errorf("fixes for %s overlap at line %d", filename, edit.EndLine)
+			}
+			current := strings.Join(lines[edit.StartLine-1:edit.EndLine], "\n")
+			if normalizeCode(edit.Original) != normalizeCode(current) {
+				return nil, fmt.Errorf("fix for %s:%d doesn't match the file", filename, edit.StartLine)
+			}
+
+			var replacement []string
+			if edit.Replacement != "" {
+				replacement = strings.Split(strings.TrimSuffix(edit.Replacement, "\n"), "\n")
+			}
+			lines = append(lines[:edit.StartLine-1], append(replacement, lines[edit.EndLine:]...)...)
+			next = edit.StartLine
+		}
+
+		updated := strings.Join(lines, "\n")
+		if updated != files[filename] {
+			files[filename] = updated
+			changed = append(changed, filename)
+		}
+	}
+
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// formatGoFiles formats the changed Go files in place, as gofmt would
+func formatGoFiles(files map[string]string, changed []string) error {
+	for _, filename := range changed {
+		if !strings.HasSuffix(filename, ".go") {
+			continue
+		}
+		formatted, err := format.Source([]byte(files[filename]))
+		if err != nil {
+			return fmt.Errorf("fix validation failed: gofmt %s: %w", filename, err)
+		}
+		files[filename] = string(formatted)
+	}
+	return nil
+}
+
+// writeWorkspaceFiles writes the changed files to the workspace
+func writeWorkspaceFiles(ws *workspace.Workspace, files map[string]string, changed []string) error {
+	for _, filename := range changed {
+		path, err := workspaceFilePath(ws, filename)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(files[filename]), 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", filename, err)
+		}
+	}
+	return nil
+}
+
+// validateFixes makes sure the code still builds and, if configured, passes its
+// tests. Both run the repository's code, with the environment of ws.Run
+func (a *Analyzer) validateFixes(ctx context.Context, ws *workspace.Workspace, changed []string) error {
+	goChanged := false
+	for _, filename := range changed {
+		goChanged = goChanged || strings.HasSuffix(filename, ".go")
+	}
+
+	if goChanged {
+		if _, err := os.Stat(filepath.Join(ws.Dir, "go.mod")); err == nil {
+			if err := runCheck(ctx, ws, []string{"go", "build", "./..."}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(a.options.AutoFixTestCommand) > 0 {
+		return runCheck(ctx, ws, a.options.AutoFixTestCommand)
+	}
+	return nil
+}
+
+// runCheck runs a validation command in the workspace, failing on a non-zero exit
+func runCheck(ctx context.Context, ws *workspace.Workspace, args []string) error {
+	stdout, stderr, err := ws.Run(ctx, args[0], args[1:]...)
+	if err != nil {
+		output := strings.TrimSpace(string(stderr))
+		if output == "" {
+			output = strings.TrimSpace(string(stdout))
+		}
+		return fmt.Errorf("fix validation failed: %s: %w: %s", strings.Join(args, " "), err, output)
+	}
+	return nil
+}
+
+// commitAndPush commits the changed files to a new branch and pushes it to origin.
+// Hooks are disabled so no repository code runs while the token is in use
+func commitAndPush(ctx context.Context, ws *workspace.Workspace, branch, message string, files []string) error {
+	steps := [][]string{
+		{"checkout", "--quiet", "-b", branch},
+		append([]string{"add", "--"}, files...),
+		{"-c", "user.name=" + autoFixAuthorName, "-c", "user.email=" + autoFixAuthorEmail, "commit", "--quiet", "--no-verify", "-m", message},
+		{"push", "--quiet", "--no-verify", "origin", "HEAD:refs/heads/" + branch},
+	}
+	for _, args := range steps {
+		args = append([]string{"-c", "core.hooksPath=/dev/null"}, args...)
+		if _, err := ws.Git(ctx, args...); err != nil {
+			return fmt.Errorf("failed to push fixes: %w", err)
+		}
+	}
+	return nil
+}
+
+// readWorkspaceFile reads a repository-relative file from the workspace
+func readWorkspaceFile(ws *workspace.Workspace, filename string) (string, error) {
+	path, err := workspaceFilePath(ws, filename)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	return string(data), nil
+}
+
+// workspaceFilePath returns the path of a regular file in the checkout. The pull
+// request controls the checkout, so symlinks, which could point outside of it, are
+// rejected anywhere along the path
+func workspaceFilePath(ws *workspace.Workspace, filename string) (string, error) {
+	rel := filepath.FromSlash(filename)
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("invalid file path %s", filename)
+	}
+
+	path := ws.Dir
+	parts := strings.Split(rel, string(filepath.Separator))
+	for i, part := range parts {
+		path = filepath.Join(path, part)
+		info, err := os.Lstat(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", filename, err)
+		}
+		last := i == len(parts)-1
+		if info.Mode()&os.ModeSymlink != 0 || (last && !info.Mode().IsRegular()) || (!last && !info.IsDir()) {
+			return "", fmt.Errorf("%s is not a regular file in the checkout", filename)
+		}
+	}
+	return path, nil
+}
+
+// commentsInFiles returns the comments on any of the given files
+func commentsInFiles(comments []llm.ReviewComment, files []string) []llm.ReviewComment {
+	set := make(map[string]bool, len(files))
+	for _, f := range files {
+		set[f] = true
+	}
+	var kept []llm.ReviewComment
+	for _, c := range comments {
+		if set[c.Filename] {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
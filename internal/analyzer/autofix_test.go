@@ -0,0 +1,115 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/carlr/codereviewtool/internal/workspace"
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+func TestFixableComments(t *testing.T) {
+	review := &llm.CodeReviewResponse{Comments: []llm.ReviewComment{
+		{Filename: "a.go", Line: 1, Category: "Style"},
+		{Filename: "a.go", Line: 2, Category: "bug"},
+		{Filename: "a.go", Line: 3, Category: "bug", Suggestion: &llm.Suggestion{StartLine: 3, EndLine: 3}},
+		{Filename: "", Line: 0, Category: "style"},
+	}}
+
+	fixable := fixableComments(review, []string{"style"})
+	if len(fixable) != 2 {
+		t.Fatalf("Expected 2 fixable comments, got %d", len(fixable))
+	}
+	if fixable[0].Line != 1 || fixable[1].Line != 3 {
+		t.Errorf("Expected lines 1 and 3, got %d and %d", fixable[0].Line, fixable[1].Line)
+	}
+}
+
+func TestApplyEdits(t *testing.T) {
+	files := map[string]string{
+		"a.go": "package a\n\nfunc A() {\n\tx := 1\n\t_ = x\n}\n",
+		"b.go": "package a\n",
+	}
+	edits := []llm.FixEdit{
+		{Filename: "a.go", StartLine: 4, EndLine: 5, Original: "\tx := 1\n\t_ = x", Replacement: "\t_ = 1"},
+		{Filename: "a.go", StartLine: 1, Original: "package a", Replacement: "package b"},
+	}
+
+	changed, err := applyEdits(files, edits)
+	if err != nil {
+		t.Fatalf("applyEdits() failed: %v", err)
+	}
+	if len(changed) != 1 || changed[0] != "a.go" {
+		t.Errorf("Expected only a.go to change, got %v", changed)
+	}
+	expected := "package b\n\nfunc A() {\n\t_ = 1\n}\n"
+	if files["a.go"] != expected {
+		t.Errorf("Expected %q, got %q", expected, files["a.go"])
+	}
+}
+
+func TestFormatGoFiles(t *testing.T) {
+	files := map[string]string{"a.go": "package a\nvar  A=1\n", "a.md": "var  A=1\n"}
+	if err := formatGoFiles(files, []string{"a.go", "a.md"}); err != nil {
+		t.Fatalf("formatGoFiles() failed: %v", err)
+	}
+	if files["a.go"] != "package a\n\nvar A = 1\n" || files["a.md"] != "var  A=1\n" {
+		t.Errorf("Expected only the Go file to be formatted, got %q", files)
+	}
+
+	if err := formatGoFiles(map[string]string{"b.go": "package b\nfunc {"}, []string{"b.go"}); err == nil {
+		t.Error("Expected an error for a file that doesn't parse")
+	}
+}
+
+func TestApplyEdits_Rejected(t *testing.T) {
+	tests := []struct {
+		name  string
+		edits []llm.FixEdit
+	}{
+		{"unknown file", []llm.FixEdit{{Filename: "c.go", StartLine: 1, Original: "package a", Replacement: "x"}}},
+		{"past end", []llm.FixEdit{{Filename: "a.go", StartLine: 2, EndLine: 9, Replacement: "x"}}},
+		{"mismatch", []llm.FixEdit{{Filename: "a.go", StartLine: 1, Original: "package z", Replacement: "x"}}},
+		{"missing original", []llm.FixEdit{{Filename: "a.go", StartLine: 3, Replacement: "var A = 2"}}},
+		{"overlap", []llm.FixEdit{
+			{Filename: "a.go", StartLine: 1, EndLine: 2, Original: "package a\n", Replacement: "x"},
+			{Filename: "a.go", StartLine: 2, EndLine: 3, Original: "\nvar A = 1", Replacement: "y"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string]string{"a.go": "package a\n\nvar A = 1\n"}
+			if _, err := applyEdits(files, tt.edits); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestReadWorkspaceFile_RejectsSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "secret")
+	os.WriteFile(outside, []byte("secret"), 0o644)
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main"), 0o644)
+	os.Symlink(outside, filepath.Join(dir, "link.go"))
+	os.Symlink(filepath.Dir(outside), filepath.Join(dir, "linkdir"))
+	os.Mkdir(filepath.Join(dir, "pkg"), 0o755)
+	ws := &workspace.Workspace{Dir: dir}
+
+	if got, err := readWorkspaceFile(ws, "main.go"); err != nil || got != "package main" {
+		t.Errorf("Expected regular file to be read, got %q, %v", got, err)
+	}
+	for _, name := range []string{"link.go", "linkdir/secret", "pkg", "../secret"} {
+		if _, err := readWorkspaceFile(ws, name); err == nil {
+			t.Errorf("Expected %s to be rejected", name)
+		}
+	}
+	if err := writeWorkspaceFiles(ws, map[string]string{"link.go": "overwritten"}, []string{"link.go"}); err == nil {
+		t.Error("Expected writing through a symlink to be rejected")
+	}
+	if data, _ := os.ReadFile(outside); string(data) != "secret" {
+		t.Errorf("Expected the file outside the checkout to be untouched, got %q", data)
+	}
+}
@@ -0,0 +1,581 @@
+package analyzer
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+const (
+	// maxRegionLines caps how large an enclosing declaration may be before it is left out
+	maxRegionLines = 300
+	// maxSiblingFiles caps how many extra files per Go package are fetched to resolve symbols
+	maxSiblingFiles = 10
+	// maxDeclarationScan caps how far above a hunk the generic matcher looks for a declaration
+	maxDeclarationScan = 200
+	// maxCallersPerSymbol caps how many callers of each changed declaration are included
+	maxCallersPerSymbol = 3
+)
+
+// fileFetcher retrieves file contents from the repository under review
+type fileFetcher interface {
+	GetFileContent(ctx context.Context, owner, repo, path, ref string) (string, error)
+	ListDirectory(ctx context.Context, owner, repo, dir, ref string) ([]string, error)
+}
+
+// hunk is the line range of a diff hunk on one side of the diff
+type hunk struct {
+	Start int
+	Lines int
+}
+
+// End returns the last line covered by the hunk
+func (h hunk) End() int {
+	if h.Lines == 0 {
+		return h.Start
+	}
+	return h.Start + h.Lines - 1
+}
+
+// declaration is a named range of lines in a source file
+type declaration struct {
+	Name  string
+	Start int
+	End   int
+}
+
+var (
+	hunkHeaderRe    = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@`)
+	oldHunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+\d+(?:,\d+)? @@`)
+	identifierRe    = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+	declarationRe   = regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?(?:(?:public|private|protected|static|abstract|final|pub)\s+)*(?:def|class|function|func|fn|interface|struct|impl|enum|trait|type)\s+([A-Za-z_][A-Za-z0-9_]*)`)
+)
+
+// parseHunks extracts the new-side line ranges from a unified diff patch
+func parseHunks(patch string) []hunk {
+	return parseHunkHeaders(patch, hunkHeaderRe)
+}
+
+// parseOldHunks extracts the old-side line ranges from a unified diff patch
+func parseOldHunks(patch string) []hunk {
+	return parseHunkHeaders(patch, oldHunkHeaderRe)
+}
+
+// parseHunkHeaders extracts the line ranges captured by a hunk header pattern
+func parseHunkHeaders(patch string, header *regexp.Regexp) []hunk {
+	var hunks []hunk
+	for _, line := range strings.Split(patch, "\n") {
+		m := header.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		start, _ := strconv.Atoi(m[1])
+		lines := 1
+		if m[2] != "" {
+			lines, _ = strconv.Atoi(m[2])
+		}
+		hunks = append(hunks, hunk{Start: start, Lines: lines})
+	}
+	return hunks
+}
+
+// addedLines returns the lines added by a patch without their leading '+'
+func addedLines(patch string) []string {
+	var lines []string
+	for _, line := range strings.Split(patch, "\n") {
+		if strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "+++") {
+			lines = append(lines, line[1:])
+		}
+	}
+	return lines
+}
+
+// referencedSymbols returns the distinct identifiers used in the given lines, in order of first use
+func referencedSymbols(lines []string) []string {
+	seen := make(map[string]bool)
+	var symbols []string
+	for _, line := range lines {
+		for _, ident := range identifierRe.FindAllString(line, -1) {
+			if len(ident) < 3 || seen[ident] || commonKeywords[ident] {
+				continue
+			}
+			seen[ident] = true
+			symbols = append(symbols, ident)
+		}
+	}
+	return symbols
+}
+
+// commonKeywords are identifiers that are never worth resolving to a definition
+var commonKeywords = map[string]bool{
+	"func": true, "var": true, "const": true, "type": true, "struct": true, "interface": true,
+	"return": true, "for": true, "range": true, "if": true, "else": true, "switch": true,
+	"case": true, "default": true, "break": true, "continue": true, "defer": true, "map": true,
+	"chan": true, "package": true, "import": true, "nil": true, "true": true, "false": true,
+	"string": true, "int": true, "int64": true, "bool": true, "byte": true, "error": true,
+	"err": true, "len": true, "make": true, "append": true, "new": true, "def": true,
+	"class": true, "self": true, "None": true, "True": true, "False": true, "function": true,
+	"let": true, "this": true, "null": true, "undefined": true, "async": true, "await": true,
+}
+
+// goDeclarations lists the top-level declarations of a Go source file
+func goDeclarations(filename, content string) ([]declaration, bool) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, content, parser.ParseComments)
+	if err != nil {
+		return nil, false
+	}
+
+	line := func(pos token.Pos) int { return fset.Position(pos).Line }
+
+	var decls []declaration
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			start := d.Pos()
+			if d.Doc != nil {
+				start = d.Doc.Pos()
+			}
+			name := d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				name = receiverName(d.Recv.List[0].Type) + "." + name
+			}
+			decls = append(decls, declaration{Name: name, Start: line(start), End: line(d.End())})
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT {
+				continue
+			}
+			for _, spec := range d.Specs {
+				start, end := spec.Pos(), spec.End()
+				if !d.Lparen.IsValid() {
+					start, end = d.Pos(), d.End()
+					if d.Doc != nil {
+						start = d.Doc.Pos()
+					}
+				}
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					decls = append(decls, declaration{Name: s.Name.Name, Start: line(start), End: line(end)})
+				case *ast.ValueSpec:
+					for _, n := range s.Names {
+						decls = append(decls, declaration{Name: n.Name, Start: line(start), End: line(end)})
+					}
+				}
+			}
+		}
+	}
+	return decls, true
+}
+
+// receiverName returns the type name of a method receiver
+func receiverName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(e.X)
+	case *ast.IndexExpr:
+		return receiverName(e.X)
+	case *ast.IndexListExpr:
+		return receiverName(e.X)
+	case *ast.Ident:
+		return e.Name
+	}
+	return ""
+}
+
+// genericDeclarations finds declarations in non-Go sources using keyword matching and
+// brace or indentation tracking
+func genericDeclarations(content string) []declaration {
+	lines := strings.Split(content, "\n")
+	var decls []declaration
+	for i, l := range lines {
+		m := declarationRe.FindStringSubmatch(l)
+		if m == nil {
+			continue
+		}
+		if end := declarationEnd(lines, i); end > 0 {
+			decls = append(decls, declaration{Name: m[1], Start: i + 1, End: end})
+		}
+	}
+	return decls
+}
+
+// declarationEnd returns the 1-based line on which the declaration starting at index start ends
+func declarationEnd(lines []string, start int) int {
+	// Brace-delimited languages: the body opens on the declaration line or shortly after
+	depth, opened := 0, false
+	for i := start; i < len(lines) && i-start < maxRegionLines; i++ {
+		if !opened && i-start > 2 {
+			break
+		}
+		for _, r := range lines[i] {
+			switch r {
+			case '{':
+				depth++
+				opened = true
+			case '}':
+				depth--
+			}
+		}
+		if opened && depth <= 0 {
+			return i + 1
+		}
+	}
+	if opened {
+		return 0
+	}
+
+	// Indentation-delimited languages: the body ends at the next line indented no deeper
+	indent := indentation(lines[start])
+	end := start
+	for i := start + 1; i < len(lines) && i-start < maxRegionLines; i++ {
+		if strings.TrimSpace(lines[i]) == "" {
+			continue
+		}
+		if indentation(lines[i]) <= indent {
+			break
+		}
+		end = i
+	}
+	return end + 1
+}
+
+// indentation counts the leading whitespace of a line, treating tabs as four spaces
+func indentation(line string) int {
+	n := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			n++
+		case '\t':
+			n += 4
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// fileDeclarations parses a file with the best available strategy for its language
+func fileDeclarations(filename, content string) []declaration {
+	if strings.HasSuffix(filename, ".go") {
+		if decls, ok := goDeclarations(filename, content); ok {
+			return decls
+		}
+	}
+	return genericDeclarations(content)
+}
+
+// enclosingDeclarations returns the declarations that overlap each hunk, merged so
+// that no line range is reported twice; nested declarations merge into the outermost
+// one
+func enclosingDeclarations(decls []declaration, hunks []hunk) []declaration {
+	var result []declaration
+	for _, h := range hunks {
+		for _, d := range decls {
+			if d.Start > h.End() || d.End < h.Start {
+				continue
+			}
+			if d.End-d.Start+1 > maxRegionLines {
+				continue
+			}
+			result = append(result, d)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Start < result[j].Start })
+
+	merged := make([]declaration, 0, len(result))
+	for _, d := range result {
+		if n := len(merged); n > 0 && d.Start <= merged[n-1].End {
+			if d.End > merged[n-1].End {
+				merged[n-1].End = d.End
+			}
+			if merged[n-1].Name != d.Name && !strings.Contains(merged[n-1].Name, d.Name) {
+				merged[n-1].Name += ", " + d.Name
+			}
+			continue
+		}
+		merged = append(merged, d)
+	}
+	return merged
+}
+
+// extractLines returns lines start through end (1-based, inclusive) of content
+func extractLines(content string, start, end int) string {
+	lines := strings.Split(content, "\n")
+	if start < 1 {
+		start = 1
+	}
+	if end > len(lines) {
+		end = len(lines)
+	}
+	if start > end {
+		return ""
+	}
+	return strings.Join(lines[start-1:end], "\n")
+}
+
+// estimateTokens roughly approximates the number of LLM tokens in a string
+func estimateTokens(s string) int {
+	return len(s)/4 + 1
+}
+
+// contextBuilder accumulates context snippets until the token budget is exhausted
+type contextBuilder struct {
+	budget   int
+	used     int
+	snippets []llm.ContextSnippet
+	covered  map[string][]declaration
+}
+
+// add appends a snippet if it fits in the remaining budget
+func (b *contextBuilder) add(snippet llm.ContextSnippet) bool {
+	cost := estimateTokens(snippet.Content)
+	if snippet.Content == "" || b.used+cost > b.budget {
+		return false
+	}
+	b.used += cost
+	b.snippets = append(b.snippets, snippet)
+	b.covered[snippet.Filename] = append(b.covered[snippet.Filename], declaration{Start: snippet.StartLine, End: snippet.EndLine})
+	return true
+}
+
+// isCovered reports whether a declaration is already part of an emitted snippet
+func (b *contextBuilder) isCovered(filename string, d declaration) bool {
+	for _, c := range b.covered[filename] {
+		if d.Start >= c.Start && d.End <= c.End {
+			return true
+		}
+	}
+	return false
+}
+
+// buildContext expands each hunk to its enclosing declaration, resolves the symbols
+// referenced by added lines to their definitions and adds callers of the changed
+// declarations, staying within tokenBudget. Definitions and callers are looked up in
+// the changed files and, for Go, up to maxSiblingFiles other files of their
+// packages; callers in other packages are not found, which the prompt tells the model
+func buildContext(ctx context.Context, fetcher fileFetcher, pr PullRequest, changes []llm.FileChange, tokenBudget int) []llm.ContextSnippet {
+	if tokenBudget <= 0 || pr.HeadSHA == "" {
+		return nil
+	}
+
+	// Fetch the head version of every changed file
+	sources := make(map[string]string)
+	var order []string
+	for _, change := range changes {
+		if change.Status == "removed" || change.Patch == "" {
+			continue
+		}
+		content, err := fetcher.GetFileContent(ctx, pr.Owner, pr.Repo, change.Filename, pr.HeadSHA)
+		if err != nil {
+			log.Printf("Skipping context for %s: %v", change.Filename, err)
+			continue
+		}
+		sources[change.Filename] = content
+		order = append(order, change.Filename)
+	}
+
+	// Go symbols are usually defined elsewhere in the same package, so pull in sibling files
+	definitionSources := make(map[string]string, len(sources))
+	for name, content := range sources {
+		definitionSources[name] = content
+	}
+	fetchedDirs := make(map[string]bool)
+	for _, name := range order {
+		if !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		dir := path.Dir(name)
+		if fetchedDirs[dir] {
+			continue
+		}
+		fetchedDirs[dir] = true
+
+		listDir := dir
+		if listDir == "." {
+			listDir = ""
+		}
+		siblings, err := fetcher.ListDirectory(ctx, pr.Owner, pr.Repo, listDir, pr.HeadSHA)
+		if err != nil {
+			log.Printf("Skipping package context for %s: %v", dir, err)
+			continue
+		}
+		fetched := 0
+		for _, sibling := range siblings {
+			if fetched >= maxSiblingFiles {
+				break
+			}
+			if !strings.HasSuffix(sibling, ".go") || strings.HasSuffix(sibling, "_test.go") {
+				continue
+			}
+			if _, ok := definitionSources[sibling]; ok {
+				continue
+			}
+			content, err := fetcher.GetFileContent(ctx, pr.Owner, pr.Repo, sibling, pr.HeadSHA)
+			if err != nil {
+				continue
+			}
+			definitionSources[sibling] = content
+			fetched++
+		}
+	}
+
+	builder := &contextBuilder{budget: tokenBudget, covered: make(map[string][]declaration)}
+
+	patches := make(map[string]string, len(changes))
+	for _, change := range changes {
+		patches[change.Filename] = change.Patch
+	}
+
+	// Enclosing declarations come first: they matter most for understanding a hunk
+	for _, name := range order {
+		content := sources[name]
+		for _, d := range enclosingDeclarations(fileDeclarations(name, content), parseHunks(patches[name])) {
+			builder.add(llm.ContextSnippet{
+				Filename:  name,
+				StartLine: d.Start,
+				EndLine:   d.End,
+				Kind:      "enclosing",
+				Symbol:    d.Name,
+				Content:   extractLines(content, d.Start, d.End),
+			})
+		}
+	}
+
+	// Index definitions by name; Go files only resolve symbols within Go sources
+	type located struct {
+		filename string
+		decl     declaration
+	}
+	goIndex := make(map[string]located)
+	otherIndex := make(map[string]located)
+	definitionNames := make([]string, 0, len(definitionSources))
+	for name := range definitionSources {
+		definitionNames = append(definitionNames, name)
+	}
+	sort.Strings(definitionNames)
+	definitionDecls := make(map[string][]declaration, len(definitionNames))
+	for _, name := range definitionNames {
+		index := otherIndex
+		if strings.HasSuffix(name, ".go") {
+			index = goIndex
+		}
+		definitionDecls[name] = fileDeclarations(name, definitionSources[name])
+		for _, d := range definitionDecls[name] {
+			if _, exists := index[d.Name]; !exists {
+				index[d.Name] = located{filename: name, decl: d}
+			}
+		}
+	}
+
+	emitted := make(map[string]bool)
+	for _, name := range order {
+		index := otherIndex
+		if strings.HasSuffix(name, ".go") {
+			index = goIndex
+		}
+		for _, symbol := range referencedSymbols(addedLines(patches[name])) {
+			def, ok := index[symbol]
+			if !ok || emitted[symbol] || builder.isCovered(def.filename, def.decl) {
+				continue
+			}
+			if def.decl.End-def.decl.Start+1 > maxRegionLines {
+				continue
+			}
+			if builder.add(llm.ContextSnippet{
+				Filename:  def.filename,
+				StartLine: def.decl.Start,
+				EndLine:   def.decl.End,
+				Kind:      "definition",
+				Symbol:    symbol,
+				Content:   extractLines(definitionSources[def.filename], def.decl.Start, def.decl.End),
+			}) {
+				emitted[symbol] = true
+			}
+		}
+	}
+
+	// Callers show how the changed declarations are relied on
+	for _, symbol := range changedSymbols(sources, order, patches) {
+		call := regexp.MustCompile(`\b` + regexp.QuoteMeta(symbol.Name) + `\(`)
+		found := 0
+		for _, name := range definitionNames {
+			if strings.HasSuffix(name, ".go") != symbol.Go {
+				continue
+			}
+			for _, d := range definitionDecls[name] {
+				if found >= maxCallersPerSymbol {
+					break
+				}
+				if d.End-d.Start+1 > maxRegionLines || builder.isCovered(name, d) {
+					continue
+				}
+				// Skip the declaration itself, whose first line matches a call
+				content := extractLines(definitionSources[name], d.Start, d.End)
+				if shortName(d.Name) == symbol.Name || !call.MatchString(content) {
+					continue
+				}
+				if builder.add(llm.ContextSnippet{
+					Filename:  name,
+					StartLine: d.Start,
+					EndLine:   d.End,
+					Kind:      "caller",
+					Symbol:    symbol.Name,
+					Content:   content,
+				}) {
+					found++
+				}
+			}
+		}
+	}
+
+	return builder.snippets
+}
+
+// changedSymbol is a declaration touched by a hunk, named as it is called
+type changedSymbol struct {
+	Name string
+	Go   bool // Declared in a Go file, so only Go sources can call it
+}
+
+// changedSymbols returns the declarations the hunks of each file touch, with methods
+// named without their receiver
+func changedSymbols(sources map[string]string, order []string, patches map[string]string) []changedSymbol {
+	seen := make(map[changedSymbol]bool)
+	var symbols []changedSymbol
+	for _, name := range order {
+		hunks := parseHunks(patches[name])
+		for _, d := range fileDeclarations(name, sources[name]) {
+			for _, h := range hunks {
+				if d.Start > h.End() || d.End < h.Start {
+					continue
+				}
+				symbol := changedSymbol{Name: shortName(d.Name), Go: strings.HasSuffix(name, ".go")}
+				if !seen[symbol] {
+					seen[symbol] = true
+					symbols = append(symbols, symbol)
+				}
+				break
+			}
+		}
+	}
+	return symbols
+}
+
+// shortName strips the receiver from a method's name
+func shortName(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
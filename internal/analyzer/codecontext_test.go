@@ -0,0 +1,168 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+type mockFetcher struct {
+	files map[string]string
+	dirs  map[string][]string
+}
+
+func (m *mockFetcher) GetFileContent(ctx context.Context, owner, repo, path, ref string) (string, error) {
+	content, ok := m.files[path]
+	if !ok {
+		return "", fmt.Errorf("not found: %s", path)
+	}
+	return content, nil
+}
+
+func (m *mockFetcher) ListDirectory(ctx context.Context, owner, repo, dir, ref string) ([]string, error) {
+	return m.dirs[dir], nil
+}
+
+const handlerSource = `package service
+
+// Handle processes a request
+func Handle(req Request) error {
+	if req.ID == "" {
+		return ErrMissingID
+	}
+	return process(req)
+}
+
+func unrelated() {}
+`
+
+const typesSource = `package service
+
+import "errors"
+
+// Request is an incoming request
+type Request struct {
+	ID string
+}
+
+var ErrMissingID = errors.New("missing id")
+`
+
+func TestParseHunks(t *testing.T) {
+	patch := "@@ -1,3 +1,4 @@\n line\n+added\n@@ -10 +12,2 @@\n-old\n+new"
+
+	hunks := parseHunks(patch)
+	if len(hunks) != 2 {
+		t.Fatalf("Expected 2 hunks, got %d", len(hunks))
+	}
+	if hunks[0].Start != 1 || hunks[0].End() != 4 {
+		t.Errorf("Expected first hunk 1-4, got %d-%d", hunks[0].Start, hunks[0].End())
+	}
+	if hunks[1].Start != 12 || hunks[1].End() != 13 {
+		t.Errorf("Expected second hunk 12-13, got %d-%d", hunks[1].Start, hunks[1].End())
+	}
+}
+
+func TestEnclosingDeclarations_Go(t *testing.T) {
+	decls := fileDeclarations("handler.go", handlerSource)
+	enclosing := enclosingDeclarations(decls, []hunk{{Start: 5, Lines: 2}})
+
+	if len(enclosing) != 1 {
+		t.Fatalf("Expected 1 enclosing declaration, got %d", len(enclosing))
+	}
+	if enclosing[0].Name != "Handle" || enclosing[0].Start != 3 || enclosing[0].End != 9 {
+		t.Errorf("Expected Handle at 3-9, got %s at %d-%d", enclosing[0].Name, enclosing[0].Start, enclosing[0].End)
+	}
+}
+
+func TestEnclosingDeclarations_Python(t *testing.T) {
+	source := "import os\n\ndef load(path):\n    data = open(path)\n    return data\n\ndef other():\n    pass\n"
+	enclosing := enclosingDeclarations(fileDeclarations("loader.py", source), []hunk{{Start: 4, Lines: 1}})
+
+	if len(enclosing) != 1 {
+		t.Fatalf("Expected 1 enclosing declaration, got %d", len(enclosing))
+	}
+	if enclosing[0].Name != "load" || enclosing[0].Start != 3 || enclosing[0].End != 5 {
+		t.Errorf("Expected load at 3-5, got %s at %d-%d", enclosing[0].Name, enclosing[0].Start, enclosing[0].End)
+	}
+}
+
+func TestBuildContext_ResolvesPackageSymbols(t *testing.T) {
+	fetcher := &mockFetcher{
+		files: map[string]string{
+			"service/handler.go": handlerSource,
+			"service/types.go":   typesSource,
+		},
+		dirs: map[string][]string{
+			"service": {"service/handler.go", "service/types.go"},
+		},
+	}
+	changes := []llm.FileChange{{
+		Filename: "service/handler.go",
+		Status:   "modified",
+		Patch:    "@@ -5,1 +5,2 @@\n+\tif req.ID == \"\" {\n+\t\treturn ErrMissingID",
+	}}
+	pr := PullRequest{Owner: "o", Repo: "r", HeadSHA: "abc"}
+
+	snippets := buildContext(context.Background(), fetcher, pr, changes, 1000)
+
+	var kinds []string
+	for _, s := range snippets {
+		kinds = append(kinds, s.Kind+":"+s.Symbol)
+	}
+	got := strings.Join(kinds, ",")
+	for _, expected := range []string{"enclosing:Handle", "definition:ErrMissingID"} {
+		if !strings.Contains(got, expected) {
+			t.Errorf("Expected snippets to contain %s, got %s", expected, got)
+		}
+	}
+}
+
+func TestBuildContext_RespectsBudget(t *testing.T) {
+	fetcher := &mockFetcher{files: map[string]string{"handler.go": handlerSource}}
+	changes := []llm.FileChange{{
+		Filename: "handler.go",
+		Status:   "modified",
+		Patch:    "@@ -5,1 +5,1 @@\n+\tif req.ID == \"\" {",
+	}}
+	pr := PullRequest{HeadSHA: "abc"}
+
+	if snippets := buildContext(context.Background(), fetcher, pr, changes, 5); len(snippets) != 0 {
+		t.Errorf("Expected no snippets within a tiny budget, got %d", len(snippets))
+	}
+	if snippets := buildContext(context.Background(), fetcher, pr, changes, 0); snippets != nil {
+		t.Errorf("Expected context retrieval to be disabled with a zero budget")
+	}
+}
+
+func TestBuildContext_IncludesCallers(t *testing.T) {
+	routerSource := "package service\n\n// Route dispatches a request\nfunc Route(req Request) error {\n\treturn Handle(req)\n}\n"
+	fetcher := &mockFetcher{
+		files: map[string]string{
+			"service/handler.go": handlerSource,
+			"service/router.go":  routerSource,
+		},
+		dirs: map[string][]string{
+			"service": {"service/handler.go", "service/router.go"},
+		},
+	}
+	changes := []llm.FileChange{{
+		Filename: "service/handler.go",
+		Status:   "modified",
+		Patch:    "@@ -5,1 +5,1 @@\n+\tif req.ID == \"\" {",
+	}}
+	pr := PullRequest{Owner: "o", Repo: "r", HeadSHA: "abc"}
+
+	var callers []llm.ContextSnippet
+	for _, s := range buildContext(context.Background(), fetcher, pr, changes, 1000) {
+		if s.Kind == "caller" {
+			callers = append(callers, s)
+		}
+	}
+	if len(callers) != 1 || callers[0].Filename != "service/router.go" || callers[0].Symbol != "Handle" {
+		t.Errorf("Expected Route as the only caller of Handle, got %+v", callers)
+	}
+}
@@ -0,0 +1,69 @@
+package analyzer
+
+import (
+	"context"
+	"sync"
+)
+
+// cachingFetcher memoizes file and directory lookups so that analysis stages
+// reviewing the same pull request don't fetch a file twice
+type cachingFetcher struct {
+	fetcher fileFetcher
+
+	mu    sync.Mutex
+	files map[string]cachedResult
+	dirs  map[string][]string
+}
+
+type cachedResult struct {
+	content string
+	err     error
+}
+
+// newCachingFetcher wraps a fetcher with a per-review cache
+func newCachingFetcher(fetcher fileFetcher) *cachingFetcher {
+	return &cachingFetcher{
+		fetcher: fetcher,
+		files:   make(map[string]cachedResult),
+		dirs:    make(map[string][]string),
+	}
+}
+
+// GetFileContent returns the cached contents of a file, fetching them on first use
+func (c *cachingFetcher) GetFileContent(ctx context.Context, owner, repo, path, ref string) (string, error) {
+	key := owner + "/" + repo + "@" + ref + ":" + path
+
+	c.mu.Lock()
+	result, ok := c.files[key]
+	c.mu.Unlock()
+	if ok {
+		return result.content, result.err
+	}
+
+	content, err := c.fetcher.GetFileContent(ctx, owner, repo, path, ref)
+	c.mu.Lock()
+	c.files[key] = cachedResult{content: content, err: err}
+	c.mu.Unlock()
+	return content, err
+}
+
+// ListDirectory returns the cached listing of a directory, fetching it on first use
+func (c *cachingFetcher) ListDirectory(ctx context.Context, owner, repo, dir, ref string) ([]string, error) {
+	key := owner + "/" + repo + "@" + ref + ":" + dir
+
+	c.mu.Lock()
+	paths, ok := c.dirs[key]
+	c.mu.Unlock()
+	if ok {
+		return paths, nil
+	}
+
+	paths, err := c.fetcher.ListDirectory(ctx, owner, repo, dir, ref)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.dirs[key] = paths
+	c.mu.Unlock()
+	return paths, nil
+}
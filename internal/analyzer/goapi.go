@@ -0,0 +1,294 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"log"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+// apiSymbol is an exported declaration of a Go package
+type apiSymbol struct {
+	Filename  string
+	Kind      string // "func", "method", "type", "interface", "struct", "const", "var"
+	Signature string
+	Shape     string            // Signature with names that don't affect callers removed
+	Fields    map[string]string // Exported struct fields and their types
+}
+
+// goPackageAPI collects the exported API declared in a Go source file. It returns
+// false for files that don't parse or that belong to package main
+func goPackageAPI(filename, content string) (map[string]apiSymbol, bool) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, content, parser.SkipObjectResolution)
+	if err != nil || file.Name.Name == "main" {
+		return nil, false
+	}
+
+	api := make(map[string]apiSymbol)
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() {
+				continue
+			}
+			name, kind := d.Name.Name, "func"
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				recv := receiverName(d.Recv.List[0].Type)
+				if !ast.IsExported(recv) {
+					continue
+				}
+				name, kind = recv+"."+d.Name.Name, "method"
+			}
+			api[name] = apiSymbol{
+				Filename:  filename,
+				Kind:      kind,
+				Signature: printNode(fset, &ast.FuncDecl{Recv: d.Recv, Name: d.Name, Type: d.Type}),
+				Shape:     printNode(fset, &ast.FuncType{TypeParams: d.Type.TypeParams, Params: unnamedFields(d.Type.Params), Results: unnamedFields(d.Type.Results)}),
+			}
+
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if !s.Name.IsExported() {
+						continue
+					}
+					api[s.Name.Name] = typeSymbol(fset, filename, s)
+				case *ast.ValueSpec:
+					for _, n := range s.Names {
+						if !n.IsExported() {
+							continue
+						}
+						signature := d.Tok.String() + " " + n.Name
+						if s.Type != nil {
+							signature += " " + printNode(fset, s.Type)
+						}
+						api[n.Name] = apiSymbol{Filename: filename, Kind: d.Tok.String(), Signature: signature, Shape: signature}
+					}
+				}
+			}
+		}
+	}
+	return api, true
+}
+
+// typeSymbol describes an exported type, keeping only the exported fields of structs
+func typeSymbol(fset *token.FileSet, filename string, spec *ast.TypeSpec) apiSymbol {
+	symbol := apiSymbol{Filename: filename, Kind: "type"}
+
+	switch t := spec.Type.(type) {
+	case *ast.InterfaceType:
+		symbol.Kind = "interface"
+	case *ast.StructType:
+		symbol.Kind = "struct"
+		symbol.Fields = make(map[string]string)
+		exported := &ast.FieldList{}
+		for _, field := range t.Fields.List {
+			fieldType := printNode(fset, field.Type)
+			if len(field.Names) == 0 {
+				// Embedded field: promoted members are part of the API when the type is exported
+				if name := receiverName(field.Type); ast.IsExported(name) {
+					symbol.Fields[name] = fieldType
+					exported.List = append(exported.List, &ast.Field{Type: field.Type})
+				}
+				continue
+			}
+			var names []*ast.Ident
+			for _, n := range field.Names {
+				if n.IsExported() {
+					names = append(names, n)
+					symbol.Fields[n.Name] = fieldType
+				}
+			}
+			if len(names) > 0 {
+				exported.List = append(exported.List, &ast.Field{Names: names, Type: field.Type})
+			}
+		}
+		spec = &ast.TypeSpec{Name: spec.Name, TypeParams: spec.TypeParams, Assign: spec.Assign, Type: &ast.StructType{Fields: exported}}
+	}
+
+	symbol.Signature = "type " + printNode(fset, spec)
+	symbol.Shape = symbol.Signature
+	return symbol
+}
+
+// unnamedFields copies a parameter list without its names, since renaming a
+// parameter doesn't affect callers
+func unnamedFields(fields *ast.FieldList) *ast.FieldList {
+	if fields == nil {
+		return nil
+	}
+	unnamed := &ast.FieldList{}
+	for _, field := range fields.List {
+		count := len(field.Names)
+		if count == 0 {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			unnamed.List = append(unnamed.List, &ast.Field{Type: field.Type})
+		}
+	}
+	return unnamed
+}
+
+// printNode renders an AST node as Go source
+func printNode(fset *token.FileSet, node ast.Node) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// compareAPI reports the differences between two versions of a package's exported API
+func compareAPI(before, after map[string]apiSymbol) []llm.APIChange {
+	var changes []llm.APIChange
+
+	for name, old := range before {
+		current, ok := after[name]
+		switch {
+		case !ok:
+			changes = append(changes, llm.APIChange{
+				Filename: old.Filename,
+				Symbol:   name,
+				Kind:     old.Kind,
+				Change:   "removed",
+				Before:   old.Signature,
+				Breaking: true,
+			})
+		case old.Shape != current.Shape:
+			changes = append(changes, llm.APIChange{
+				Filename: current.Filename,
+				Symbol:   name,
+				Kind:     current.Kind,
+				Change:   "changed",
+				Before:   old.Signature,
+				After:    current.Signature,
+				Breaking: isBreakingChange(old, current),
+			})
+		}
+	}
+
+	for name, current := range after {
+		if _, ok := before[name]; !ok {
+			changes = append(changes, llm.APIChange{
+				Filename: current.Filename,
+				Symbol:   name,
+				Kind:     current.Kind,
+				Change:   "added",
+				After:    current.Signature,
+			})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Filename != changes[j].Filename {
+			return changes[i].Filename < changes[j].Filename
+		}
+		return changes[i].Symbol < changes[j].Symbol
+	})
+	return changes
+}
+
+// isBreakingChange decides whether a signature change can break callers. Adding
+// fields to a struct is compatible; any other change to an exported signature is not
+func isBreakingChange(before, after apiSymbol) bool {
+	if before.Kind != after.Kind {
+		return true
+	}
+	if before.Kind != "struct" {
+		return true
+	}
+	for field, fieldType := range before.Fields {
+		if after.Fields[field] != fieldType {
+			return true
+		}
+	}
+	return false
+}
+
+// analyzeGoAPI parses the base and head versions of the changed Go files and reports how
+// the exported API of each touched package changed
+func analyzeGoAPI(ctx context.Context, fetcher fileFetcher, pr PullRequest, changes []llm.FileChange) []llm.APIChange {
+	if pr.HeadSHA == "" || pr.BaseSHA == "" {
+		return nil
+	}
+
+	type packageAPI struct {
+		before map[string]apiSymbol
+		after  map[string]apiSymbol
+	}
+	packages := make(map[string]*packageAPI)
+	var dirs []string
+
+	// version parses a file's exported API at ref; added and removed files have an
+	// empty API on the side where they don't exist
+	version := func(filename, ref string, exists bool) (map[string]apiSymbol, bool) {
+		if !exists {
+			return nil, true
+		}
+		content, err := fetcher.GetFileContent(ctx, pr.Owner, pr.Repo, filename, ref)
+		if err != nil {
+			log.Printf("Skipping API diff for %s: %v", filename, err)
+			return nil, false
+		}
+		api, ok := goPackageAPI(filename, content)
+		if !ok {
+			log.Printf("Skipping API diff for %s: failed to parse at %s", filename, ref)
+		}
+		return api, ok
+	}
+
+	for _, change := range changes {
+		if !strings.HasSuffix(change.Filename, ".go") || strings.HasSuffix(change.Filename, "_test.go") {
+			continue
+		}
+
+		oldName := change.Filename
+		if change.PreviousFilename != "" {
+			oldName = change.PreviousFilename
+		}
+		// A side that can't be read would make every symbol look added or removed,
+		// so the file is only compared when both sides parse
+		before, ok := version(oldName, pr.BaseSHA, change.Status != "added")
+		if !ok {
+			continue
+		}
+		after, ok := version(change.Filename, pr.HeadSHA, change.Status != "removed")
+		if !ok {
+			continue
+		}
+
+		dir := path.Dir(change.Filename)
+		pkg, ok := packages[dir]
+		if !ok {
+			pkg = &packageAPI{before: make(map[string]apiSymbol), after: make(map[string]apiSymbol)}
+			packages[dir] = pkg
+			dirs = append(dirs, dir)
+		}
+		for name, symbol := range before {
+			pkg.before[name] = symbol
+		}
+		for name, symbol := range after {
+			pkg.after[name] = symbol
+		}
+	}
+
+	// Symbols are compared per package so that moving a declaration between
+	// changed files of the same package isn't reported as a removal
+	sort.Strings(dirs)
+	var apiChanges []llm.APIChange
+	for _, dir := range dirs {
+		apiChanges = append(apiChanges, compareAPI(packages[dir].before, packages[dir].after)...)
+	}
+	return apiChanges
+}
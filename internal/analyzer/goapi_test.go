@@ -0,0 +1,140 @@
+package analyzer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+const apiBefore = `package store
+
+type Store interface {
+	Get(key string) (string, error)
+}
+
+type Config struct {
+	Path    string
+	Timeout int
+	cache   bool
+}
+
+func Open(path string) (*Config, error) { return nil, nil }
+
+func (c *Config) Close() error { return nil }
+
+func Legacy() {}
+`
+
+const apiAfter = `package store
+
+type Store interface {
+	Get(ctx string, key string) (string, error)
+}
+
+type Config struct {
+	Path    string
+	Timeout int
+	Retries int
+}
+
+func Open(filename string) (*Config, error) { return nil, nil }
+
+func (c *Config) Close() error { return nil }
+
+func New() *Config { return nil }
+`
+
+func findChange(changes []llm.APIChange, symbol string) *llm.APIChange {
+	for i := range changes {
+		if changes[i].Symbol == symbol {
+			return &changes[i]
+		}
+	}
+	return nil
+}
+
+func TestAnalyzeGoAPI(t *testing.T) {
+	fetcher := &mockBaseHeadFetcher{
+		base: map[string]string{"store/store.go": apiBefore},
+		head: map[string]string{"store/store.go": apiAfter},
+	}
+	changes := []llm.FileChange{{Filename: "store/store.go", Status: "modified"}}
+	pr := PullRequest{BaseSHA: "base", HeadSHA: "head"}
+
+	apiChanges := analyzeGoAPI(context.Background(), fetcher, pr, changes)
+
+	tests := []struct {
+		symbol   string
+		change   string
+		breaking bool
+	}{
+		{"Store", "changed", true},
+		{"Config", "changed", false},
+		{"Legacy", "removed", true},
+		{"New", "added", false},
+	}
+	for _, tt := range tests {
+		c := findChange(apiChanges, tt.symbol)
+		if c == nil {
+			t.Errorf("Expected a change for %s", tt.symbol)
+			continue
+		}
+		if c.Change != tt.change || c.Breaking != tt.breaking {
+			t.Errorf("Expected %s to be %s (breaking=%v), got %s (breaking=%v)", tt.symbol, tt.change, tt.breaking, c.Change, c.Breaking)
+		}
+	}
+
+	// Renaming a parameter or touching unexported fields doesn't change the API
+	for _, symbol := range []string{"Open", "Config.Close"} {
+		if c := findChange(apiChanges, symbol); c != nil {
+			t.Errorf("Expected no change for %s, got %s", symbol, c.Change)
+		}
+	}
+}
+
+func TestAnalyzeGoAPI_SymbolMovedWithinPackage(t *testing.T) {
+	fetcher := &mockBaseHeadFetcher{
+		base: map[string]string{"pkg/a.go": "package pkg\n\nfunc Moved() {}\n", "pkg/b.go": "package pkg\n"},
+		head: map[string]string{"pkg/a.go": "package pkg\n", "pkg/b.go": "package pkg\n\nfunc Moved() {}\n"},
+	}
+	changes := []llm.FileChange{
+		{Filename: "pkg/a.go", Status: "modified"},
+		{Filename: "pkg/b.go", Status: "modified"},
+	}
+
+	apiChanges := analyzeGoAPI(context.Background(), fetcher, PullRequest{BaseSHA: "base", HeadSHA: "head"}, changes)
+	if len(apiChanges) != 0 {
+		t.Errorf("Expected no API changes when a symbol moves between files, got %+v", apiChanges)
+	}
+}
+
+func TestAnalyzeGoAPI_UnparseableHead(t *testing.T) {
+	fetcher := &mockBaseHeadFetcher{
+		base: map[string]string{"pkg/a.go": "package pkg\n\nfunc Kept() {}\n"},
+		head: map[string]string{"pkg/a.go": "package pkg\n\nfunc Kept() {\n"},
+	}
+	changes := []llm.FileChange{{Filename: "pkg/a.go", Status: "modified"}}
+
+	apiChanges := analyzeGoAPI(context.Background(), fetcher, PullRequest{BaseSHA: "base", HeadSHA: "head"}, changes)
+	if len(apiChanges) != 0 {
+		t.Errorf("Expected a file that doesn't parse at the head to be skipped, got %+v", apiChanges)
+	}
+}
+
+type mockBaseHeadFetcher struct {
+	base map[string]string
+	head map[string]string
+}
+
+func (m *mockBaseHeadFetcher) GetFileContent(ctx context.Context, owner, repo, path, ref string) (string, error) {
+	files := m.head
+	if ref == "base" {
+		files = m.base
+	}
+	return files[path], nil
+}
+
+func (m *mockBaseHeadFetcher) ListDirectory(ctx context.Context, owner, repo, dir, ref string) ([]string, error) {
+	return nil, nil
+}
@@ -0,0 +1,100 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+// maxFallbackFileSize is the largest file whose contents are fetched when GitHub
+// omits its patch
+const maxFallbackFileSize = 32 * 1024
+
+// completePatches handles files GitHub returned without a patch, either because
+// they are binary or because their diff is too large. Added files get a patch built
+// from their contents, other files are shown to the model in full, and files that
+// can't be shown are reported as skipped
+func completePatches(ctx context.Context, fetcher fileFetcher, pr PullRequest, changes []llm.FileChange) ([]llm.ContextSnippet, []llm.SkippedFile) {
+	var snippets []llm.ContextSnippet
+	var skipped []llm.SkippedFile
+
+	for i := range changes {
+		change := &changes[i]
+		if change.Patch != "" || change.Status == "removed" {
+			continue
+		}
+		if change.Additions == 0 && change.Deletions == 0 {
+			// Pure renames have nothing to show; anything else without line changes is binary
+			if change.Status != "renamed" {
+				skipped = append(skipped, llm.SkippedFile{Filename: change.Filename, Reason: "binary file"})
+			}
+			continue
+		}
+
+		content, err := fetcher.GetFileContent(ctx, pr.Owner, pr.Repo, change.Filename, pr.HeadSHA)
+		switch {
+		case err != nil:
+			log.Printf("Failed to fetch %s for a missing patch: %v", change.Filename, err)
+			skipped = append(skipped, llm.SkippedFile{Filename: change.Filename, Reason: "diff too large and contents unavailable"})
+		case strings.ContainsRune(content, 0):
+			skipped = append(skipped, llm.SkippedFile{Filename: change.Filename, Reason: "binary file"})
+		case len(content) > maxFallbackFileSize:
+			skipped = append(skipped, llm.SkippedFile{Filename: change.Filename, Reason: fmt.Sprintf("diff too large (%d lines changed)", change.Changes)})
+		case change.Status == "added":
+			change.Patch = addedPatch(content)
+		default:
+			lines := strings.Count(strings.TrimSuffix(content, "\n"), "\n") + 1
+			snippets = append(snippets, llm.ContextSnippet{
+				Filename:  change.Filename,
+				StartLine: 1,
+				EndLine:   lines,
+				Kind:      "file",
+				Content:   content,
+			})
+		}
+	}
+	return snippets, skipped
+}
+
+// addedPatch builds the patch of a new file from its contents
+func addedPatch(content string) string {
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	var b strings.Builder
+	fmt.Fprintf(&b, "@@ -0,0 +1,%d @@", len(lines))
+	for _, line := range lines {
+		b.WriteString("\n+" + line)
+	}
+	return b.String()
+}
+
+// diffFromPatches reconstructs a unified diff from per-file patches, for when the
+// full diff is unavailable
+func diffFromPatches(changes []llm.FileChange) string {
+	var b strings.Builder
+	for _, change := range changes {
+		if change.Patch == "" {
+			continue
+		}
+		b.WriteString(fileDiff(change))
+	}
+	return b.String()
+}
+
+// fileDiff renders one file's patch with unified diff headers
+func fileDiff(change llm.FileChange) string {
+	oldName := change.Filename
+	if change.PreviousFilename != "" {
+		oldName = change.PreviousFilename
+	}
+	from, to := "a/"+oldName, "b/"+change.Filename
+	switch change.Status {
+	case "added":
+		from = "/dev/null"
+	case "removed":
+		to = "/dev/null"
+	}
+	return fmt.Sprintf("diff --git a/%s b/%s\n--- %s\n+++ %s\n%s\n", oldName, change.Filename, from, to, change.Patch)
+}
@@ -0,0 +1,66 @@
+package analyzer
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+func TestCompletePatches(t *testing.T) {
+	fetcher := &mockFetcher{files: map[string]string{
+		"new.go":     "package a\n\nvar A = 1\n",
+		"big.go":     "package a\n\nvar B = 2\n",
+		"huge.go":    strings.Repeat("// filler\n", maxFallbackFileSize),
+		"blob.dat":   "ab\x00cd",
+		"normal.go":  "package a\n",
+		"renamed.go": "package a\n",
+	}}
+	changes := []llm.FileChange{
+		{Filename: "new.go", Status: "added", Additions: 3},
+		{Filename: "big.go", Status: "modified", Additions: 1, Deletions: 1},
+		{Filename: "huge.go", Status: "modified", Additions: 5000, Changes: 5000},
+		{Filename: "blob.dat", Status: "modified", Additions: 1},
+		{Filename: "logo.png", Status: "added"},
+		{Filename: "missing.go", Status: "modified", Additions: 1},
+		{Filename: "renamed.go", Status: "renamed", PreviousFilename: "old.go"},
+		{Filename: "normal.go", Status: "modified", Additions: 1, Patch: "@@ -1 +1 @@\n-package b\n+package a"},
+	}
+
+	snippets, skipped := completePatches(context.Background(), fetcher, PullRequest{HeadSHA: "head"}, changes)
+
+	if changes[0].Patch != "@@ -0,0 +1,3 @@\n+package a\n+\n+var A = 1" {
+		t.Errorf("Unexpected patch for added file: %q", changes[0].Patch)
+	}
+	if len(snippets) != 1 || snippets[0].Filename != "big.go" || snippets[0].Kind != "file" || snippets[0].EndLine != 3 {
+		t.Errorf("Expected full contents of big.go, got %+v", snippets)
+	}
+
+	reasons := make(map[string]string)
+	for _, f := range skipped {
+		reasons[f.Filename] = f.Reason
+	}
+	for _, name := range []string{"huge.go", "blob.dat", "logo.png", "missing.go"} {
+		if reasons[name] == "" {
+			t.Errorf("Expected %s to be skipped", name)
+		}
+	}
+	if len(skipped) != 4 {
+		t.Errorf("Expected 4 skipped files, got %+v", skipped)
+	}
+}
+
+func TestDiffFromPatches(t *testing.T) {
+	diff := diffFromPatches([]llm.FileChange{
+		{Filename: "a.go", Status: "added", Patch: "@@ -0,0 +1 @@\n+package a"},
+		{Filename: "b.go", Status: "renamed", PreviousFilename: "old.go", Patch: "@@ -1 +1 @@\n-x\n+y"},
+		{Filename: "c.png", Status: "added"},
+	})
+
+	expected := "diff --git a/a.go b/a.go\n--- /dev/null\n+++ b/a.go\n@@ -0,0 +1 @@\n+package a\n" +
+		"diff --git a/old.go b/b.go\n--- a/old.go\n+++ b/b.go\n@@ -1 +1 @@\n-x\n+y\n"
+	if diff != expected {
+		t.Errorf("Expected %q, got %q", expected, diff)
+	}
+}
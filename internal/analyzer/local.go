@@ -0,0 +1,253 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+// Refs under which local files are read from the working tree or the index rather
+// than from a commit; git refs can't contain a colon
+const (
+	workingTree = ":working-tree"
+	index       = ":index"
+)
+
+// LocalChange is a diff of a local repository reviewed without a pull request
+type LocalChange struct {
+	// Dir is the repository the diff applies to
+	Dir string
+	// Diff is a unified diff as printed by git diff
+	Diff string
+	// Base is the commit the old side of the diff is read from; empty means HEAD
+	Base string
+	// Head is the commit the new side of the diff is read from; empty means the
+	// working tree
+	Head string
+	// Staged reads the new side of the diff from the index, for diffs of staged
+	// changes; Head is ignored
+	Staged bool
+	// Title describes the change to the model, e.g. a commit subject
+	Title string
+}
+
+// AnalyzeLocal reviews a diff of a local repository. Nothing is fetched from
+// GitHub, so the analyzer may have been created without a GitHub client
+func (a *Analyzer) AnalyzeLocal(ctx context.Context, change LocalChange) (*llm.CodeReviewResponse, error) {
+	fileChanges := ParseDiff(change.Diff)
+	if len(fileChanges) == 0 {
+		return nil, fmt.Errorf("the diff doesn't change any files")
+	}
+
+	dir, err := filepath.Abs(change.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository directory: %w", err)
+	}
+	pr := PullRequest{
+		Repo:    filepath.Base(dir),
+		Title:   change.Title,
+		BaseSHA: change.Base,
+		HeadSHA: change.Head,
+	}
+	if pr.BaseSHA == "" {
+		pr.BaseSHA = "HEAD"
+	}
+	switch {
+	case change.Staged:
+		pr.HeadSHA = index
+	case pr.HeadSHA == "":
+		pr.HeadSHA = workingTree
+	}
+	log.Printf("Analyzing %d changed files in %s", len(fileChanges), dir)
+
+	fetcher := newCachingFetcher(gitFetcher{dir: dir})
+	checkout := func(ctx context.Context) (string, func(), error) {
+		switch pr.HeadSHA {
+		case workingTree:
+			return dir, func() {}, nil
+		case index:
+			return gitCheckoutIndex(ctx, dir)
+		}
+		return gitWorktree(ctx, dir, pr.HeadSHA)
+	}
+	return a.review(ctx, pr, fetcher, checkout, change.Diff, fileChanges)
+}
+
+// ParseDiff builds the changed files of a unified diff in git's format, with
+// patches starting at the first hunk header like the ones GitHub returns
+func ParseDiff(diff string) []llm.FileChange {
+	var changes []llm.FileChange
+	var current *llm.FileChange
+	var patch []string
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+		current.Patch = strings.Join(patch, "\n")
+		current.Changes = current.Additions + current.Deletions
+		changes = append(changes, *current)
+		current, patch = nil, nil
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		if strings.HasPrefix(line, "diff --git ") {
+			flush()
+			current = &llm.FileChange{Status: "modified"}
+			// Fall back to the header's name in case there are no ---/+++ lines,
+			// e.g. for binary files and pure renames
+			if _, name, ok := strings.Cut(line, " b/"); ok {
+				current.Filename = name
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		if patch != nil || strings.HasPrefix(line, "@@") {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				current.Additions++
+			case strings.HasPrefix(line, "-"):
+				current.Deletions++
+			}
+			patch = append(patch, line)
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "new file mode"):
+			current.Status = "added"
+		case strings.HasPrefix(line, "deleted file mode"):
+			current.Status = "removed"
+		case strings.HasPrefix(line, "rename from "):
+			current.Status = "renamed"
+			current.PreviousFilename = strings.TrimPrefix(line, "rename from ")
+		case strings.HasPrefix(line, "rename to "):
+			current.Filename = strings.TrimPrefix(line, "rename to ")
+		case strings.HasPrefix(line, "--- a/"):
+			if current.Status == "removed" {
+				current.Filename = strings.TrimPrefix(line, "--- a/")
+			}
+		case strings.HasPrefix(line, "+++ b/"):
+			current.Filename = strings.TrimPrefix(line, "+++ b/")
+		}
+	}
+	flush()
+	return changes
+}
+
+// gitFetcher reads files from a local repository, at a commit or from the working tree
+type gitFetcher struct {
+	dir string
+}
+
+// GetFileContent returns the contents of a file at ref
+func (g gitFetcher) GetFileContent(ctx context.Context, owner, repo, file, ref string) (string, error) {
+	if ref == index {
+		return runGit(ctx, g.dir, "show", ":"+file)
+	}
+	if ref == workingTree {
+		if !filepath.IsLocal(file) {
+			return "", fmt.Errorf("invalid path %q", file)
+		}
+		content, err := os.ReadFile(filepath.Join(g.dir, file))
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		return string(content), nil
+	}
+	return runGit(ctx, g.dir, "show", ref+":"+file)
+}
+
+// ListDirectory returns the paths of the files directly inside a directory at ref.
+// The index is listed like the working tree, which it rarely differs from in
+// which files exist
+func (g gitFetcher) ListDirectory(ctx context.Context, owner, repo, dir, ref string) ([]string, error) {
+	var paths []string
+	if ref == workingTree || ref == index {
+		if dir != "" && !filepath.IsLocal(dir) {
+			return nil, fmt.Errorf("invalid directory %q", dir)
+		}
+		entries, err := os.ReadDir(filepath.Join(g.dir, dir))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list directory %s: %w", dir, err)
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				paths = append(paths, path.Join(dir, entry.Name()))
+			}
+		}
+		return paths, nil
+	}
+
+	treeish := ref
+	if dir != "" {
+		treeish += ":" + dir
+	}
+	out, err := runGit(ctx, g.dir, "ls-tree", treeish)
+	if err != nil {
+		return nil, err
+	}
+	// Entries look like "<mode> <type> <object>\t<name>"
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		info, name, ok := strings.Cut(line, "\t")
+		if ok && strings.Contains(info, " blob ") {
+			paths = append(paths, path.Join(dir, name))
+		}
+	}
+	return paths, nil
+}
+
+// gitWorktree checks out ref into a temporary worktree of the repository
+func gitWorktree(ctx context.Context, repoDir, ref string) (string, func(), error) {
+	dir, err := os.MkdirTemp("", "codereview-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create worktree: %w", err)
+	}
+	if _, err := runGit(ctx, repoDir, "worktree", "add", "--quiet", "--detach", dir, ref); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+	cleanup := func() {
+		if _, err := runGit(context.Background(), repoDir, "worktree", "remove", "--force", dir); err != nil {
+			log.Printf("Failed to remove worktree %s: %v", dir, err)
+		}
+	}
+	return dir, cleanup, nil
+}
+
+// gitCheckoutIndex copies the files in the index into a temporary directory
+func gitCheckoutIndex(ctx context.Context, repoDir string) (string, func(), error) {
+	dir, err := os.MkdirTemp("", "codereview-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create checkout: %w", err)
+	}
+	if _, err := runGit(ctx, repoDir, "checkout-index", "--all", "--prefix="+dir+string(filepath.Separator)); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+	return dir, func() { os.RemoveAll(dir) }, nil
+}
+
+// runGit runs a git command in dir and returns its output
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
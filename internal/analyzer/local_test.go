@@ -0,0 +1,127 @@
+package analyzer
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseDiff(t *testing.T) {
+	diff := `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,3 @@
+ package main
+-var x = 1
++var x = 2
+ // end
+diff --git a/new.go b/new.go
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ b/new.go
+@@ -0,0 +1,2 @@
++package main
++var y = 3
+diff --git a/gone.go b/gone.go
+deleted file mode 100644
+index 4444444..0000000
+--- a/gone.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package main
+diff --git a/old name.go b/new name.go
+similarity index 100%
+rename from old name.go
+rename to new name.go
+diff --git a/logo.png b/logo.png
+index 5555555..6666666 100644
+Binary files a/logo.png and b/logo.png differ
+`
+	changes := ParseDiff(diff)
+	if len(changes) != 5 {
+		t.Fatalf("Expected 5 changes, got %d", len(changes))
+	}
+
+	expected := []struct {
+		filename, previous, status string
+		additions, deletions       int
+	}{
+		{"main.go", "", "modified", 1, 1},
+		{"new.go", "", "added", 2, 0},
+		{"gone.go", "", "removed", 0, 1},
+		{"new name.go", "old name.go", "renamed", 0, 0},
+		{"logo.png", "", "modified", 0, 0},
+	}
+	for i, e := range expected {
+		c := changes[i]
+		if c.Filename != e.filename || c.PreviousFilename != e.previous || c.Status != e.status ||
+			c.Additions != e.additions || c.Deletions != e.deletions {
+			t.Errorf("Expected %+v, got %s %s %s +%d -%d", e, c.Filename, c.PreviousFilename, c.Status, c.Additions, c.Deletions)
+		}
+	}
+
+	expectedPatch := "@@ -1,3 +1,3 @@\n package main\n-var x = 1\n+var x = 2\n // end"
+	if changes[0].Patch != expectedPatch {
+		t.Errorf("Expected patch %q, got %q", expectedPatch, changes[0].Patch)
+	}
+	if changes[4].Patch != "" {
+		t.Errorf("Expected no patch for a binary file, got %q", changes[4].Patch)
+	}
+}
+
+func TestGitFetcher(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	dir := t.TempDir()
+	ctx := context.Background()
+	run := func(args ...string) {
+		if _, err := runGit(ctx, dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run("init", "--quiet")
+	os.MkdirAll(filepath.Join(dir, "pkg"), 0o755)
+	os.WriteFile(filepath.Join(dir, "pkg", "a.go"), []byte("package pkg\n"), 0o644)
+	os.MkdirAll(filepath.Join(dir, "pkg", "sub"), 0o755)
+	os.WriteFile(filepath.Join(dir, "pkg", "sub", "b.go"), []byte("package sub\n"), 0o644)
+	run("add", ".")
+	run("-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "initial")
+	os.WriteFile(filepath.Join(dir, "pkg", "a.go"), []byte("package pkg // staged\n"), 0o644)
+	run("add", "pkg/a.go")
+	os.WriteFile(filepath.Join(dir, "pkg", "a.go"), []byte("package pkg // edited\n"), 0o644)
+
+	fetcher := gitFetcher{dir: dir}
+
+	committed, err := fetcher.GetFileContent(ctx, "", "", "pkg/a.go", "HEAD")
+	if err != nil || committed != "package pkg\n" {
+		t.Errorf("Expected committed contents, got %q (%v)", committed, err)
+	}
+	edited, err := fetcher.GetFileContent(ctx, "", "", "pkg/a.go", workingTree)
+	if err != nil || edited != "package pkg // edited\n" {
+		t.Errorf("Expected working tree contents, got %q (%v)", edited, err)
+	}
+	staged, err := fetcher.GetFileContent(ctx, "", "", "pkg/a.go", index)
+	if err != nil || staged != "package pkg // staged\n" {
+		t.Errorf("Expected staged contents, got %q (%v)", staged, err)
+	}
+	if _, err := fetcher.GetFileContent(ctx, "", "", "../outside", workingTree); err == nil {
+		t.Error("Expected error for a path outside the repository")
+	}
+
+	for _, ref := range []string{"HEAD", workingTree} {
+		paths, err := fetcher.ListDirectory(ctx, "", "", "pkg", ref)
+		if err != nil {
+			t.Fatalf("ListDirectory(%s) failed: %v", ref, err)
+		}
+		if !reflect.DeepEqual(paths, []string{"pkg/a.go"}) {
+			t.Errorf("Expected only the files in pkg at %s, got %v", ref, paths)
+		}
+	}
+}
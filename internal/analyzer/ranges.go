@@ -0,0 +1,129 @@
+package analyzer
+
+import (
+	"context"
+	"strings"
+
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+const (
+	sideRight = "RIGHT"
+	sideLeft  = "LEFT"
+)
+
+// normalizeScopes decides where each comment can be posted. GitHub rejects a whole
+// review if one of its line comments isn't on a line in the diff, so comments
+// without a usable line become file-level comments, and comments without a
+// changed file become general comments
+func normalizeScopes(changes []llm.FileChange, review *llm.CodeReviewResponse) {
+	byName := make(map[string]llm.FileChange, len(changes))
+	for _, change := range changes {
+		byName[change.Filename] = change
+	}
+
+	for i := range review.Comments {
+		comment := &review.Comments[i]
+		change, ok := byName[comment.Filename]
+
+		switch {
+		case comment.Filename == "" || !ok || strings.EqualFold(comment.Scope, llm.ScopeGeneral):
+			comment.Scope = llm.ScopeGeneral
+		case comment.Line <= 0 || strings.EqualFold(comment.Scope, llm.ScopeFile):
+			comment.Scope = llm.ScopeFile
+		case change.Patch == "":
+			// Binary and very large files have no patch to comment on
+			comment.Scope = llm.ScopeFile
+		case strings.EqualFold(comment.Side, sideLeft):
+			comment.Scope = lineScope(parseOldHunks(change.Patch), comment.Line)
+		case change.Status == "removed":
+			comment.Scope = llm.ScopeFile
+		default:
+			comment.Scope = lineScope(parseHunks(change.Patch), comment.Line)
+		}
+	}
+}
+
+// lineScope returns the line scope if line lies in one of the hunks, or the file scope
+func lineScope(hunks []hunk, line int) string {
+	if withinHunk(hunks, line, line) {
+		return llm.ScopeLine
+	}
+	return llm.ScopeFile
+}
+
+// normalizeRanges makes the ranges of line comments acceptable to GitHub. A range
+// must run forwards and lie within a single hunk on its side of the diff;
+// otherwise the comment is pinned to its last line
+func normalizeRanges(changes []llm.FileChange, review *llm.CodeReviewResponse) {
+	patches := make(map[string]string, len(changes))
+	for _, change := range changes {
+		patches[change.Filename] = change.Patch
+	}
+
+	for i := range review.Comments {
+		comment := &review.Comments[i]
+
+		comment.Side = strings.ToUpper(strings.TrimSpace(comment.Side))
+		if comment.Side != sideLeft {
+			comment.Side = sideRight
+		}
+
+		if comment.Scope != llm.ScopeLine {
+			comment.StartLine = 0
+			continue
+		}
+
+		if comment.StartLine <= 0 || comment.StartLine >= comment.Line {
+			comment.StartLine = 0
+			continue
+		}
+
+		hunks := parseHunks(patches[comment.Filename])
+		if comment.Side == sideLeft {
+			hunks = parseOldHunks(patches[comment.Filename])
+		}
+		if !withinHunk(hunks, comment.StartLine, comment.Line) {
+			comment.StartLine = 0
+		}
+	}
+}
+
+// withinHunk reports whether lines start through end lie in a single hunk
+func withinHunk(hunks []hunk, start, end int) bool {
+	for _, h := range hunks {
+		if start >= h.Start && end <= h.End() {
+			return true
+		}
+	}
+	return false
+}
+
+// attachSnippets records the head text of the lines each comment covers, which
+// identifies a finding across pushes that move it to other lines. Files that can't
+// be read leave their comments without a snippet
+func attachSnippets(ctx context.Context, fetcher fileFetcher, pr PullRequest, review *llm.CodeReviewResponse) {
+	files := make(map[string][]string)
+	for i := range review.Comments {
+		comment := &review.Comments[i]
+		if comment.Filename == "" || comment.Line <= 0 || comment.Side == sideLeft {
+			continue
+		}
+		lines, ok := files[comment.Filename]
+		if !ok {
+			content, err := fetcher.GetFileContent(ctx, pr.Owner, pr.Repo, comment.Filename, pr.HeadSHA)
+			if err == nil {
+				lines = strings.Split(content, "\n")
+			}
+			files[comment.Filename] = lines
+		}
+
+		start := comment.Line
+		if comment.StartLine > 0 && comment.StartLine < comment.Line {
+			start = comment.StartLine
+		}
+		if comment.Line <= len(lines) {
+			comment.Snippet = strings.Join(lines[start-1:comment.Line], "\n")
+		}
+	}
+}
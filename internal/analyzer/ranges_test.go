@@ -0,0 +1,100 @@
+package analyzer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+func TestNormalizeRanges(t *testing.T) {
+	changes := []llm.FileChange{{
+		Filename: "a.go",
+		Patch:    "@@ -10,4 +10,6 @@\n ctx\n-old\n+new\n+new\n+new\n ctx\n ctx\n@@ -40,2 +42,2 @@\n-x\n+y\n ctx",
+	}}
+	review := &llm.CodeReviewResponse{Comments: []llm.ReviewComment{
+		{Scope: llm.ScopeLine, Filename: "a.go", StartLine: 10, Line: 15, Side: "right"},
+		{Scope: llm.ScopeLine, Filename: "a.go", StartLine: 12, Line: 43},
+		{Scope: llm.ScopeLine, Filename: "a.go", StartLine: 9, Line: 5},
+		{Scope: llm.ScopeLine, Filename: "a.go", StartLine: 10, Line: 13, Side: "LEFT"},
+		{Scope: llm.ScopeLine, Filename: "a.go", StartLine: 40, Line: 41, Side: "left"},
+	}}
+
+	normalizeRanges(changes, review)
+
+	expected := []struct {
+		start int
+		side  string
+	}{
+		{10, "RIGHT"},
+		{0, "RIGHT"}, // spans two hunks
+		{0, "RIGHT"}, // runs backwards
+		{10, "LEFT"},
+		{40, "LEFT"},
+	}
+	for i, want := range expected {
+		got := review.Comments[i]
+		if got.StartLine != want.start || got.Side != want.side {
+			t.Errorf("Comment %d: expected start %d on %s, got %d on %s", i, want.start, want.side, got.StartLine, got.Side)
+		}
+	}
+}
+
+func TestNormalizeScopes(t *testing.T) {
+	changes := []llm.FileChange{
+		{Filename: "a.go", Status: "modified", Patch: "@@ -10,2 +10,3 @@\n ctx\n-old\n+new\n+new"},
+		{Filename: "gone.go", Status: "removed", Patch: "@@ -1,2 +0,0 @@\n-package gone\n-"},
+		{Filename: "logo.png", Status: "added"},
+	}
+	review := &llm.CodeReviewResponse{Comments: []llm.ReviewComment{
+		{Filename: "a.go", Line: 11},
+		{Filename: "a.go", Line: 50},
+		{Filename: "a.go", Line: 11, Scope: "file"},
+		{Filename: "a.go", Line: 0},
+		{Filename: "gone.go", Line: 1},
+		{Filename: "gone.go", Line: 1, Side: "LEFT"},
+		{Filename: "logo.png", Line: 1},
+		{Filename: "", Line: 0},
+		{Filename: "other.go", Line: 3},
+	}}
+
+	normalizeScopes(changes, review)
+
+	expected := []string{
+		llm.ScopeLine,
+		llm.ScopeFile, // outside the diff
+		llm.ScopeFile,
+		llm.ScopeFile,
+		llm.ScopeFile, // removed file has no new side
+		llm.ScopeLine,
+		llm.ScopeFile, // binary file
+		llm.ScopeGeneral,
+		llm.ScopeGeneral, // not part of the pull request
+	}
+	for i, want := range expected {
+		if got := review.Comments[i].Scope; got != want {
+			t.Errorf("Comment %d: expected scope %s, got %s", i, want, got)
+		}
+	}
+}
+
+func TestAttachSnippets(t *testing.T) {
+	fetcher := &mockFetcher{files: map[string]string{"service/math.go": suggestionSource}}
+	review := &llm.CodeReviewResponse{Comments: []llm.ReviewComment{
+		{Filename: "service/math.go", StartLine: 3, Line: 4, Side: sideRight},
+		{Filename: "service/math.go", Line: 4, Side: sideLeft},
+		{Filename: "service/math.go", Line: 40},
+		{Filename: "missing.go", Line: 1},
+	}}
+
+	attachSnippets(context.Background(), fetcher, PullRequest{}, review)
+
+	if got := review.Comments[0].Snippet; got != "func Add(a, b int) int {\n\treturn a - b" {
+		t.Errorf("Expected the commented lines, got %q", got)
+	}
+	for _, c := range review.Comments[1:] {
+		if c.Snippet != "" {
+			t.Errorf("Expected no snippet for %s:%d (%s), got %q", c.Filename, c.Line, c.Side, c.Snippet)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/carlr/codereviewtool/internal/lint"
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+// changedLines returns the new-side line numbers added by a patch
+func changedLines(patch string) map[int]bool {
+	lines := make(map[int]bool)
+	current := 0
+	for _, line := range strings.Split(patch, "\n") {
+		if m := hunkHeaderRe.FindStringSubmatch(line); m != nil {
+			current, _ = strconv.Atoi(m[1])
+			continue
+		}
+		if current == 0 {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "+"):
+			lines[current] = true
+			current++
+		case strings.HasPrefix(line, "-"), strings.HasPrefix(line, "\\"):
+			// Removed lines and "\ No newline at end of file" don't advance the new side
+		default:
+			current++
+		}
+	}
+	return lines
+}
+
+// runStaticAnalysis checks out the head of the change and runs the configured
+// linters, keeping only findings on lines the change adds
+func (a *Analyzer) runStaticAnalysis(ctx context.Context, checkout checkoutFunc, changes []llm.FileChange) ([]llm.StaticFinding, error) {
+	if a.options.StaticAnalysisTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.options.StaticAnalysisTimeout)
+		defer cancel()
+	}
+
+	changed := make(map[string]map[int]bool)
+	var files []string
+	for _, change := range changes {
+		if change.Status == "removed" || change.Patch == "" {
+			continue
+		}
+		changed[change.Filename] = changedLines(change.Patch)
+		files = append(files, change.Filename)
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	dir, cleanup, err := checkout(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	findings := lint.FilterChanged(lint.Run(ctx, dir, a.options.Linters, files), changed)
+
+	result := make([]llm.StaticFinding, 0, len(findings))
+	for _, f := range findings {
+		result = append(result, llm.StaticFinding{
+			Tool:     f.Tool,
+			Rule:     f.Rule,
+			Filename: f.Filename,
+			Line:     f.Line,
+			Message:  f.Message,
+			Severity: f.Severity,
+			Category: f.Category,
+		})
+	}
+	return result, nil
+}
+
+// mergeStaticFindings adds tool findings the model didn't already comment on to the review
+func mergeStaticFindings(review *llm.CodeReviewResponse, findings []llm.StaticFinding) {
+	commented := make(map[string]bool, len(review.Comments))
+	for _, c := range review.Comments {
+		commented[fmt.Sprintf("%s:%d", c.Filename, c.Line)] = true
+	}
+
+	for _, f := range findings {
+		if commented[fmt.Sprintf("%s:%d", f.Filename, f.Line)] {
+			continue
+		}
+		body := f.Message
+		if f.Rule != "" {
+			body = fmt.Sprintf("%s (%s)", f.Message, f.Rule)
+		}
+		review.Comments = append(review.Comments, llm.ReviewComment{
+			Scope:    llm.ScopeLine,
+			Filename: f.Filename,
+			Line:     f.Line,
+			Side:     sideRight,
+			Body:     fmt.Sprintf("%s: %s", f.Tool, body),
+			Severity: f.Severity,
+			Category: f.Category,
+			Source:   f.Tool,
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+func TestChangedLines(t *testing.T) {
+	patch := "@@ -1,4 +1,5 @@\n context\n-removed\n+added one\n+added two\n context\n\\ No newline at end of file\n@@ -20,2 +21,2 @@\n context\n+added three"
+
+	lines := changedLines(patch)
+
+	for _, line := range []int{2, 3, 22} {
+		if !lines[line] {
+			t.Errorf("Expected line %d to be marked as changed", line)
+		}
+	}
+	if len(lines) != 3 {
+		t.Errorf("Expected 3 changed lines, got %d", len(lines))
+	}
+}
+
+func TestMergeStaticFindings(t *testing.T) {
+	review := &llm.CodeReviewResponse{
+		Comments: []llm.ReviewComment{{Filename: "a.go", Line: 3, Body: "explained by model", Severity: "error"}},
+	}
+	findings := []llm.StaticFinding{
+		{Tool: "go vet", Filename: "a.go", Line: 3, Message: "printf mismatch", Severity: "warning"},
+		{Tool: "staticcheck", Rule: "SA4006", Filename: "a.go", Line: 7, Message: "value never used", Severity: "error"},
+	}
+
+	mergeStaticFindings(review, findings)
+
+	if len(review.Comments) != 2 {
+		t.Fatalf("Expected 2 comments after merge, got %d", len(review.Comments))
+	}
+	added := review.Comments[1]
+	if added.Source != "staticcheck" || added.Line != 7 || added.Body != "staticcheck: value never used (SA4006)" {
+		t.Errorf("Unexpected merged comment: %+v", added)
+	}
+}
@@ -0,0 +1,95 @@
+package analyzer
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+// validateSuggestions drops suggested changes that would not apply cleanly to the
+// head of the pull request, and anchors the remaining comments on the lines their
+// suggestion replaces
+func validateSuggestions(ctx context.Context, fetcher fileFetcher, pr PullRequest, changes []llm.FileChange, review *llm.CodeReviewResponse) {
+	patches := make(map[string]string, len(changes))
+	for _, change := range changes {
+		if change.Status != "removed" {
+			patches[change.Filename] = change.Patch
+		}
+	}
+
+	for i := range review.Comments {
+		comment := &review.Comments[i]
+		if comment.Suggestion == nil {
+			continue
+		}
+		if comment.Scope != llm.ScopeLine {
+			comment.Suggestion = nil
+			continue
+		}
+
+		if reason := checkSuggestion(ctx, fetcher, pr, patches, comment); reason != "" {
+			log.Printf("Dropping suggestion on %s:%d: %s", comment.Filename, comment.Line, reason)
+			comment.Suggestion = nil
+			continue
+		}
+
+		// GitHub applies a suggestion to the lines the comment spans
+		comment.StartLine = 0
+		if comment.Suggestion.StartLine < comment.Suggestion.EndLine {
+			comment.StartLine = comment.Suggestion.StartLine
+		}
+		comment.Line = comment.Suggestion.EndLine
+		comment.Side = sideRight
+	}
+}
+
+// checkSuggestion returns why a suggestion can't be applied, or an empty string if it can
+func checkSuggestion(ctx context.Context, fetcher fileFetcher, pr PullRequest, patches map[string]string, comment *llm.ReviewComment) string {
+	s := comment.Suggestion
+	if s.StartLine == 0 && s.EndLine == 0 {
+		s.StartLine, s.EndLine = comment.Line, comment.Line
+	}
+	if s.EndLine == 0 {
+		s.EndLine = s.StartLine
+	}
+	if s.StartLine < 1 || s.EndLine < s.StartLine {
+		return "invalid line range"
+	}
+
+	patch, ok := patches[comment.Filename]
+	if !ok || patch == "" {
+		return "file is not part of the diff"
+	}
+	if !withinHunk(parseHunks(patch), s.StartLine, s.EndLine) {
+		return "lines are outside a single changed hunk"
+	}
+
+	content, err := fetcher.GetFileContent(ctx, pr.Owner, pr.Repo, comment.Filename, pr.HeadSHA)
+	if err != nil {
+		return "failed to fetch file: " + err.Error()
+	}
+	lines := strings.Split(content, "\n")
+	if s.EndLine > len(lines) {
+		return "lines are past the end of the file"
+	}
+
+	current := strings.Join(lines[s.StartLine-1:s.EndLine], "\n")
+	if s.Original != "" && normalizeCode(s.Original) != normalizeCode(current) {
+		return "original text doesn't match the file"
+	}
+	if normalizeCode(s.Replacement) == normalizeCode(current) {
+		return "replacement doesn't change anything"
+	}
+	return ""
+}
+
+// normalizeCode ignores trailing whitespace and line ending differences when comparing code
+func normalizeCode(code string) string {
+	lines := strings.Split(strings.ReplaceAll(code, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
@@ -0,0 +1,100 @@
+package analyzer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+const suggestionSource = `package service
+
+func Add(a, b int) int {
+	return a - b
+}
+`
+
+func TestValidateSuggestions(t *testing.T) {
+	fetcher := &mockFetcher{files: map[string]string{"service/math.go": suggestionSource}}
+	changes := []llm.FileChange{{
+		Filename: "service/math.go",
+		Status:   "added",
+		Patch:    "@@ -0,0 +1,5 @@\n+package service\n+\n+func Add(a, b int) int {\n+\treturn a - b\n+}",
+	}}
+	review := &llm.CodeReviewResponse{Comments: []llm.ReviewComment{
+		{
+			Scope:      llm.ScopeLine,
+			Filename:   "service/math.go",
+			Line:       3,
+			Body:       "Add subtracts",
+			Suggestion: &llm.Suggestion{StartLine: 4, EndLine: 4, Original: "\treturn a - b  ", Replacement: "\treturn a + b"},
+		},
+		{
+			Scope:      llm.ScopeLine,
+			Filename:   "service/math.go",
+			Line:       4,
+			Body:       "Wrong original",
+			Suggestion: &llm.Suggestion{StartLine: 4, EndLine: 4, Original: "\treturn a * b", Replacement: "\treturn a + b"},
+		},
+		{
+			Scope:      llm.ScopeLine,
+			Filename:   "service/math.go",
+			Line:       4,
+			Body:       "Past the end",
+			Suggestion: &llm.Suggestion{StartLine: 4, EndLine: 40, Replacement: "}"},
+		},
+		{
+			Scope:      llm.ScopeLine,
+			Filename:   "service/math.go",
+			Line:       4,
+			Body:       "No-op",
+			Suggestion: &llm.Suggestion{StartLine: 4, EndLine: 4, Replacement: "\treturn a - b"},
+		},
+		{
+			Scope:      llm.ScopeLine,
+			Filename:   "service/other.go",
+			Line:       1,
+			Body:       "Not in diff",
+			Suggestion: &llm.Suggestion{StartLine: 1, EndLine: 1, Replacement: "package other"},
+		},
+	}}
+
+	validateSuggestions(context.Background(), fetcher, PullRequest{Owner: "o", Repo: "r", HeadSHA: "head"}, changes, review)
+
+	if len(review.Comments) != 5 {
+		t.Fatalf("Expected comments to be kept, got %d", len(review.Comments))
+	}
+	if review.Comments[0].Suggestion == nil {
+		t.Fatal("Expected valid suggestion to be kept")
+	}
+	if review.Comments[0].Line != 4 {
+		t.Errorf("Expected comment to move to line 4, got %d", review.Comments[0].Line)
+	}
+	for _, c := range review.Comments[1:] {
+		if c.Suggestion != nil {
+			t.Errorf("Expected suggestion on %q to be dropped", c.Body)
+		}
+	}
+}
+
+func TestValidateSuggestions_OutsideHunk(t *testing.T) {
+	fetcher := &mockFetcher{files: map[string]string{"service/math.go": suggestionSource}}
+	changes := []llm.FileChange{{
+		Filename: "service/math.go",
+		Status:   "modified",
+		Patch:    "@@ -4,1 +4,1 @@\n-\treturn a\n+\treturn a - b",
+	}}
+	review := &llm.CodeReviewResponse{Comments: []llm.ReviewComment{{
+		Scope:      llm.ScopeLine,
+		Filename:   "service/math.go",
+		Line:       4,
+		Body:       "Spans unchanged lines",
+		Suggestion: &llm.Suggestion{StartLine: 3, EndLine: 4, Replacement: "func Add(a, b int) int {\n\treturn a + b"},
+	}}}
+
+	validateSuggestions(context.Background(), fetcher, PullRequest{HeadSHA: "head"}, changes, review)
+
+	if review.Comments[0].Suggestion != nil {
+		t.Error("Expected suggestion spanning lines outside the hunk to be dropped")
+	}
+}
@@ -0,0 +1,94 @@
+package ci
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PullRequest identifies the pull request a CI build runs for
+type PullRequest struct {
+	Owner  string
+	Repo   string
+	Number int
+}
+
+var (
+	// pullURLRe matches pull request URLs such as https://github.com/owner/repo/pull/1
+	pullURLRe = regexp.MustCompile(`/([^/]+)/([^/]+)/pull/(\d+)/?$`)
+	// repoURLRe matches the owner and name at the end of a clone URL
+	repoURLRe = regexp.MustCompile(`[/:]([^/:]+)/([^/]+?)(?:\.git)?/?$`)
+	// pullRefRe matches the refs GitHub Actions checks out for pull requests
+	pullRefRe = regexp.MustCompile(`^refs/pull/(\d+)/`)
+)
+
+// Detect finds the pull request from the environment variables of GitHub Actions,
+// CircleCI, Travis CI, Buildkite or Jenkins. getenv is usually os.Getenv
+func Detect(getenv func(string) string) (PullRequest, bool) {
+	switch {
+	case getenv("GITHUB_ACTIONS") == "true":
+		return githubActions(getenv)
+	case getenv("CIRCLECI") == "true":
+		if pr, ok := fromURL(getenv("CIRCLE_PULL_REQUEST")); ok {
+			return pr, true
+		}
+	case getenv("TRAVIS") == "true":
+		return withSlug(getenv("TRAVIS_REPO_SLUG"), getenv("TRAVIS_PULL_REQUEST"))
+	case getenv("BUILDKITE") == "true":
+		m := repoURLRe.FindStringSubmatch(getenv("BUILDKITE_REPO"))
+		if m == nil {
+			return PullRequest{}, false
+		}
+		return withSlug(m[1]+"/"+m[2], getenv("BUILDKITE_PULL_REQUEST"))
+	case getenv("JENKINS_URL") != "":
+		if pr, ok := fromURL(getenv("CHANGE_URL")); ok {
+			return pr, true
+		}
+	}
+	return PullRequest{}, false
+}
+
+// githubActions reads the pull request from the event payload, falling back to
+// the checked out ref
+func githubActions(getenv func(string) string) (PullRequest, bool) {
+	number := 0
+	if data, err := os.ReadFile(getenv("GITHUB_EVENT_PATH")); err == nil {
+		var event struct {
+			PullRequest struct {
+				Number int `json:"number"`
+			} `json:"pull_request"`
+		}
+		if json.Unmarshal(data, &event) == nil {
+			number = event.PullRequest.Number
+		}
+	}
+	if number == 0 {
+		if m := pullRefRe.FindStringSubmatch(getenv("GITHUB_REF")); m != nil {
+			number, _ = strconv.Atoi(m[1])
+		}
+	}
+	return withSlug(getenv("GITHUB_REPOSITORY"), strconv.Itoa(number))
+}
+
+// fromURL parses a pull request URL
+func fromURL(url string) (PullRequest, bool) {
+	m := pullURLRe.FindStringSubmatch(url)
+	if m == nil {
+		return PullRequest{}, false
+	}
+	number, _ := strconv.Atoi(m[3])
+	return PullRequest{Owner: m[1], Repo: m[2], Number: number}, true
+}
+
+// withSlug combines an owner/repo slug with a pull request number, which CI
+// services set to "false" or leave empty for builds of branches
+func withSlug(slug, number string) (PullRequest, bool) {
+	owner, repo, ok := strings.Cut(slug, "/")
+	n, err := strconv.Atoi(number)
+	if !ok || owner == "" || repo == "" || err != nil || n <= 0 {
+		return PullRequest{}, false
+	}
+	return PullRequest{Owner: owner, Repo: repo, Number: n}, true
+}
@@ -0,0 +1,68 @@
+package ci
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+func TestDetect(t *testing.T) {
+	eventPath := filepath.Join(t.TempDir(), "event.json")
+	os.WriteFile(eventPath, []byte(`{"action":"opened","pull_request":{"number":12}}`), 0o644)
+
+	tests := []struct {
+		name string
+		vars map[string]string
+		want PullRequest
+		ok   bool
+	}{
+		{
+			name: "GitHub Actions event payload",
+			vars: map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_REPOSITORY": "octo/repo", "GITHUB_EVENT_PATH": eventPath},
+			want: PullRequest{"octo", "repo", 12}, ok: true,
+		},
+		{
+			name: "GitHub Actions pull request ref",
+			vars: map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_REPOSITORY": "octo/repo", "GITHUB_REF": "refs/pull/7/merge"},
+			want: PullRequest{"octo", "repo", 7}, ok: true,
+		},
+		{
+			name: "GitHub Actions push",
+			vars: map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_REPOSITORY": "octo/repo", "GITHUB_REF": "refs/heads/main"},
+		},
+		{
+			name: "CircleCI",
+			vars: map[string]string{"CIRCLECI": "true", "CIRCLE_PULL_REQUEST": "https://github.com/octo/repo/pull/3"},
+			want: PullRequest{"octo", "repo", 3}, ok: true,
+		},
+		{
+			name: "Travis CI branch build",
+			vars: map[string]string{"TRAVIS": "true", "TRAVIS_REPO_SLUG": "octo/repo", "TRAVIS_PULL_REQUEST": "false"},
+		},
+		{
+			name: "Buildkite",
+			vars: map[string]string{"BUILDKITE": "true", "BUILDKITE_REPO": "git@github.com:octo/repo.git", "BUILDKITE_PULL_REQUEST": "5"},
+			want: PullRequest{"octo", "repo", 5}, ok: true,
+		},
+		{
+			name: "Jenkins",
+			vars: map[string]string{"JENKINS_URL": "https://ci.example.com/", "CHANGE_URL": "https://github.com/octo/repo/pull/9"},
+			want: PullRequest{"octo", "repo", 9}, ok: true,
+		},
+		{
+			name: "no CI",
+			vars: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		got, ok := Detect(env(tt.vars))
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("%s: expected %+v (%v), got %+v (%v)", tt.name, tt.want, tt.ok, got, ok)
+		}
+	}
+}
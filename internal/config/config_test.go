@@ -86,3 +86,119 @@ func TestValidate_ValidConfig(t *testing.T) {
 		t.Errorf("Expected valid config, got error: %v", err)
 	}
 }
+
+func TestValidate_InvalidReviewEvent(t *testing.T) {
+	cfg := &Config{
+		GitHubWebhookSecret: "test",
+		GitHubToken:         "test",
+		LLMProvider:         "openai",
+		OpenAIAPIKey:        "sk-test",
+		ReviewEventOnError:  "REJECT",
+		RabbitMQURL:         "test",
+		PostgresURL:         "test",
+	}
+	err := cfg.Validate()
+	if err == nil {
+		t.Error("Expected validation error for invalid review event")
+	}
+}
+
+func TestValidate_ApproveRequiresPaths(t *testing.T) {
+	cfg := &Config{
+		GitHubWebhookSecret: "test",
+		GitHubToken:         "test",
+		LLMProvider:         "openai",
+		OpenAIAPIKey:        "sk-test",
+		ReviewEventOnClean:  "APPROVE",
+		RabbitMQURL:         "test",
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected validation error for APPROVE without REVIEW_APPROVE_PATHS")
+	}
+
+	cfg.ReviewApprovePaths = []string{"docs/**"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected valid config, got error: %v", err)
+	}
+}
+
+func TestValidateFor_LocalRole(t *testing.T) {
+	cfg := &Config{
+		LLMProvider:  "openai",
+		OpenAIAPIKey: "sk-test",
+	}
+	if err := cfg.ValidateFor(RoleLocal); err != nil {
+		t.Errorf("Expected local config without GitHub or broker settings to be valid, got: %v", err)
+	}
+
+	cfg.OpenAIAPIKey = ""
+	if err := cfg.ValidateFor(RoleLocal); err == nil {
+		t.Error("Expected validation error for missing OpenAI API key")
+	}
+}
+
+func TestValidateFor_ListenerRole(t *testing.T) {
+	cfg := &Config{
+		GitHubWebhookSecret: "test",
+		RabbitMQURL:         "test",
+		PostgresURL:         "test",
+	}
+	if err := cfg.ValidateFor(RoleListener); err != nil {
+		t.Errorf("Expected listener config without a token or LLM to be valid, got: %v", err)
+	}
+	if err := cfg.ValidateFor(RoleWorker); err == nil {
+		t.Error("Expected worker validation error for missing GitHub token")
+	}
+}
+
+func TestValidateFor_QueueBackend(t *testing.T) {
+	cfg := &Config{
+		GitHubWebhookSecret: "test",
+		QueueBackend:        "redis",
+		PostgresURL:         "test",
+	}
+	if err := cfg.ValidateFor(RoleListener); err == nil {
+		t.Error("Expected validation error for missing REDIS_URL")
+	}
+
+	cfg.RedisURL = "redis://localhost:6379/0"
+	if err := cfg.ValidateFor(RoleListener); err != nil {
+		t.Errorf("Expected Redis queue config without RABBITMQ_URL to be valid, got: %v", err)
+	}
+
+	cfg.QueueBackend = "kafka"
+	if err := cfg.ValidateFor(RoleListener); err == nil {
+		t.Error("Expected validation error for invalid QUEUE_BACKEND")
+	}
+}
+
+func TestLoadFor_ServeDefaultsToMemoryQueue(t *testing.T) {
+	os.Setenv("GITHUB_WEBHOOK_SECRET", "test")
+	os.Setenv("GITHUB_TOKEN", "test")
+	os.Setenv("LLM_PROVIDER", "ollama")
+	os.Setenv("QUEUE_BACKEND", "")
+	os.Setenv("POSTGRES_URL", "")
+	defer func() {
+		os.Unsetenv("GITHUB_WEBHOOK_SECRET")
+		os.Unsetenv("GITHUB_TOKEN")
+		os.Unsetenv("LLM_PROVIDER")
+		os.Unsetenv("QUEUE_BACKEND")
+		os.Unsetenv("POSTGRES_URL")
+	}()
+
+	cfg, err := LoadFor(RoleServe)
+	if err != nil {
+		t.Fatalf("Expected serve config without a broker or Postgres to be valid, got: %v", err)
+	}
+	if cfg.QueueBackend != "memory" {
+		t.Errorf("Expected memory queue, got %s", cfg.QueueBackend)
+	}
+
+	cfg, err = LoadFor(RoleWorker)
+	if err != nil {
+		t.Fatalf("Failed to load worker config: %v", err)
+	}
+	if cfg.QueueBackend != "rabbitmq" {
+		t.Errorf("Expected separate processes to default to rabbitmq, got %s", cfg.QueueBackend)
+	}
+}
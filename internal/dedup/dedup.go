@@ -0,0 +1,186 @@
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// deliveryRetention is how long delivery IDs are remembered; GitHub only
+	// redelivers webhooks from the last three days
+	deliveryRetention = 72 * time.Hour
+
+	// claimTimeout is how long a review may run before its claim is considered
+	// abandoned, e.g. because the worker crashed, and another worker may take it
+	claimTimeout = time.Hour
+)
+
+// Store records webhook deliveries and the review state of pull request heads so
+// retried deliveries and duplicate jobs don't review the same commit twice
+type Store interface {
+	// ClaimDelivery records a webhook delivery ID and reports whether it was new;
+	// false means another request already claimed it. The check and the insert are
+	// atomic so concurrent redeliveries are only queued once
+	ClaimDelivery(ctx context.Context, id string) (bool, error)
+	// ForgetDelivery drops a claimed delivery ID, e.g. when queueing it failed, so
+	// GitHub's retry of it is processed
+	ForgetDelivery(ctx context.Context, id string) error
+
+	// SetHead records the newest head SHA pushed to a pull request
+	SetHead(ctx context.Context, pr, sha string) error
+	// Head returns the newest recorded head SHA of a pull request, or "" if unknown
+	Head(ctx context.Context, pr string) (string, error)
+
+	// Claim marks a head SHA as being reviewed and reports whether the caller
+	// should review it; false means it is already reviewed or in progress. Cancelled
+	// reviews can be claimed again, e.g. when the pull request is reopened
+	Claim(ctx context.Context, pr, sha string) (bool, error)
+	// Complete marks a claimed head SHA as reviewed unless it was cancelled
+	Complete(ctx context.Context, pr, sha string) error
+	// Release gives up a claim so the review can be retried
+	Release(ctx context.Context, pr, sha string) error
+
+	// Cancel marks the running reviews of a pull request as cancelled, so workers
+	// reviewing it in other processes don't post their results
+	Cancel(ctx context.Context, pr string) error
+	// Cancelled reports whether the review of a head SHA was cancelled
+	Cancelled(ctx context.Context, pr, sha string) (bool, error)
+}
+
+// Key identifies a pull request in a Store
+func Key(owner, repo string, number int) string {
+	return fmt.Sprintf("%s/%s#%d", owner, repo, number)
+}
+
+// job is the review state of one head SHA
+type job struct {
+	done      bool
+	cancelled bool
+	claimedAt time.Time
+}
+
+// memoryStore is a Store for a single process
+type memoryStore struct {
+	mu           sync.Mutex
+	deliveries   map[string]time.Time
+	heads        map[string]string
+	jobs         map[string]map[string]*job // pull request -> head SHA -> job
+	claimTimeout time.Duration
+	now          func() time.Time
+}
+
+// NewMemoryStore creates a Store held in memory. It only deduplicates within one
+// process; use the Postgres store when the listener and workers run separately
+func NewMemoryStore() Store {
+	return &memoryStore{
+		deliveries:   make(map[string]time.Time),
+		heads:        make(map[string]string),
+		jobs:         make(map[string]map[string]*job),
+		claimTimeout: claimTimeout,
+		now:          time.Now,
+	}
+}
+
+// ClaimDelivery records a webhook delivery ID unless it is already known,
+// forgetting expired ones
+func (s *memoryStore) ClaimDelivery(ctx context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for seen, at := range s.deliveries {
+		if now.Sub(at) > deliveryRetention {
+			delete(s.deliveries, seen)
+		}
+	}
+	if _, ok := s.deliveries[id]; ok {
+		return false, nil
+	}
+	s.deliveries[id] = now
+	return true, nil
+}
+
+// ForgetDelivery drops a claimed webhook delivery ID
+func (s *memoryStore) ForgetDelivery(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.deliveries, id)
+	return nil
+}
+
+// SetHead records the newest head SHA of a pull request. Finished reviews of
+// older heads are forgotten since jobs for them are superseded anyway
+func (s *memoryStore) SetHead(ctx context.Context, pr, sha string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.heads[pr] = sha
+	for old, j := range s.jobs[pr] {
+		if old != sha && j.done {
+			delete(s.jobs[pr], old)
+		}
+	}
+	return nil
+}
+
+// Head returns the newest recorded head SHA of a pull request
+func (s *memoryStore) Head(ctx context.Context, pr string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.heads[pr], nil
+}
+
+// Claim marks a head SHA as being reviewed unless it is done or still in progress
+func (s *memoryStore) Claim(ctx context.Context, pr, sha string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if j, ok := s.jobs[pr][sha]; ok && !j.cancelled && (j.done || now.Sub(j.claimedAt) < s.claimTimeout) {
+		return false, nil
+	}
+	if s.jobs[pr] == nil {
+		s.jobs[pr] = make(map[string]*job)
+	}
+	s.jobs[pr][sha] = &job{claimedAt: now}
+	return true, nil
+}
+
+// Complete marks a claimed head SHA as reviewed
+func (s *memoryStore) Complete(ctx context.Context, pr, sha string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if j, ok := s.jobs[pr][sha]; ok && !j.cancelled {
+		j.done = true
+	}
+	return nil
+}
+
+// Release gives up a claim so the review can be retried
+func (s *memoryStore) Release(ctx context.Context, pr, sha string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if j, ok := s.jobs[pr][sha]; ok && !j.done && !j.cancelled {
+		delete(s.jobs[pr], sha)
+	}
+	return nil
+}
+
+// Cancel marks the running reviews of a pull request as cancelled
+func (s *memoryStore) Cancel(ctx context.Context, pr string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs[pr] {
+		if !j.done {
+			j.cancelled = true
+		}
+	}
+	return nil
+}
+
+// Cancelled reports whether the review of a head SHA was cancelled
+func (s *memoryStore) Cancelled(ctx context.Context, pr, sha string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[pr][sha]
+	return ok && j.cancelled, nil
+}
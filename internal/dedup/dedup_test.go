@@ -0,0 +1,184 @@
+package dedup
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore_Claim(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	key := Key("octo", "repo", 1)
+
+	if ok, _ := store.Claim(ctx, key, "abc"); !ok {
+		t.Fatal("Expected first claim to succeed")
+	}
+	if ok, _ := store.Claim(ctx, key, "abc"); ok {
+		t.Error("Expected claim of a review in progress to fail")
+	}
+
+	// A failed review is released so the retry can claim it
+	store.Release(ctx, key, "abc")
+	if ok, _ := store.Claim(ctx, key, "abc"); !ok {
+		t.Error("Expected claim after release to succeed")
+	}
+
+	store.Complete(ctx, key, "abc")
+	store.Release(ctx, key, "abc")
+	if ok, _ := store.Claim(ctx, key, "abc"); ok {
+		t.Error("Expected claim of a completed review to fail")
+	}
+	if ok, _ := store.Claim(ctx, key, "def"); !ok {
+		t.Error("Expected claim of another head to succeed")
+	}
+}
+
+func TestMemoryStore_AbandonedClaim(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore().(*memoryStore)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	store.Claim(ctx, "pr", "abc")
+	now = now.Add(claimTimeout + time.Minute)
+	if ok, _ := store.Claim(ctx, "pr", "abc"); !ok {
+		t.Error("Expected an abandoned claim to be taken over")
+	}
+}
+
+func TestMemoryStore_Head(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore().(*memoryStore)
+
+	if head, _ := store.Head(ctx, "pr"); head != "" {
+		t.Errorf("Expected no head, got %q", head)
+	}
+
+	store.Claim(ctx, "pr", "abc")
+	store.Complete(ctx, "pr", "abc")
+	store.SetHead(ctx, "pr", "def")
+
+	if head, _ := store.Head(ctx, "pr"); head != "def" {
+		t.Errorf("Expected head def, got %q", head)
+	}
+	if _, ok := store.jobs["pr"]["abc"]; ok {
+		t.Error("Expected completed review of the old head to be forgotten")
+	}
+}
+
+func TestMemoryStore_Deliveries(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore().(*memoryStore)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	if ok, _ := store.ClaimDelivery(ctx, "d1"); !ok {
+		t.Error("Expected unknown delivery to be claimed")
+	}
+	if ok, _ := store.ClaimDelivery(ctx, "d1"); ok {
+		t.Error("Expected claimed delivery not to be claimed again")
+	}
+
+	store.ForgetDelivery(ctx, "d1")
+	if ok, _ := store.ClaimDelivery(ctx, "d1"); !ok {
+		t.Error("Expected forgotten delivery to be claimed again")
+	}
+
+	now = now.Add(deliveryRetention + time.Hour)
+	if ok, _ := store.ClaimDelivery(ctx, "d1"); !ok {
+		t.Error("Expected expired delivery to be claimed again")
+	}
+}
+
+func TestSQLiteStore_Claim(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(ctx, "sqlite", "", filepath.Join(t.TempDir(), "dedup.db"))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	key := Key("octo", "repo", 1)
+
+	if ok, err := store.Claim(ctx, key, "abc"); !ok || err != nil {
+		t.Fatalf("Expected first claim to succeed, got %v, %v", ok, err)
+	}
+	if ok, _ := store.Claim(ctx, key, "abc"); ok {
+		t.Error("Expected claim of a review in progress to fail")
+	}
+
+	store.Release(ctx, key, "abc")
+	if ok, _ := store.Claim(ctx, key, "abc"); !ok {
+		t.Error("Expected claim after release to succeed")
+	}
+
+	store.Complete(ctx, key, "abc")
+	if ok, _ := store.Claim(ctx, key, "abc"); ok {
+		t.Error("Expected claim of a completed review to fail")
+	}
+
+	store.SetHead(ctx, key, "def")
+	if head, _ := store.Head(ctx, key); head != "def" {
+		t.Errorf("Expected head def, got %q", head)
+	}
+
+	if ok, err := store.ClaimDelivery(ctx, "delivery-1"); !ok || err != nil {
+		t.Errorf("Expected unknown delivery to be claimed, got %v, %v", ok, err)
+	}
+	if ok, _ := store.ClaimDelivery(ctx, "delivery-1"); ok {
+		t.Error("Expected claimed delivery not to be claimed again")
+	}
+	store.ForgetDelivery(ctx, "delivery-1")
+	if ok, _ := store.ClaimDelivery(ctx, "delivery-1"); !ok {
+		t.Error("Expected forgotten delivery to be claimed again")
+	}
+}
+
+func TestSQLiteStore_AbandonedClaim(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(ctx, "sqlite", "", filepath.Join(t.TempDir(), "dedup.db"))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	s := store.(*sqliteStore)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	store.Claim(ctx, "pr", "abc")
+	now = now.Add(claimTimeout + time.Minute)
+	if ok, _ := store.Claim(ctx, "pr", "abc"); !ok {
+		t.Error("Expected an abandoned claim to be taken over")
+	}
+}
+
+func TestStore_Cancel(t *testing.T) {
+	ctx := context.Background()
+	sqliteStore, err := Open(ctx, "sqlite", "", filepath.Join(t.TempDir(), "dedup.db"))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+
+	for name, store := range map[string]Store{"memory": NewMemoryStore(), "sqlite": sqliteStore} {
+		store.Claim(ctx, "pr", "abc")
+		store.Claim(ctx, "other", "abc")
+		if err := store.Cancel(ctx, "pr"); err != nil {
+			t.Fatalf("%s: Failed to cancel: %v", name, err)
+		}
+		if cancelled, _ := store.Cancelled(ctx, "pr", "abc"); !cancelled {
+			t.Errorf("%s: Expected the running review to be cancelled", name)
+		}
+		if cancelled, _ := store.Cancelled(ctx, "other", "abc"); cancelled {
+			t.Errorf("%s: Expected reviews of other pull requests to keep running", name)
+		}
+
+		// A cancelled review isn't completed by the worker that ran it, and can be
+		// claimed again once the pull request is reopened
+		store.Complete(ctx, "pr", "abc")
+		if ok, _ := store.Claim(ctx, "pr", "abc"); !ok {
+			t.Errorf("%s: Expected a cancelled review to be claimed again", name)
+		}
+		if cancelled, _ := store.Cancelled(ctx, "pr", "abc"); cancelled {
+			t.Errorf("%s: Expected the new claim to be running", name)
+		}
+	}
+}
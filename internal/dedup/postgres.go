@@ -0,0 +1,175 @@
+package dedup
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/carlr/codereviewtool/internal/sqlite"
+)
+
+// postgresStore is a Store shared by every listener and worker using the database
+type postgresStore struct {
+	db *sql.DB
+}
+
+// Open creates the store named by kind: "memory", "postgres", "sqlite", or "none"
+// which returns a nil Store. The caller must register the Postgres driver
+func Open(ctx context.Context, kind, postgresURL, sqlitePath string) (Store, error) {
+	switch kind {
+	case "none":
+		return nil, nil
+	case "postgres":
+		db, err := sql.Open("postgres", postgresURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open Postgres: %w", err)
+		}
+		return NewPostgresStore(ctx, db)
+	case "sqlite":
+		db, err := sqlite.Open(sqlitePath)
+		if err != nil {
+			return nil, err
+		}
+		return NewSQLiteStore(ctx, db)
+	default:
+		return NewMemoryStore(), nil
+	}
+}
+
+// NewPostgresStore creates a Store backed by Postgres. Its tables are created by
+// migrations/003_webhook_deliveries.sql through 005_review_jobs.sql
+func NewPostgresStore(ctx context.Context, db *sql.DB) (Store, error) {
+	for _, table := range []string{"webhook_deliveries", "pull_request_heads", "review_jobs"} {
+		if _, err := db.ExecContext(ctx, "SELECT 1 FROM "+table+" LIMIT 0"); err != nil {
+			return nil, fmt.Errorf("deduplication table %s unavailable (apply the migrations): %w", table, err)
+		}
+	}
+	return &postgresStore{db: db}, nil
+}
+
+// ClaimDelivery records a webhook delivery ID unless it is already known,
+// forgetting expired ones
+func (s *postgresStore) ClaimDelivery(ctx context.Context, id string) (bool, error) {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM webhook_deliveries WHERE received_at < NOW() - $1 * INTERVAL '1 second'`,
+		int(deliveryRetention.Seconds()))
+	if err != nil {
+		return false, fmt.Errorf("failed to expire deliveries: %w", err)
+	}
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO webhook_deliveries (delivery_id) VALUES ($1) ON CONFLICT DO NOTHING`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim delivery: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim delivery: %w", err)
+	}
+	return n == 1, nil
+}
+
+// ForgetDelivery drops a claimed webhook delivery ID
+func (s *postgresStore) ForgetDelivery(ctx context.Context, id string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE delivery_id = $1`, id); err != nil {
+		return fmt.Errorf("failed to forget delivery: %w", err)
+	}
+	return nil
+}
+
+// SetHead records the newest head SHA of a pull request, forgetting finished
+// reviews of older heads
+func (s *postgresStore) SetHead(ctx context.Context, pr, sha string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO pull_request_heads (pr_key, head_sha, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (pr_key) DO UPDATE SET head_sha = EXCLUDED.head_sha, updated_at = NOW()`,
+		pr, sha)
+	if err != nil {
+		return fmt.Errorf("failed to record head: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx,
+		`DELETE FROM review_jobs WHERE pr_key = $1 AND head_sha <> $2 AND status = 'done'`, pr, sha)
+	if err != nil {
+		return fmt.Errorf("failed to expire review jobs: %w", err)
+	}
+	return nil
+}
+
+// Head returns the newest recorded head SHA of a pull request
+func (s *postgresStore) Head(ctx context.Context, pr string) (string, error) {
+	var sha string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT head_sha FROM pull_request_heads WHERE pr_key = $1`, pr,
+	).Scan(&sha)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up head: %w", err)
+	}
+	return sha, nil
+}
+
+// Claim marks a head SHA as being reviewed unless it is done or still in progress.
+// The conditional upsert makes concurrent claims from several workers safe
+func (s *postgresStore) Claim(ctx context.Context, pr, sha string) (bool, error) {
+	var claimed string
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO review_jobs (pr_key, head_sha, status, claimed_at)
+		VALUES ($1, $2, 'running', NOW())
+		ON CONFLICT (pr_key, head_sha) DO UPDATE SET status = 'running', claimed_at = NOW()
+		WHERE review_jobs.status = 'cancelled'
+			OR (review_jobs.status = 'running' AND review_jobs.claimed_at < NOW() - $3 * INTERVAL '1 second')
+		RETURNING head_sha`,
+		pr, sha, int(claimTimeout.Seconds()),
+	).Scan(&claimed)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to claim review: %w", err)
+	}
+	return true, nil
+}
+
+// Complete marks a claimed head SHA as reviewed unless it was cancelled
+func (s *postgresStore) Complete(ctx context.Context, pr, sha string) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE review_jobs SET status = 'done' WHERE pr_key = $1 AND head_sha = $2 AND status = 'running'`, pr, sha)
+	if err != nil {
+		return fmt.Errorf("failed to complete review: %w", err)
+	}
+	return nil
+}
+
+// Release gives up a claim so the review can be retried
+func (s *postgresStore) Release(ctx context.Context, pr, sha string) error {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM review_jobs WHERE pr_key = $1 AND head_sha = $2 AND status = 'running'`, pr, sha)
+	if err != nil {
+		return fmt.Errorf("failed to release review: %w", err)
+	}
+	return nil
+}
+
+// Cancel marks the running reviews of a pull request as cancelled
+func (s *postgresStore) Cancel(ctx context.Context, pr string) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE review_jobs SET status = 'cancelled' WHERE pr_key = $1 AND status = 'running'`, pr)
+	if err != nil {
+		return fmt.Errorf("failed to cancel reviews: %w", err)
+	}
+	return nil
+}
+
+// Cancelled reports whether the review of a head SHA was cancelled
+func (s *postgresStore) Cancelled(ctx context.Context, pr, sha string) (bool, error) {
+	var cancelled bool
+	err := s.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM review_jobs WHERE pr_key = $1 AND head_sha = $2 AND status = 'cancelled')`, pr, sha,
+	).Scan(&cancelled)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up review: %w", err)
+	}
+	return cancelled, nil
+}
@@ -0,0 +1,92 @@
+package dedup
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Registry tracks the reviews running in this process so they can be cancelled
+// once a newer push or closing the pull request makes them stale
+type Registry struct {
+	mu      sync.Mutex
+	running map[string][]*run // pull request -> running reviews
+}
+
+// run is one running review
+type run struct {
+	sha    string
+	queued time.Time
+	cancel context.CancelFunc
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{running: make(map[string][]*run)}
+}
+
+// Start registers a review of sha, queued at queued, and returns the context it
+// must run under. Running reviews of other commits of the same pull request that
+// were queued earlier are cancelled; if one was queued later, the new review is
+// the stale one and its context is cancelled instead. The returned function must
+// be called once the review ends
+func (r *Registry) Start(ctx context.Context, pr, sha string, queued time.Time) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	current := &run{sha: sha, queued: queued, cancel: cancel}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, other := range r.running[pr] {
+		if other.sha != sha && other.queued.After(queued) {
+			// Jobs can be dequeued out of order, e.g. after a redelivery or
+			// when a newer push has a higher priority
+			cancel()
+			return ctx, cancel
+		}
+	}
+
+	var kept []*run
+	for _, other := range r.running[pr] {
+		if other.sha != sha {
+			other.cancel()
+			continue
+		}
+		kept = append(kept, other)
+	}
+	r.running[pr] = append(kept, current)
+
+	return ctx, func() {
+		cancel()
+		r.remove(pr, current)
+	}
+}
+
+// Cancel cancels every running review of the pull request and returns how many there were
+func (r *Registry) Cancel(pr string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	runs := r.running[pr]
+	for _, run := range runs {
+		run.cancel()
+	}
+	delete(r.running, pr)
+	return len(runs)
+}
+
+// remove forgets a review that has ended
+func (r *Registry) remove(pr string, ended *run) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	runs := r.running[pr]
+	for i, run := range runs {
+		if run == ended {
+			runs = append(runs[:i], runs[i+1:]...)
+			break
+		}
+	}
+	if len(runs) == 0 {
+		delete(r.running, pr)
+	} else {
+		r.running[pr] = runs
+	}
+}
@@ -0,0 +1,76 @@
+package dedup
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRegistry_NewerPushCancels(t *testing.T) {
+	registry := NewRegistry()
+
+	queued := time.Now()
+	oldCtx, oldDone := registry.Start(context.Background(), "pr", "abc", queued)
+	defer oldDone()
+	sameCtx, sameDone := registry.Start(context.Background(), "pr", "abc", queued)
+	defer sameDone()
+
+	if oldCtx.Err() != nil {
+		t.Error("Expected a second review of the same commit not to cancel the first")
+	}
+
+	newCtx, newDone := registry.Start(context.Background(), "pr", "def", queued.Add(time.Second))
+	defer newDone()
+
+	if oldCtx.Err() == nil || sameCtx.Err() == nil {
+		t.Error("Expected reviews of the older commit to be cancelled")
+	}
+	if newCtx.Err() != nil {
+		t.Error("Expected the newer review to keep running")
+	}
+
+	otherCtx, otherDone := registry.Start(context.Background(), "other", "abc", queued)
+	defer otherDone()
+	if otherCtx.Err() != nil {
+		t.Error("Expected reviews of other pull requests to keep running")
+	}
+}
+
+func TestRegistry_Cancel(t *testing.T) {
+	registry := NewRegistry()
+
+	ctx, done := registry.Start(context.Background(), "pr", "abc", time.Now())
+	if n := registry.Cancel("pr"); n != 1 {
+		t.Errorf("Expected 1 review cancelled, got %d", n)
+	}
+	if ctx.Err() == nil {
+		t.Error("Expected the review to be cancelled")
+	}
+	done()
+
+	_, done = registry.Start(context.Background(), "pr", "def", time.Now())
+	done()
+	if n := registry.Cancel("pr"); n != 0 {
+		t.Errorf("Expected finished reviews not to be cancelled, got %d", n)
+	}
+}
+
+func TestRegistry_OlderJobDoesNotCancelNewer(t *testing.T) {
+	registry := NewRegistry()
+	queued := time.Now()
+
+	newCtx, newDone := registry.Start(context.Background(), "pr", "def", queued)
+	defer newDone()
+	oldCtx, oldDone := registry.Start(context.Background(), "pr", "abc", queued.Add(-time.Minute))
+	defer oldDone()
+
+	if newCtx.Err() != nil {
+		t.Error("Expected a job queued earlier not to cancel the newer review")
+	}
+	if oldCtx.Err() == nil {
+		t.Error("Expected the review of the older job to be cancelled")
+	}
+	if n := registry.Cancel("pr"); n != 1 {
+		t.Errorf("Expected only the newer review to be registered, got %d", n)
+	}
+}
@@ -0,0 +1,171 @@
+package dedup
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// sqliteStore is a Store kept in a SQLite file, for single-process deployments
+// whose duplicates should survive restarts
+type sqliteStore struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// NewSQLiteStore creates a Store backed by SQLite, creating its tables if needed.
+// Times are stored as Unix seconds
+func NewSQLiteStore(ctx context.Context, db *sql.DB) (Store, error) {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			delivery_id TEXT PRIMARY KEY,
+			received_at INTEGER NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS pull_request_heads (
+			pr_key TEXT PRIMARY KEY,
+			head_sha TEXT NOT NULL,
+			updated_at INTEGER NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS review_jobs (
+			pr_key TEXT NOT NULL,
+			head_sha TEXT NOT NULL,
+			status TEXT NOT NULL, -- 'running', 'done', 'cancelled'
+			claimed_at INTEGER NOT NULL,
+			PRIMARY KEY (pr_key, head_sha)
+		)`,
+	}
+	for _, stmt := range statements {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return nil, fmt.Errorf("failed to create deduplication tables: %w", err)
+		}
+	}
+	return &sqliteStore{db: db, now: time.Now}, nil
+}
+
+// ClaimDelivery records a webhook delivery ID unless it is already known,
+// forgetting expired ones
+func (s *sqliteStore) ClaimDelivery(ctx context.Context, id string) (bool, error) {
+	now := s.now()
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM webhook_deliveries WHERE received_at < ?`, now.Add(-deliveryRetention).Unix())
+	if err != nil {
+		return false, fmt.Errorf("failed to expire deliveries: %w", err)
+	}
+	res, err := s.db.ExecContext(ctx,
+		`INSERT OR IGNORE INTO webhook_deliveries (delivery_id, received_at) VALUES (?, ?)`,
+		id, now.Unix())
+	if err != nil {
+		return false, fmt.Errorf("failed to claim delivery: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim delivery: %w", err)
+	}
+	return n == 1, nil
+}
+
+// ForgetDelivery drops a claimed webhook delivery ID
+func (s *sqliteStore) ForgetDelivery(ctx context.Context, id string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE delivery_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to forget delivery: %w", err)
+	}
+	return nil
+}
+
+// SetHead records the newest head SHA of a pull request, forgetting finished
+// reviews of older heads
+func (s *sqliteStore) SetHead(ctx context.Context, pr, sha string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO pull_request_heads (pr_key, head_sha, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (pr_key) DO UPDATE SET head_sha = excluded.head_sha, updated_at = excluded.updated_at`,
+		pr, sha, s.now().Unix())
+	if err != nil {
+		return fmt.Errorf("failed to record head: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx,
+		`DELETE FROM review_jobs WHERE pr_key = ? AND head_sha <> ? AND status = 'done'`, pr, sha)
+	if err != nil {
+		return fmt.Errorf("failed to expire review jobs: %w", err)
+	}
+	return nil
+}
+
+// Head returns the newest recorded head SHA of a pull request
+func (s *sqliteStore) Head(ctx context.Context, pr string) (string, error) {
+	var sha string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT head_sha FROM pull_request_heads WHERE pr_key = ?`, pr,
+	).Scan(&sha)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up head: %w", err)
+	}
+	return sha, nil
+}
+
+// Claim marks a head SHA as being reviewed unless it is done or still in progress
+func (s *sqliteStore) Claim(ctx context.Context, pr, sha string) (bool, error) {
+	now := s.now()
+	var claimed string
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO review_jobs (pr_key, head_sha, status, claimed_at)
+		VALUES (?, ?, 'running', ?)
+		ON CONFLICT (pr_key, head_sha) DO UPDATE SET status = 'running', claimed_at = excluded.claimed_at
+		WHERE review_jobs.status = 'cancelled' OR (review_jobs.status = 'running' AND review_jobs.claimed_at < ?)
+		RETURNING head_sha`,
+		pr, sha, now.Unix(), now.Add(-claimTimeout).Unix(),
+	).Scan(&claimed)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to claim review: %w", err)
+	}
+	return true, nil
+}
+
+// Complete marks a claimed head SHA as reviewed unless it was cancelled
+func (s *sqliteStore) Complete(ctx context.Context, pr, sha string) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE review_jobs SET status = 'done' WHERE pr_key = ? AND head_sha = ? AND status = 'running'`, pr, sha)
+	if err != nil {
+		return fmt.Errorf("failed to complete review: %w", err)
+	}
+	return nil
+}
+
+// Release gives up a claim so the review can be retried
+func (s *sqliteStore) Release(ctx context.Context, pr, sha string) error {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM review_jobs WHERE pr_key = ? AND head_sha = ? AND status = 'running'`, pr, sha)
+	if err != nil {
+		return fmt.Errorf("failed to release review: %w", err)
+	}
+	return nil
+}
+
+// Cancel marks the running reviews of a pull request as cancelled
+func (s *sqliteStore) Cancel(ctx context.Context, pr string) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE review_jobs SET status = 'cancelled' WHERE pr_key = ? AND status = 'running'`, pr)
+	if err != nil {
+		return fmt.Errorf("failed to cancel reviews: %w", err)
+	}
+	return nil
+}
+
+// Cancelled reports whether the review of a head SHA was cancelled
+func (s *sqliteStore) Cancelled(ctx context.Context, pr, sha string) (bool, error) {
+	var cancelled bool
+	err := s.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM review_jobs WHERE pr_key = ? AND head_sha = ? AND status = 'cancelled')`, pr, sha,
+	).Scan(&cancelled)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up review: %w", err)
+	}
+	return cancelled, nil
+}
@@ -0,0 +1,138 @@
+package job
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Version is the newest envelope schema this build reads and the one it writes.
+//
+// Schema evolution rules:
+//   - Adding a field, job type or SCM kind keeps the version. Readers ignore
+//     fields they do not know, so new fields must be optional
+//   - Renaming or removing a field, or changing what one means, bumps the version
+//   - version, type, attempt and trace_id keep their names and meaning in every
+//     version, so workers can retry and drop jobs they cannot read
+//   - Workers retry jobs of newer versions until MaxAttempts, so upgrade workers
+//     before the processes publishing jobs
+//   - Workers drop jobs of types or SCMs they do not handle
+const Version = 1
+
+// MaxAttempts is how often a job is tried before workers drop it
+const MaxAttempts = 5
+
+// Job types
+const (
+	TypeReview  = "review"  // Review a pull request's head
+	TypeCommand = "command" // Run a slash command
+	TypeLabel   = "label"   // React to a label being added
+	TypeCancel  = "cancel"  // Stop reviewing a closed pull request
+)
+
+// SCM kinds
+const (
+	SCMGitHub = "github"
+)
+
+// ErrUnsupportedVersion is returned when decoding a job written by a newer schema
+var ErrUnsupportedVersion = errors.New("unsupported job version")
+
+// Job is the envelope queued for workers. The SCM-specific event that caused it
+// travels in Payload, e.g. the GitHub pull request webhook payload
+type Job struct {
+	Version    int             `json:"version"`
+	Type       string          `json:"type"`
+	SCM        string          `json:"scm"`
+	Owner      string          `json:"owner"`
+	Repo       string          `json:"repo"`
+	PR         int             `json:"pr"`
+	HeadSHA    string          `json:"head_sha,omitempty"`
+	Trigger    string          `json:"trigger"` // Why the job was queued, e.g. synchronize or backfill
+	Attempt    int             `json:"attempt"` // Incremented each time a worker queues the job again after it failed
+	TraceID    string          `json:"trace_id"`
+	EnqueuedAt time.Time       `json:"enqueued_at"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+}
+
+// New creates a first attempt at a job, generating a trace ID if none is given
+func New(jobType, scm, trigger, traceID string, payload interface{}) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job payload: %w", err)
+	}
+	if traceID == "" {
+		traceID = NewTraceID()
+	}
+	return &Job{
+		Version:    Version,
+		Type:       jobType,
+		SCM:        scm,
+		Trigger:    trigger,
+		Attempt:    1,
+		TraceID:    traceID,
+		EnqueuedAt: time.Now().UTC(),
+		Payload:    data,
+	}, nil
+}
+
+// Decode parses a queued job. Messages without a version are raw GitHub pull
+// request events queued before jobs were versioned; they decode as version 0
+// jobs carrying the whole message as their payload
+func Decode(body []byte) (*Job, error) {
+	var j Job
+	if err := json.Unmarshal(body, &j); err != nil {
+		return nil, fmt.Errorf("failed to decode job: %w", err)
+	}
+	if j.Version == 0 {
+		return &Job{SCM: SCMGitHub, Attempt: max(j.Attempt, 1), Payload: body}, nil
+	}
+	if j.Version > Version {
+		return nil, fmt.Errorf("%w %d, newest supported is %d", ErrUnsupportedVersion, j.Version, Version)
+	}
+	return &j, nil
+}
+
+// Retry is a failed job prepared to be queued again
+type Retry struct {
+	Body    json.RawMessage // The job with its attempt incremented
+	Attempt int
+	Type    string
+	TraceID string
+}
+
+// NextAttempt increments the attempt of a queued job. It edits the message
+// rather than decoding it as a Job, so jobs of newer versions keep their fields
+func NextAttempt(body []byte) (*Retry, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, fmt.Errorf("failed to decode job: %w", err)
+	}
+	var header struct {
+		Type    string `json:"type"`
+		Attempt int    `json:"attempt"`
+		TraceID string `json:"trace_id"`
+	}
+	if err := json.Unmarshal(body, &header); err != nil {
+		return nil, fmt.Errorf("failed to decode job: %w", err)
+	}
+
+	retry := &Retry{Attempt: max(header.Attempt, 1) + 1, Type: header.Type, TraceID: header.TraceID}
+	fields["attempt"], _ = json.Marshal(retry.Attempt)
+	next, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job: %w", err)
+	}
+	retry.Body = next
+	return retry, nil
+}
+
+// NewTraceID returns a random ID tying together the logs of one job
+func NewTraceID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,84 @@
+package job
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestDecode_RoundTrip(t *testing.T) {
+	j, err := New(TypeReview, SCMGitHub, "synchronize", "", map[string]string{"action": "synchronize"})
+	if err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+	j.Owner, j.Repo, j.PR, j.HeadSHA = "octo", "repo", 4, "abc123"
+	body, _ := json.Marshal(j)
+
+	decoded, err := Decode(body)
+	if err != nil {
+		t.Fatalf("Failed to decode job: %v", err)
+	}
+	if decoded.Version != Version || decoded.Type != TypeReview || decoded.PR != 4 || decoded.HeadSHA != "abc123" {
+		t.Errorf("Unexpected job: %+v", decoded)
+	}
+	if decoded.Attempt != 1 || decoded.TraceID == "" || decoded.EnqueuedAt.IsZero() {
+		t.Errorf("Expected a traced first attempt, got %+v", decoded)
+	}
+	if string(decoded.Payload) != `{"action":"synchronize"}` {
+		t.Errorf("Expected payload to be kept, got %s", decoded.Payload)
+	}
+}
+
+func TestDecode_Legacy(t *testing.T) {
+	body := []byte(`{"action":"opened","number":3,"pull_request":{"number":3}}`)
+
+	j, err := Decode(body)
+	if err != nil {
+		t.Fatalf("Failed to decode legacy event: %v", err)
+	}
+	if j.Version != 0 || j.SCM != SCMGitHub || string(j.Payload) != string(body) {
+		t.Errorf("Expected a version 0 GitHub job wrapping the event, got %+v", j)
+	}
+}
+
+func TestDecode_Evolution(t *testing.T) {
+	// Unknown fields from a compatible writer are ignored
+	j, err := Decode([]byte(`{"version":1,"type":"review","scm":"github","pr":2,"new_field":true}`))
+	if err != nil || j.PR != 2 {
+		t.Errorf("Expected unknown fields to be ignored, got %+v, %v", j, err)
+	}
+
+	_, err = Decode([]byte(`{"version":2,"type":"review","scm":"github"}`))
+	if !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Expected ErrUnsupportedVersion, got %v", err)
+	}
+}
+
+func TestNextAttempt(t *testing.T) {
+	// Fields of newer versions survive the retry
+	body := []byte(`{"version":2,"type":"review","attempt":1,"trace_id":"t1","new_field":{"a":1}}`)
+
+	retry, err := NextAttempt(body)
+	if err != nil {
+		t.Fatalf("Failed to retry job: %v", err)
+	}
+	if retry.Attempt != 2 || retry.Type != TypeReview || retry.TraceID != "t1" {
+		t.Errorf("Unexpected retry: %+v", retry)
+	}
+	var fields map[string]interface{}
+	json.Unmarshal(retry.Body, &fields)
+	if fields["attempt"] != 2.0 || fields["new_field"] == nil || fields["version"] != 2.0 {
+		t.Errorf("Expected the attempt to be incremented and other fields kept, got %s", retry.Body)
+	}
+
+	// Legacy raw events count their attempts too
+	retry, _ = NextAttempt([]byte(`{"action":"opened","number":3}`))
+	j, err := Decode(retry.Body)
+	if err != nil || j.Attempt != 2 || j.Version != 0 {
+		t.Errorf("Expected a second attempt at a legacy event, got %+v, %v", j, err)
+	}
+
+	if _, err := NextAttempt([]byte("not json")); err == nil {
+		t.Error("Expected an error for a malformed job")
+	}
+}
@@ -0,0 +1,180 @@
+package lint
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/carlr/codereviewtool/internal/workspace"
+)
+
+// Finding is a single issue reported by a static analysis tool
+type Finding struct {
+	Tool     string
+	Rule     string
+	Filename string // Relative to the repository root, using forward slashes
+	Line     int
+	Column   int
+	Message  string
+	Severity string // "info", "warning", "error"
+	Category string // Review comment category, e.g. "security"; empty if unknown
+}
+
+// Linter runs one static analysis tool over a checked-out repository
+type Linter interface {
+	// Name returns the name used to enable the linter in configuration
+	Name() string
+	// Command returns the executable and arguments used to lint the given files,
+	// or nil if none of the files are relevant to this linter
+	Command(files []string) []string
+	// Parse converts the tool's output into findings
+	Parse(stdout, stderr []byte) ([]Finding, error)
+}
+
+// registry holds the supported linters keyed by name
+var registry = map[string]Linter{
+	"go vet":        goVet{},
+	"staticcheck":   staticcheck{},
+	"golangci-lint": golangciLint{},
+	"eslint":        eslint{},
+	"ruff":          ruff{},
+}
+
+// Lookup returns the linters with the given names
+func Lookup(names []string) ([]Linter, error) {
+	linters := make([]Linter, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		linter, ok := registry[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported linter: %s", name)
+		}
+		linters = append(linters, linter)
+	}
+	return linters, nil
+}
+
+// Run executes each linter against the changed files in dir. Linters may execute
+// code from dir, so they run without the worker's secrets and with a temporary
+// HOME. A linter that is not installed or fails to run is logged and skipped so
+// one broken tool does not prevent the others from reporting
+func Run(ctx context.Context, dir string, linters []Linter, files []string) []Finding {
+	home, err := os.MkdirTemp("", "codereview-home-*")
+	if err != nil {
+		log.Printf("Skipping static analysis: %v", err)
+		return nil
+	}
+	defer os.RemoveAll(home)
+	env := workspace.Env(home)
+
+	var findings []Finding
+	for _, linter := range linters {
+		args := linter.Command(files)
+		if args == nil {
+			continue
+		}
+		if _, err := exec.LookPath(args[0]); err != nil {
+			log.Printf("Skipping %s: %v", linter.Name(), err)
+			continue
+		}
+
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Dir = dir
+		cmd.Env = env
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+
+		// Linters exit non-zero when they report issues, so only treat the run as
+		// failed if the process couldn't complete
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			log.Printf("Failed to run %s: %v", linter.Name(), err)
+			continue
+		}
+
+		results, err := linter.Parse(stdout.Bytes(), stderr.Bytes())
+		if err != nil {
+			log.Printf("Failed to parse %s output: %v", linter.Name(), err)
+			continue
+		}
+		for _, f := range results {
+			f.Filename = relativePath(dir, f.Filename)
+			f.Category = category(f.Tool, f.Rule)
+			findings = append(findings, f)
+		}
+		log.Printf("%s reported %d findings", linter.Name(), len(results))
+	}
+	return findings
+}
+
+// FilterChanged keeps only the findings on lines added by the pull request.
+// changed maps each filename to the set of new-side line numbers it adds
+func FilterChanged(findings []Finding, changed map[string]map[int]bool) []Finding {
+	var kept []Finding
+	for _, f := range findings {
+		if changed[f.Filename][f.Line] {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// relativePath converts a tool-reported path into a repository-relative one
+func relativePath(dir, filename string) string {
+	if filepath.IsAbs(filename) {
+		roots := []string{dir}
+		// Temp directories are often behind symlinks (e.g. /var -> /private/var)
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			roots = append(roots, resolved)
+		}
+		for _, root := range roots {
+			if rel, err := filepath.Rel(root, filename); err == nil && !strings.HasPrefix(rel, "..") {
+				filename = rel
+				break
+			}
+		}
+	}
+	return strings.TrimPrefix(filepath.ToSlash(filepath.Clean(filename)), "./")
+}
+
+// filesWithExtension returns the files ending in one of the given extensions
+func filesWithExtension(files []string, extensions ...string) []string {
+	var matched []string
+	for _, file := range files {
+		for _, ext := range extensions {
+			if strings.HasSuffix(file, ext) {
+				matched = append(matched, file)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// goPackages returns the package patterns that contain the changed Go files
+func goPackages(files []string) []string {
+	seen := make(map[string]bool)
+	var pkgs []string
+	for _, file := range filesWithExtension(files, ".go") {
+		pkg := "./" + filepath.ToSlash(filepath.Dir(file))
+		if pkg == "./." {
+			pkg = "."
+		}
+		if !seen[pkg] {
+			seen[pkg] = true
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs
+}
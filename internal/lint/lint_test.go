@@ -0,0 +1,158 @@
+package lint
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	linters, err := Lookup([]string{"go vet", " staticcheck ", ""})
+	if err != nil {
+		t.Fatalf("Lookup() failed: %v", err)
+	}
+	if len(linters) != 2 {
+		t.Errorf("Expected 2 linters, got %d", len(linters))
+	}
+
+	if _, err := Lookup([]string{"pylint"}); err == nil {
+		t.Error("Expected error for unsupported linter")
+	}
+}
+
+func TestGoVet_Parse(t *testing.T) {
+	stderr := []byte("# example.com/pkg\npkg/main.go:12:2: fmt.Printf format %d has arg s of wrong type string\nvet: exit status 1\n")
+
+	findings, err := goVet{}.Parse(nil, stderr)
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	if len(findings) != 1 {
+		t.Fatalf("Expected 1 finding, got %d", len(findings))
+	}
+	if findings[0].Filename != "pkg/main.go" || findings[0].Line != 12 || findings[0].Column != 2 {
+		t.Errorf("Unexpected location %s:%d:%d", findings[0].Filename, findings[0].Line, findings[0].Column)
+	}
+}
+
+func TestStaticcheck_Parse(t *testing.T) {
+	stdout := []byte(`{"code":"SA4006","severity":"error","location":{"file":"/tmp/ws/a.go","line":3,"column":1},"message":"value never used"}
+{"code":"U1000","severity":"ignored","location":{"file":"/tmp/ws/a.go","line":9,"column":1},"message":"unused"}
+`)
+
+	findings, err := staticcheck{}.Parse(stdout, nil)
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	if len(findings) != 1 {
+		t.Fatalf("Expected 1 finding, got %d", len(findings))
+	}
+	if findings[0].Rule != "SA4006" || findings[0].Severity != "error" {
+		t.Errorf("Expected SA4006 error, got %s %s", findings[0].Rule, findings[0].Severity)
+	}
+}
+
+func TestESLint_Parse(t *testing.T) {
+	stdout := []byte(`[{"filePath":"/ws/src/app.js","messages":[{"ruleId":"no-unused-vars","severity":2,"message":"'x' is unused","line":4,"column":7},{"ruleId":"semi","severity":1,"message":"Missing semicolon","line":5,"column":1}]}]`)
+
+	findings, err := eslint{}.Parse(stdout, nil)
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	if len(findings) != 2 {
+		t.Fatalf("Expected 2 findings, got %d", len(findings))
+	}
+	if findings[0].Severity != "error" || findings[1].Severity != "warning" {
+		t.Errorf("Expected error and warning severities, got %s and %s", findings[0].Severity, findings[1].Severity)
+	}
+}
+
+func TestRuff_Parse(t *testing.T) {
+	stdout := []byte(`[{"code":"F401","message":"os imported but unused","filename":"/ws/tool.py","location":{"row":1,"column":8}}]`)
+
+	findings, err := ruff{}.Parse(stdout, nil)
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	if len(findings) != 1 || findings[0].Line != 1 || findings[0].Rule != "F401" {
+		t.Errorf("Unexpected findings: %+v", findings)
+	}
+}
+
+func TestCategory(t *testing.T) {
+	tests := []struct {
+		tool, rule, expected string
+	}{
+		{"go vet", "", "bug"},
+		{"staticcheck", "SA1019", "bug"},
+		{"staticcheck", "ST1003", "style"},
+		{"golangci-lint", "gosec", "security"},
+		{"golangci-lint", "gofmt", "style"},
+		{"golangci-lint", "somethingnew", ""},
+		{"eslint", "no-eval", "security"},
+		{"eslint", "no-unused-vars", ""},
+		{"ruff", "S101", "security"},
+		{"ruff", "E501", "style"},
+		{"ruff", "PERF401", "performance"},
+	}
+	for _, tt := range tests {
+		if got := category(tt.tool, tt.rule); got != tt.expected {
+			t.Errorf("Expected %s %s to be %q, got %q", tt.tool, tt.rule, tt.expected, got)
+		}
+	}
+}
+
+func TestFilterChanged(t *testing.T) {
+	findings := []Finding{
+		{Filename: "a.go", Line: 3},
+		{Filename: "a.go", Line: 4},
+		{Filename: "b.go", Line: 3},
+	}
+	changed := map[string]map[int]bool{"a.go": {3: true}}
+
+	kept := FilterChanged(findings, changed)
+	if len(kept) != 1 || kept[0].Filename != "a.go" || kept[0].Line != 3 {
+		t.Errorf("Expected only a.go:3 to be kept, got %+v", kept)
+	}
+}
+
+func TestRelativePath(t *testing.T) {
+	dir := t.TempDir()
+	if got := relativePath(dir, filepath.Join(dir, "pkg", "a.go")); got != "pkg/a.go" {
+		t.Errorf("Expected pkg/a.go, got %s", got)
+	}
+	if got := relativePath(dir, "./pkg/a.go"); got != "pkg/a.go" {
+		t.Errorf("Expected pkg/a.go, got %s", got)
+	}
+}
+
+func TestRun_GoVet(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":      "module example.com/vettest\n\ngo 1.21\n",
+		"pkg/main.go": "package pkg\n\nimport \"fmt\"\n\nfunc Print(s string) {\n\tfmt.Printf(\"%d\\n\", s)\n}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	findings := Run(context.Background(), dir, []Linter{goVet{}}, []string{"pkg/main.go"})
+	if len(findings) != 1 {
+		t.Fatalf("Expected 1 go vet finding, got %+v", findings)
+	}
+	if findings[0].Filename != "pkg/main.go" || findings[0].Line != 6 {
+		t.Errorf("Expected finding at pkg/main.go:6, got %s:%d", findings[0].Filename, findings[0].Line)
+	}
+}
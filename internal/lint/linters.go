@@ -0,0 +1,292 @@
+package lint
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// goVet runs the go vet analyzers over the changed packages
+type goVet struct{}
+
+var vetLineRe = regexp.MustCompile(`^(\S+\.go):(\d+):(\d+): (.+)$`)
+
+func (goVet) Name() string { return "go vet" }
+
+func (goVet) Command(files []string) []string {
+	pkgs := goPackages(files)
+	if len(pkgs) == 0 {
+		return nil
+	}
+	return append([]string{"go", "vet"}, pkgs...)
+}
+
+func (goVet) Parse(stdout, stderr []byte) ([]Finding, error) {
+	var findings []Finding
+	scanner := bufio.NewScanner(bytes.NewReader(stderr))
+	for scanner.Scan() {
+		m := vetLineRe.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		line, _ := strconv.Atoi(m[2])
+		column, _ := strconv.Atoi(m[3])
+		findings = append(findings, Finding{
+			Tool:     "go vet",
+			Filename: m[1],
+			Line:     line,
+			Column:   column,
+			Message:  m[4],
+			Severity: "warning",
+		})
+	}
+	return findings, scanner.Err()
+}
+
+// staticcheck runs staticcheck with its JSON line formatter
+type staticcheck struct{}
+
+func (staticcheck) Name() string { return "staticcheck" }
+
+func (staticcheck) Command(files []string) []string {
+	pkgs := goPackages(files)
+	if len(pkgs) == 0 {
+		return nil
+	}
+	return append([]string{"staticcheck", "-f", "json"}, pkgs...)
+}
+
+func (staticcheck) Parse(stdout, stderr []byte) ([]Finding, error) {
+	var findings []Finding
+	decoder := json.NewDecoder(bytes.NewReader(stdout))
+	for decoder.More() {
+		var issue struct {
+			Code     string `json:"code"`
+			Severity string `json:"severity"`
+			Location struct {
+				File   string `json:"file"`
+				Line   int    `json:"line"`
+				Column int    `json:"column"`
+			} `json:"location"`
+			Message string `json:"message"`
+		}
+		if err := decoder.Decode(&issue); err != nil {
+			return nil, fmt.Errorf("failed to decode staticcheck output: %w", err)
+		}
+		if issue.Severity == "ignored" {
+			continue
+		}
+		findings = append(findings, Finding{
+			Tool:     "staticcheck",
+			Rule:     issue.Code,
+			Filename: issue.Location.File,
+			Line:     issue.Location.Line,
+			Column:   issue.Location.Column,
+			Message:  issue.Message,
+			Severity: normalizeSeverity(issue.Severity, "warning"),
+		})
+	}
+	return findings, nil
+}
+
+// golangciLint runs golangci-lint (v2 flags) with JSON output
+type golangciLint struct{}
+
+func (golangciLint) Name() string { return "golangci-lint" }
+
+func (golangciLint) Command(files []string) []string {
+	pkgs := goPackages(files)
+	if len(pkgs) == 0 {
+		return nil
+	}
+	return append([]string{"golangci-lint", "run", "--output.json.path=stdout", "--show-stats=false"}, pkgs...)
+}
+
+func (golangciLint) Parse(stdout, stderr []byte) ([]Finding, error) {
+	var report struct {
+		Issues []struct {
+			FromLinter string `json:"FromLinter"`
+			Text       string `json:"Text"`
+			Severity   string `json:"Severity"`
+			Pos        struct {
+				Filename string `json:"Filename"`
+				Line     int    `json:"Line"`
+				Column   int    `json:"Column"`
+			} `json:"Pos"`
+		} `json:"Issues"`
+	}
+	if len(bytes.TrimSpace(stdout)) == 0 {
+		return nil, nil
+	}
+	if err := json.Unmarshal(stdout, &report); err != nil {
+		return nil, fmt.Errorf("failed to decode golangci-lint output: %w", err)
+	}
+
+	findings := make([]Finding, 0, len(report.Issues))
+	for _, issue := range report.Issues {
+		findings = append(findings, Finding{
+			Tool:     "golangci-lint",
+			Rule:     issue.FromLinter,
+			Filename: issue.Pos.Filename,
+			Line:     issue.Pos.Line,
+			Column:   issue.Pos.Column,
+			Message:  issue.Text,
+			Severity: normalizeSeverity(issue.Severity, "warning"),
+		})
+	}
+	return findings, nil
+}
+
+// eslint runs ESLint with its JSON formatter over changed JavaScript and TypeScript files
+type eslint struct{}
+
+func (eslint) Name() string { return "eslint" }
+
+func (eslint) Command(files []string) []string {
+	matched := filesWithExtension(files, ".js", ".jsx", ".mjs", ".cjs", ".ts", ".tsx")
+	if len(matched) == 0 {
+		return nil
+	}
+	return append([]string{"eslint", "--format", "json"}, matched...)
+}
+
+func (eslint) Parse(stdout, stderr []byte) ([]Finding, error) {
+	var results []struct {
+		FilePath string `json:"filePath"`
+		Messages []struct {
+			RuleID   string `json:"ruleId"`
+			Severity int    `json:"severity"`
+			Message  string `json:"message"`
+			Line     int    `json:"line"`
+			Column   int    `json:"column"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(stdout, &results); err != nil {
+		return nil, fmt.Errorf("failed to decode eslint output: %w", err)
+	}
+
+	var findings []Finding
+	for _, result := range results {
+		for _, msg := range result.Messages {
+			severity := "warning"
+			if msg.Severity >= 2 {
+				severity = "error"
+			}
+			findings = append(findings, Finding{
+				Tool:     "eslint",
+				Rule:     msg.RuleID,
+				Filename: result.FilePath,
+				Line:     msg.Line,
+				Column:   msg.Column,
+				Message:  msg.Message,
+				Severity: severity,
+			})
+		}
+	}
+	return findings, nil
+}
+
+// ruff runs the Ruff Python linter with JSON output over changed Python files
+type ruff struct{}
+
+func (ruff) Name() string { return "ruff" }
+
+func (ruff) Command(files []string) []string {
+	matched := filesWithExtension(files, ".py", ".pyi")
+	if len(matched) == 0 {
+		return nil
+	}
+	return append([]string{"ruff", "check", "--output-format", "json", "--exit-zero"}, matched...)
+}
+
+func (ruff) Parse(stdout, stderr []byte) ([]Finding, error) {
+	var results []struct {
+		Code     string `json:"code"`
+		Message  string `json:"message"`
+		Filename string `json:"filename"`
+		Location struct {
+			Row    int `json:"row"`
+			Column int `json:"column"`
+		} `json:"location"`
+	}
+	if err := json.Unmarshal(stdout, &results); err != nil {
+		return nil, fmt.Errorf("failed to decode ruff output: %w", err)
+	}
+
+	findings := make([]Finding, 0, len(results))
+	for _, result := range results {
+		findings = append(findings, Finding{
+			Tool:     "ruff",
+			Rule:     result.Code,
+			Filename: result.Filename,
+			Line:     result.Location.Row,
+			Column:   result.Location.Column,
+			Message:  result.Message,
+			Severity: "warning",
+		})
+	}
+	return findings, nil
+}
+
+// normalizeSeverity maps a tool's severity onto info/warning/error
+func normalizeSeverity(severity, defaultSeverity string) string {
+	switch severity {
+	case "error":
+		return "error"
+	case "warning", "warn":
+		return "warning"
+	case "info", "note", "hint":
+		return "info"
+	}
+	return defaultSeverity
+}
+
+// golangciCategories maps golangci-lint linters to review comment categories
+var golangciCategories = map[string]string{
+	"gosec": "security", "errcheck": "bug", "govet": "bug", "staticcheck": "bug",
+	"bodyclose": "bug", "sqlclosecheck": "bug", "nilerr": "bug", "errorlint": "bug",
+	"ineffassign": "maintainability", "unused": "maintainability", "unparam": "maintainability",
+	"gocyclo": "maintainability", "gocognit": "maintainability", "dupl": "maintainability",
+	"funlen": "maintainability", "prealloc": "performance",
+	"gofmt": "style", "goimports": "style", "gofumpt": "style", "whitespace": "style",
+	"misspell": "style", "revive": "style", "stylecheck": "style", "godot": "style", "lll": "style",
+}
+
+// ruffCategories maps Ruff rule prefixes to review comment categories
+var ruffCategories = map[string]string{
+	"S": "security", "F": "bug", "B": "bug", "PERF": "performance", "C": "maintainability",
+	"E": "style", "W": "style", "I": "style", "D": "style", "N": "style", "Q": "style",
+}
+
+var rulePrefixRe = regexp.MustCompile(`^[A-Z]+`)
+
+// category maps a finding's tool and rule onto a review comment category, or
+// returns "" when the rule's kind isn't known
+func category(tool, rule string) string {
+	switch tool {
+	case "go vet":
+		return "bug"
+	case "staticcheck":
+		switch rulePrefixRe.FindString(rule) {
+		case "SA":
+			return "bug"
+		case "ST":
+			return "style"
+		case "S", "QF", "U":
+			return "maintainability"
+		}
+	case "golangci-lint":
+		return golangciCategories[rule]
+	case "eslint":
+		if rule == "no-eval" || rule == "no-implied-eval" || rule == "no-new-func" || strings.HasPrefix(rule, "security/") {
+			return "security"
+		}
+	case "ruff":
+		return ruffCategories[rulePrefixRe.FindString(rule)]
+	}
+	return ""
+}
@@ -0,0 +1,232 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+const (
+	// DefaultOverrideLabel lets a maintainer bypass the policy by labeling the PR
+	DefaultOverrideLabel = "ai-review-override"
+	// DefaultOverrideCommand lets a maintainer bypass the policy with a PR comment
+	DefaultOverrideCommand = "/ai-review override"
+)
+
+// Policy decides whether a review's findings should block a merge
+type Policy struct {
+	// MaxErrors and MaxWarnings are the most findings of each severity allowed;
+	// nil means unlimited
+	MaxErrors   *int `json:"max_errors,omitempty"`
+	MaxWarnings *int `json:"max_warnings,omitempty"`
+
+	// BlockCategories fail the policy on any warning or error in these categories
+	BlockCategories []string `json:"block_categories,omitempty"`
+
+	// Paths apply stricter rules to pull requests changing matching files
+	Paths []PathPolicy `json:"paths,omitempty"`
+
+	// OverrideLabel and OverrideCommand let maintainers bypass a failing policy
+	OverrideLabel   string `json:"override_label,omitempty"`
+	OverrideCommand string `json:"override_command,omitempty"`
+}
+
+// PathPolicy limits findings in files matching a glob pattern. A trailing "/**"
+// matches everything below a directory
+type PathPolicy struct {
+	Pattern     string `json:"pattern"`
+	MaxErrors   *int   `json:"max_errors,omitempty"`
+	MaxWarnings *int   `json:"max_warnings,omitempty"`
+	// RequireOverride fails the policy whenever a matching file is changed, with
+	// or without findings, so a maintainer has to override it
+	RequireOverride bool `json:"require_override,omitempty"`
+}
+
+// Config holds the default policy and per-repository policies keyed by
+// "owner/repo" or "owner/*"
+type Config struct {
+	Default      Policy            `json:"default"`
+	Repositories map[string]Policy `json:"repositories,omitempty"`
+}
+
+// Decision is the outcome of evaluating a policy
+type Decision struct {
+	Passed       bool
+	Reasons      []string
+	Overridden   bool
+	OverriddenBy string
+}
+
+// DefaultConfig blocks merges on any error-severity finding
+func DefaultConfig() *Config {
+	zero := 0
+	return &Config{Default: Policy{MaxErrors: &zero}}
+}
+
+// Load reads a policy configuration from a JSON file, or returns the default
+// configuration when path is empty
+func Load(filename string) (*Config, error) {
+	if filename == "" {
+		return DefaultConfig(), nil
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file: %w", err)
+	}
+	return &cfg, nil
+}
+
+// For returns the policy for a repository given as "owner/repo", preferring an
+// exact match over an owner wildcard over the default
+func (c *Config) For(fullName string) Policy {
+	p, ok := c.Repositories[fullName]
+	if !ok {
+		owner, _, _ := strings.Cut(fullName, "/")
+		p, ok = c.Repositories[owner+"/*"]
+	}
+	if !ok {
+		p = c.Default
+	}
+
+	if p.OverrideLabel == "" {
+		p.OverrideLabel = DefaultOverrideLabel
+	}
+	if p.OverrideCommand == "" {
+		p.OverrideCommand = DefaultOverrideCommand
+	}
+	return p
+}
+
+// Evaluate checks a review against the policy. A review whose response couldn't
+// be parsed has no findings to check, so it fails rather than passing unseen code
+func (p Policy) Evaluate(review *llm.CodeReviewResponse) Decision {
+	if review.Unparsed {
+		return Decision{Reasons: []string{"the AI review could not be parsed"}}
+	}
+
+	var reasons []string
+
+	counts := review.SeverityCounts()
+	reasons = append(reasons, checkLimit("error", counts["error"], p.MaxErrors, "")...)
+	reasons = append(reasons, checkLimit("warning", counts["warning"], p.MaxWarnings, "")...)
+
+	blocked := make(map[string]bool, len(p.BlockCategories))
+	for _, category := range p.BlockCategories {
+		blocked[strings.ToLower(category)] = true
+	}
+	blockedCounts := make(map[string]int)
+	for _, c := range review.Comments {
+		category := strings.ToLower(c.Category)
+		if blocked[category] && llm.SeverityRank(c.Severity) > 0 {
+			blockedCounts[category]++
+		}
+	}
+	for _, category := range p.BlockCategories {
+		if n := blockedCounts[strings.ToLower(category)]; n > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d %s finding(s) of warning severity or higher", n, category))
+		}
+	}
+
+	for _, pp := range p.Paths {
+		if pp.RequireOverride {
+			if n := countMatches(pp.Pattern, review.ChangedFiles); n > 0 {
+				reasons = append(reasons, fmt.Sprintf("%d changed file(s) in %s need an override", n, pp.Pattern))
+			}
+		}
+
+		matched := &llm.CodeReviewResponse{}
+		for _, c := range review.Comments {
+			if MatchPath(pp.Pattern, c.Filename) {
+				matched.Comments = append(matched.Comments, c)
+			}
+		}
+		counts := matched.SeverityCounts()
+		reasons = append(reasons, checkLimit("error", counts["error"], pp.MaxErrors, pp.Pattern)...)
+		reasons = append(reasons, checkLimit("warning", counts["warning"], pp.MaxWarnings, pp.Pattern)...)
+	}
+
+	return Decision{Passed: len(reasons) == 0, Reasons: reasons}
+}
+
+// IsOverrideCommand reports whether a comment body invokes the override command
+func (p Policy) IsOverrideCommand(body string) bool {
+	body = strings.TrimSpace(body)
+	return p.OverrideCommand != "" && (body == p.OverrideCommand || strings.HasPrefix(body, p.OverrideCommand+" ") || strings.HasPrefix(body, p.OverrideCommand+"\n"))
+}
+
+// HasOverrideLabel reports whether any of the labels is the override label
+func (p Policy) HasOverrideLabel(labels []string) bool {
+	for _, label := range labels {
+		if LabelsEqual(label, p.OverrideLabel) {
+			return true
+		}
+	}
+	return false
+}
+
+// LabelsEqual compares label names the way GitHub does, ignoring case
+func LabelsEqual(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
+
+// Description summarizes the decision in a single line suitable for a commit status
+func (d Decision) Description() string {
+	switch {
+	case d.Overridden:
+		return "Policy overridden by " + d.OverriddenBy
+	case d.Passed:
+		return "No blocking findings"
+	default:
+		return "Blocked: " + strings.Join(d.Reasons, "; ")
+	}
+}
+
+// checkLimit reports a reason if count exceeds limit
+func checkLimit(severity string, count int, limit *int, pattern string) []string {
+	if limit == nil || count <= *limit {
+		return nil
+	}
+	scope := ""
+	if pattern != "" {
+		scope = " in " + pattern
+	}
+	return []string{fmt.Sprintf("%d %s(s)%s exceed the limit of %d", count, severity, scope, *limit)}
+}
+
+// countMatches returns how many of the files match the pattern
+func countMatches(pattern string, files []string) int {
+	n := 0
+	for _, file := range files {
+		if MatchPath(pattern, file) {
+			n++
+		}
+	}
+	return n
+}
+
+// MatchPath matches a slash-separated file path against a glob pattern. Besides
+// path.Match syntax, a trailing "/**" matches any file below a directory
+func MatchPath(pattern, name string) bool {
+	if dir, ok := strings.CutSuffix(pattern, "/**"); ok {
+		return name == dir || strings.HasPrefix(name, dir+"/")
+	}
+	if matched, _ := path.Match(pattern, name); matched {
+		return true
+	}
+	// Patterns without a directory match the file name anywhere in the tree
+	if !strings.Contains(pattern, "/") {
+		matched, _ := path.Match(pattern, path.Base(name))
+		return matched
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+func intPtr(n int) *int { return &n }
+
+func TestEvaluate_DefaultBlocksErrors(t *testing.T) {
+	p := DefaultConfig().For("owner/repo")
+
+	clean := &llm.CodeReviewResponse{Comments: []llm.ReviewComment{{Severity: "warning"}}}
+	if d := p.Evaluate(clean); !d.Passed {
+		t.Errorf("Expected warnings to pass the default policy, got %v", d.Reasons)
+	}
+
+	failing := &llm.CodeReviewResponse{Comments: []llm.ReviewComment{{Severity: "error"}}}
+	if d := p.Evaluate(failing); d.Passed {
+		t.Error("Expected an error to fail the default policy")
+	}
+}
+
+func TestEvaluate_UnparsedFails(t *testing.T) {
+	p := Policy{} // No limits at all
+
+	if d := p.Evaluate(&llm.CodeReviewResponse{Summary: "not JSON", Unparsed: true}); d.Passed {
+		t.Error("Expected a review that couldn't be parsed to fail the policy")
+	}
+}
+
+func TestEvaluate_CategoriesAndPaths(t *testing.T) {
+	p := Policy{
+		BlockCategories: []string{"security"},
+		Paths:           []PathPolicy{{Pattern: "internal/auth/**", MaxWarnings: intPtr(0)}},
+	}
+
+	tests := []struct {
+		name    string
+		comment llm.ReviewComment
+		passed  bool
+	}{
+		{"security warning", llm.ReviewComment{Filename: "main.go", Severity: "warning", Category: "Security"}, false},
+		{"security info", llm.ReviewComment{Filename: "main.go", Severity: "info", Category: "security"}, true},
+		{"warning in protected path", llm.ReviewComment{Filename: "internal/auth/token.go", Severity: "warning"}, false},
+		{"warning elsewhere", llm.ReviewComment{Filename: "internal/api/token.go", Severity: "warning"}, true},
+	}
+	for _, tt := range tests {
+		d := p.Evaluate(&llm.CodeReviewResponse{Comments: []llm.ReviewComment{tt.comment}})
+		if d.Passed != tt.passed {
+			t.Errorf("%s: expected passed=%v, got %v (%v)", tt.name, tt.passed, d.Passed, d.Reasons)
+		}
+	}
+}
+
+func TestEvaluate_RequireOverride(t *testing.T) {
+	p := Policy{Paths: []PathPolicy{{Pattern: "migrations/**", RequireOverride: true}}}
+
+	if d := p.Evaluate(&llm.CodeReviewResponse{ChangedFiles: []string{"migrations/004_users.sql", "main.go"}}); d.Passed {
+		t.Error("Expected a clean review touching a protected path to fail")
+	}
+	if d := p.Evaluate(&llm.CodeReviewResponse{ChangedFiles: []string{"main.go"}}); !d.Passed {
+		t.Errorf("Expected a review outside the protected path to pass, got %v", d.Reasons)
+	}
+}
+
+func TestConfig_For(t *testing.T) {
+	cfg := &Config{
+		Default: Policy{MaxErrors: intPtr(0)},
+		Repositories: map[string]Policy{
+			"acme/*":    {MaxErrors: intPtr(5)},
+			"acme/core": {MaxErrors: intPtr(1), OverrideLabel: "ship-it"},
+		},
+	}
+
+	if p := cfg.For("acme/core"); *p.MaxErrors != 1 || p.OverrideLabel != "ship-it" {
+		t.Errorf("Expected exact repository policy, got %+v", p)
+	}
+	if p := cfg.For("acme/web"); *p.MaxErrors != 5 || p.OverrideLabel != DefaultOverrideLabel {
+		t.Errorf("Expected owner wildcard policy with default override label, got %+v", p)
+	}
+	if p := cfg.For("other/repo"); *p.MaxErrors != 0 || p.OverrideCommand != DefaultOverrideCommand {
+		t.Errorf("Expected default policy, got %+v", p)
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	p := DefaultConfig().For("owner/repo")
+
+	if !p.IsOverrideCommand("  /ai-review override because hotfix") {
+		t.Error("Expected override command with a reason to match")
+	}
+	if p.IsOverrideCommand("/ai-review overrides") {
+		t.Error("Expected a different command not to match")
+	}
+	if !p.HasOverrideLabel([]string{"bug", "AI-Review-Override"}) {
+		t.Error("Expected override label to match case-insensitively")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.json")
+	content := `{"default": {"max_errors": 2}, "repositories": {"acme/core": {"max_warnings": 0}}}`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if *cfg.Default.MaxErrors != 2 || *cfg.For("acme/core").MaxWarnings != 0 {
+		t.Errorf("Unexpected policy config: %+v", cfg)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected error for a missing policy file")
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		matched       bool
+	}{
+		{"docs/**", "docs/guide/intro.md", true},
+		{"docs/**", "documentation/intro.md", false},
+		{"*.md", "docs/README.md", true},
+		{"cmd/*/main.go", "cmd/worker/main.go", true},
+		{"cmd/*/main.go", "cmd/worker/checks.go", false},
+	}
+	for _, tt := range tests {
+		if got := MatchPath(tt.pattern, tt.name); got != tt.matched {
+			t.Errorf("MatchPath(%q, %q) = %v, expected %v", tt.pattern, tt.name, got, tt.matched)
+		}
+	}
+}
+
+func TestDecision_Description(t *testing.T) {
+	d := Decision{Reasons: []string{"2 error(s) exceed the limit of 0"}}
+	if !strings.HasPrefix(d.Description(), "Blocked: ") {
+		t.Errorf("Unexpected description: %s", d.Description())
+	}
+}
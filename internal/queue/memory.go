@@ -0,0 +1,133 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// memoryCapacity is how many messages the in-memory queue holds before
+// Publish fails
+const memoryCapacity = 1000
+
+// ErrClosed is returned when publishing to a closed queue
+var ErrClosed = errors.New("queue closed")
+
+// Memory is an in-process queue for single-binary deployments and tests.
+// Commands are delivered before reviews, which are delivered in arrival order.
+// Messages are lost when the process exits
+type Memory struct {
+	messages chan []byte
+	commands chan []byte
+	done     chan struct{}
+	once     sync.Once
+
+	// RetryDelay is how long a failed message waits before redelivery
+	RetryDelay time.Duration
+}
+
+// NewMemory creates an empty in-memory queue
+func NewMemory() *Memory {
+	return &Memory{
+		messages:   make(chan []byte, memoryCapacity),
+		commands:   make(chan []byte, memoryCapacity),
+		done:       make(chan struct{}),
+		RetryDelay: retryDelay,
+	}
+}
+
+// Publish publishes an event to the queue as a review
+func (m *Memory) Publish(event interface{}) error {
+	return m.PublishWith(event, defaultOptions)
+}
+
+// PublishWith publishes an event to the queue of its route, failing rather than
+// blocking when it is full. Priorities are ignored
+func (m *Memory) PublishWith(event interface{}, opts PublishOptions) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	select {
+	case <-m.done:
+		return ErrClosed
+	default:
+	}
+
+	select {
+	case m.route(opts.Route) <- body:
+		return nil
+	default:
+		return fmt.Errorf("failed to publish message: queue is full (%d messages)", memoryCapacity)
+	}
+}
+
+// Consume consumes events from the queue until it is closed, requeueing
+// messages the handler fails after RetryDelay
+func (m *Memory) Consume(concurrency int, handler func([]byte) error) error {
+	var wg sync.WaitGroup
+	for i := 0; i < normalizeConcurrency(concurrency); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				// Prefer waiting commands over reviews
+				var body []byte
+				messages := m.messages
+				select {
+				case body = <-m.commands:
+					messages = m.commands
+				default:
+					select {
+					case body = <-m.commands:
+						messages = m.commands
+					case body = <-m.messages:
+					case <-m.done:
+						return
+					}
+				}
+
+				if err := handler(body); err != nil {
+					log.Printf("Error processing message: %v", err)
+					m.requeue(body, messages)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// route returns the channel carrying a route's messages
+func (m *Memory) route(route Route) chan []byte {
+	if route == RouteCommand {
+		return m.commands
+	}
+	return m.messages
+}
+
+// requeue puts a failed message back after RetryDelay unless the queue closes first
+func (m *Memory) requeue(body []byte, messages chan []byte) {
+	go func() {
+		select {
+		case <-time.After(m.RetryDelay):
+		case <-m.done:
+			return
+		}
+		select {
+		case messages <- body:
+		case <-m.done:
+		}
+	}()
+}
+
+// Close stops consumers; queued messages are discarded
+func (m *Memory) Close() error {
+	m.once.Do(func() { close(m.done) })
+	return nil
+}
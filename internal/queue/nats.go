@@ -0,0 +1,159 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	natsStream   = "CODEREVIEW"
+	natsSubject  = "codereview.pull_requests"
+	natsConsumer = "workers"
+
+	// natsAckWait is how long a message may go without a heartbeat before the
+	// server redelivers it
+	natsAckWait = 5 * time.Minute
+)
+
+// NATS implements message queue using a NATS JetStream work queue stream
+type NATS struct {
+	conn *nats.Conn
+	js   jetstream.JetStream
+	done chan struct{}
+	once sync.Once
+}
+
+// NewNATS creates a new NATS JetStream client, creating the stream if needed
+func NewNATS(url string) (*NATS, error) {
+	conn, err := nats.Connect(url, nats.Name("codereview"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create JetStream context: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:      natsStream,
+		Subjects:  []string{natsSubject},
+		Retention: jetstream.WorkQueuePolicy,
+		Storage:   jetstream.FileStorage,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create stream: %w", err)
+	}
+
+	return &NATS{conn: conn, js: js, done: make(chan struct{})}, nil
+}
+
+// Publish publishes an event to the stream
+func (n *NATS) Publish(event interface{}) error {
+	return n.PublishWith(event, defaultOptions)
+}
+
+// PublishWith publishes an event to the stream. Streams are consumed in arrival
+// order, so routes and priorities are ignored
+func (n *NATS) PublishWith(event interface{}, opts PublishOptions) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := n.js.Publish(ctx, natsSubject, body); err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+	return nil
+}
+
+// Consume consumes events from the stream's durable consumer until the queue
+// is closed
+func (n *NATS) Consume(concurrency int, handler func([]byte) error) error {
+	concurrency = normalizeConcurrency(concurrency)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	consumer, err := n.js.CreateOrUpdateConsumer(ctx, natsStream, jetstream.ConsumerConfig{
+		Durable:   natsConsumer,
+		AckPolicy: jetstream.AckExplicitPolicy,
+		AckWait:   natsAckWait,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register consumer: %w", err)
+	}
+
+	// Only take as many messages as can be processed at once
+	msgs, err := consumer.Messages(jetstream.PullMaxMessages(concurrency))
+	if err != nil {
+		return fmt.Errorf("failed to register consumer: %w", err)
+	}
+	go func() {
+		<-n.done
+		msgs.Stop()
+	}()
+
+	log.Println("Waiting for messages...")
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				msg, err := msgs.Next()
+				if err != nil {
+					if errors.Is(err, jetstream.ErrMsgIteratorClosed) {
+						return
+					}
+					log.Printf("Error receiving message: %v", err)
+					continue
+				}
+				n.handle(msg, handler)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// handle runs the handler, acknowledging the message on success and asking
+// the server to redeliver it after retryDelay on failure
+func (n *NATS) handle(msg jetstream.Msg, handler func([]byte) error) {
+	stop := heartbeat(natsAckWait/3, func() {
+		msg.InProgress()
+	})
+	err := handler(msg.Data())
+	stop()
+
+	if err != nil {
+		log.Printf("Error processing message: %v", err)
+		msg.NakWithDelay(retryDelay)
+		return
+	}
+	if err := msg.Ack(); err != nil {
+		log.Printf("Failed to acknowledge message: %v", err)
+	}
+}
+
+// Close stops consumers and closes the NATS connection
+func (n *NATS) Close() error {
+	n.once.Do(func() { close(n.done) })
+	n.conn.Close()
+	return nil
+}
@@ -0,0 +1,110 @@
+package queue
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// retryDelay is how long a message the handler failed is held before redelivery
+const retryDelay = 5 * time.Second
+
+// Priorities of review jobs; queues that support priorities deliver higher ones first
+const (
+	PriorityLow    uint8 = 1 // Background work such as backfills
+	PriorityNormal uint8 = 5
+	PriorityHigh   uint8 = 9 // Reviews people asked for and PRs matching priority rules
+
+	MaxPriority = PriorityHigh
+)
+
+// Route separates interactive commands from background reviews, so commands
+// never wait behind a backlog of reviews
+type Route int
+
+const (
+	// RouteReview carries reviews, delivered in priority order
+	RouteReview Route = iota
+	// RouteCommand carries commands, labels and closed pull requests, which are
+	// consumed by their own workers
+	RouteCommand
+)
+
+// PublishOptions controls how a message is delivered
+type PublishOptions struct {
+	Route    Route
+	Priority uint8 // 0 to MaxPriority
+}
+
+// defaultOptions are used by Publish
+var defaultOptions = PublishOptions{Route: RouteReview, Priority: PriorityNormal}
+
+// Queue publishes review jobs and delivers them to workers
+type Queue interface {
+	// Publish marshals the event as JSON and queues it as a normal priority review
+	Publish(event interface{}) error
+	// PublishWith marshals the event as JSON and queues it with the given route
+	// and priority. Backends without priorities deliver in arrival order
+	PublishWith(event interface{}, opts PublishOptions) error
+	// Consume calls handler for each message, up to concurrency at once. A nil
+	// error acknowledges the message; any other error redelivers it. Consume
+	// blocks until the queue is closed
+	Consume(concurrency int, handler func([]byte) error) error
+	// Close stops consumers and releases the connection
+	Close() error
+}
+
+// Options selects and configures a queue backend
+type Options struct {
+	Backend     string // "rabbitmq", "memory", "sqlite", "redis" or "nats"
+	RabbitMQURL string
+	RedisURL    string
+	NATSURL     string
+	SQLitePath  string
+}
+
+// Open connects to the backend named by opts.Backend, defaulting to RabbitMQ
+func Open(opts Options) (Queue, error) {
+	switch opts.Backend {
+	case "", "rabbitmq":
+		return NewRabbitMQ(opts.RabbitMQURL)
+	case "memory":
+		return NewMemory(), nil
+	case "sqlite":
+		return NewSQLite(opts.SQLitePath)
+	case "redis":
+		return NewRedis(opts.RedisURL)
+	case "nats":
+		return NewNATS(opts.NATSURL)
+	default:
+		return nil, fmt.Errorf("unknown queue backend: %s", opts.Backend)
+	}
+}
+
+// heartbeat calls beat every interval until the returned stop function is
+// called, keeping a long review from being redelivered to another worker
+func heartbeat(interval time.Duration, beat func()) (stop func()) {
+	done := make(chan struct{})
+	var once sync.Once
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				beat()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// normalizeConcurrency returns at least one consumer
+func normalizeConcurrency(concurrency int) int {
+	if concurrency < 1 {
+		return 1
+	}
+	return concurrency
+}
@@ -0,0 +1,215 @@
+package queue
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testRedelivery publishes a job, fails it once and expects it back before the
+// queue closes
+func testRedelivery(t *testing.T, q Queue) {
+	t.Helper()
+
+	if err := q.Publish(map[string]string{"action": "opened"}); err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+
+	deliveries := make(chan string, 10)
+	var attempts int32
+	consumed := make(chan error, 1)
+	go func() {
+		consumed <- q.Consume(2, func(body []byte) error {
+			deliveries <- string(body)
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				return errors.New("transient failure")
+			}
+			return nil
+		})
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case body := <-deliveries:
+			if body != `{"action":"opened"}` {
+				t.Errorf("Expected the published event, got %s", body)
+			}
+		case <-time.After(15 * time.Second):
+			t.Fatalf("Expected delivery %d, got none", i+1)
+		}
+	}
+
+	select {
+	case body := <-deliveries:
+		t.Errorf("Expected an acknowledged message not to be redelivered, got %s", body)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	q.Close()
+	select {
+	case err := <-consumed:
+		if err != nil {
+			t.Errorf("Expected Consume to return nil after Close, got: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Error("Expected Consume to return after Close")
+	}
+}
+
+func TestMemory_Redelivery(t *testing.T) {
+	q := NewMemory()
+	q.RetryDelay = 10 * time.Millisecond
+	testRedelivery(t, q)
+
+	if err := q.Publish("late"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed after Close, got: %v", err)
+	}
+}
+
+func TestSQLite_Redelivery(t *testing.T) {
+	q, err := NewSQLite(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatalf("Failed to open queue: %v", err)
+	}
+	q.RetryDelay = 10 * time.Millisecond
+	testRedelivery(t, q)
+}
+
+func TestSQLite_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.db")
+	q, err := NewSQLite(path)
+	if err != nil {
+		t.Fatalf("Failed to open queue: %v", err)
+	}
+	if err := q.Publish("queued before restart"); err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+	q.Close()
+
+	// A job queued before the process stopped is still delivered after it restarts
+	q, err = NewSQLite(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen queue: %v", err)
+	}
+	defer q.Close()
+	id, body, err := q.claim()
+	if err != nil {
+		t.Fatalf("Failed to claim job: %v", err)
+	}
+	if string(body) != `"queued before restart"` {
+		t.Errorf("Expected the job published before restart, got %q", body)
+	}
+	if _, next, _ := q.claim(); next != nil {
+		t.Errorf("Expected a leased job not to be handed out twice, got job %d twice", id)
+	}
+}
+
+func TestMemory_CommandsFirst(t *testing.T) {
+	q := NewMemory()
+	q.Publish("review")
+	q.PublishWith("command", PublishOptions{Route: RouteCommand})
+
+	deliveries := make(chan string, 2)
+	go q.Consume(1, func(body []byte) error {
+		deliveries <- string(body)
+		return nil
+	})
+	defer q.Close()
+
+	for _, want := range []string{`"command"`, `"review"`} {
+		select {
+		case got := <-deliveries:
+			if got != want {
+				t.Errorf("Expected %s, got %s", want, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected %s to be delivered", want)
+		}
+	}
+}
+
+func TestSQLite_Priorities(t *testing.T) {
+	q, err := NewSQLite(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatalf("Failed to open queue: %v", err)
+	}
+	defer q.Close()
+
+	q.PublishWith("backfill", PublishOptions{Priority: PriorityLow})
+	q.Publish("push")
+	q.PublishWith("hotfix", PublishOptions{Priority: PriorityHigh})
+	q.PublishWith("command", PublishOptions{Route: RouteCommand})
+
+	for _, want := range []string{`"command"`, `"hotfix"`, `"push"`, `"backfill"`} {
+		_, body, err := q.claim()
+		if err != nil {
+			t.Fatalf("Failed to claim job: %v", err)
+		}
+		if string(body) != want {
+			t.Errorf("Expected %s, got %s", want, body)
+		}
+	}
+}
+
+func TestMemory_Full(t *testing.T) {
+	q := NewMemory()
+	defer q.Close()
+
+	for i := 0; i < memoryCapacity; i++ {
+		if err := q.Publish(i); err != nil {
+			t.Fatalf("Failed to publish message %d: %v", i, err)
+		}
+	}
+	if err := q.Publish("overflow"); err == nil {
+		t.Error("Expected publishing to a full queue to fail")
+	}
+}
+
+func TestRabbitMQ_Redelivery(t *testing.T) {
+	url := os.Getenv("RABBITMQ_TEST_URL")
+	if url == "" {
+		t.Skip("RABBITMQ_TEST_URL not set")
+	}
+	q, err := NewRabbitMQ(url)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	testRedelivery(t, q)
+
+	if err := q.Publish("late"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed after Close, got: %v", err)
+	}
+}
+
+func TestRedis_Redelivery(t *testing.T) {
+	url := os.Getenv("REDIS_TEST_URL")
+	if url == "" {
+		t.Skip("REDIS_TEST_URL not set")
+	}
+	q, err := NewRedis(url)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	testRedelivery(t, q)
+}
+
+func TestNATS_Redelivery(t *testing.T) {
+	url := os.Getenv("NATS_TEST_URL")
+	if url == "" {
+		t.Skip("NATS_TEST_URL not set")
+	}
+	q, err := NewNATS(url)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	testRedelivery(t, q)
+}
+
+func TestOpen_UnknownBackend(t *testing.T) {
+	if _, err := Open(Options{Backend: "kafka"}); err == nil {
+		t.Error("Expected an error for an unknown backend")
+	}
+}
@@ -3,37 +3,107 @@ package queue
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
 const (
-	exchangeName = "codereview"
-	queueName    = "pull_requests"
-	routingKey   = "pr.review"
+	exchangeName      = "codereview"
+	queueName         = "pull_requests"
+	routingKey        = "pr.review"
+	commandQueueName  = "pull_request_commands"
+	commandRoutingKey = "pr.command"
+
+	// commandConcurrency is how many commands a consumer handles at once, on
+	// top of its reviews
+	commandConcurrency = 2
+
+	// publishTimeout bounds a publish, including waiting for a reconnection and
+	// for the broker to confirm it
+	publishTimeout = 5 * time.Second
+
+	reconnectMinDelay = time.Second
+	reconnectMaxDelay = 30 * time.Second
 )
 
-// RabbitMQ implements message queue using RabbitMQ
+// RabbitMQ implements message queue using RabbitMQ. It reconnects with back-off
+// when the connection is lost, declaring the topology again and re-registering
+// consumers
 type RabbitMQ struct {
+	url string
+
+	mu      sync.Mutex
 	conn    *amqp.Connection
-	channel *amqp.Channel
+	channel *amqp.Channel // Publishing channel in confirm mode
+	ready   chan struct{} // Closed once connected
+	done    chan struct{}
+	once    sync.Once
 }
 
-// NewRabbitMQ creates a new RabbitMQ client
+// NewRabbitMQ creates a new RabbitMQ client. The first connection must succeed;
+// later ones are retried in the background
 func NewRabbitMQ(url string) (*RabbitMQ, error) {
-	conn, err := amqp.Dial(url)
+	r := &RabbitMQ{
+		url:   url,
+		ready: make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if err := r.connect(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// connect dials the broker, declares the topology and opens the publishing channel
+func (r *RabbitMQ) connect() error {
+	conn, err := amqp.Dial(r.url)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+		return fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+
+	if err := declareTopology(conn); err != nil {
+		conn.Close()
+		return err
 	}
 
 	channel, err := conn.Channel()
 	if err != nil {
 		conn.Close()
-		return nil, fmt.Errorf("failed to open channel: %w", err)
+		return fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	// Publisher confirms let Publish wait until the broker has stored the event
+	if err := channel.Confirm(false); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	channelClosed := channel.NotifyClose(make(chan *amqp.Error, 1))
+
+	r.mu.Lock()
+	r.conn = conn
+	r.channel = channel
+	close(r.ready)
+	r.mu.Unlock()
+
+	go r.watch(conn, connClosed, channelClosed)
+	return nil
+}
+
+// declareTopology declares the exchange and the durable review and command
+// queues and binds them
+func declareTopology(conn *amqp.Connection) error {
+	channel, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open channel: %w", err)
 	}
+	defer channel.Close()
 
 	// Declare exchange
 	err = channel.ExchangeDeclare(
@@ -46,14 +116,15 @@ func NewRabbitMQ(url string) (*RabbitMQ, error) {
 		nil,   // arguments
 	)
 	if err != nil {
-		channel.Close()
-		conn.Close()
-		return nil, fmt.Errorf("failed to declare exchange: %w", err)
+		return fmt.Errorf("failed to declare exchange: %w", err)
 	}
 
-	// Declare queue
+	// Declare queues
+	if err := declareReviewQueue(conn); err != nil {
+		return err
+	}
 	_, err = channel.QueueDeclare(
-		queueName,
+		commandQueueName,
 		true,  // durable
 		false, // delete when unused
 		false, // exclusive
@@ -61,75 +132,311 @@ func NewRabbitMQ(url string) (*RabbitMQ, error) {
 		nil,   // arguments
 	)
 	if err != nil {
-		channel.Close()
-		conn.Close()
-		return nil, fmt.Errorf("failed to declare queue: %w", err)
+		return fmt.Errorf("failed to declare queue: %w", err)
+	}
+
+	// Bind queues to exchange
+	bindings := map[string]string{queueName: routingKey, commandQueueName: commandRoutingKey}
+	for queue, key := range bindings {
+		if err := channel.QueueBind(queue, key, exchangeName, false, nil); err != nil {
+			return fmt.Errorf("failed to bind queue: %w", err)
+		}
 	}
 
-	// Bind queue to exchange
-	err = channel.QueueBind(
+	return nil
+}
+
+// declareReviewQueue declares the review queue with priorities. A queue's
+// arguments can't be changed, so a queue created before priorities were
+// supported is used as it is, in arrival order, until it is deleted
+func declareReviewQueue(conn *amqp.Connection) error {
+	channel, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open channel: %w", err)
+	}
+	defer channel.Close()
+
+	_, err = channel.QueueDeclare(
 		queueName,
-		routingKey,
-		exchangeName,
-		false,
-		nil,
+		true,  // durable
+		false, // delete when unused
+		false, // exclusive
+		false, // no-wait
+		amqp.Table{"x-max-priority": int32(MaxPriority)},
 	)
+	var amqpErr *amqp.Error
+	if !errors.As(err, &amqpErr) || amqpErr.Code != amqp.PreconditionFailed {
+		if err != nil {
+			return fmt.Errorf("failed to declare queue: %w", err)
+		}
+		return nil
+	}
+
+	// The failed declaration closed the channel
+	passive, err := conn.Channel()
 	if err != nil {
-		channel.Close()
-		conn.Close()
-		return nil, fmt.Errorf("failed to bind queue: %w", err)
+		return fmt.Errorf("failed to open channel: %w", err)
+	}
+	defer passive.Close()
+	if _, err := passive.QueueDeclarePassive(queueName, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare queue: %w", err)
 	}
+	log.Printf("Warning: queue %s was created without priorities; delete it once drained to enable them", queueName)
+	return nil
+}
 
-	return &RabbitMQ{
-		conn:    conn,
-		channel: channel,
-	}, nil
+// watch waits for the connection or publishing channel to close and reconnects
+// with exponential back-off until it succeeds or the client is closed
+func (r *RabbitMQ) watch(conn *amqp.Connection, connClosed, channelClosed chan *amqp.Error) {
+	var reason *amqp.Error
+	select {
+	case reason = <-connClosed:
+	case reason = <-channelClosed:
+	case <-r.done:
+		return
+	}
+
+	r.mu.Lock()
+	r.conn = nil
+	r.channel = nil
+	r.ready = make(chan struct{})
+	r.mu.Unlock()
+
+	// A channel error leaves the connection open; start over on a fresh one
+	conn.Close()
+
+	select {
+	case <-r.done:
+		return
+	default:
+	}
+	log.Printf("Lost connection to RabbitMQ: %v; reconnecting", reason)
+
+	delay := reconnectMinDelay
+	for {
+		select {
+		case <-time.After(delay):
+		case <-r.done:
+			return
+		}
+
+		if err := r.connect(); err != nil {
+			log.Printf("Failed to reconnect to RabbitMQ, retrying in %s: %v", delay, err)
+			delay *= 2
+			if delay > reconnectMaxDelay {
+				delay = reconnectMaxDelay
+			}
+			continue
+		}
+		log.Println("Reconnected to RabbitMQ")
+		return
+	}
 }
 
-// Publish publishes an event to the queue
+// session returns the current connection and publishing channel, waiting for a
+// reconnection in progress until ctx is done
+func (r *RabbitMQ) session(ctx context.Context) (*amqp.Connection, *amqp.Channel, error) {
+	for {
+		r.mu.Lock()
+		conn, channel, ready := r.conn, r.channel, r.ready
+		r.mu.Unlock()
+
+		if conn != nil && !conn.IsClosed() {
+			return conn, channel, nil
+		}
+
+		wait := ready
+		if conn != nil {
+			// Closed, but watch hasn't noticed yet
+			wait = nil
+		}
+		select {
+		case <-wait:
+		case <-time.After(50 * time.Millisecond):
+		case <-ctx.Done():
+			return nil, nil, fmt.Errorf("RabbitMQ is unavailable: %w", ctx.Err())
+		case <-r.done:
+			return nil, nil, ErrClosed
+		}
+	}
+}
+
+// Publish publishes an event to the review queue with normal priority
 func (r *RabbitMQ) Publish(event interface{}) error {
+	return r.PublishWith(event, defaultOptions)
+}
+
+// PublishWith publishes an event to the queue of its route, returning once the
+// broker has confirmed it. Publishes interrupted by a lost connection are
+// retried on the next one until publishTimeout
+func (r *RabbitMQ) PublishWith(event interface{}, opts PublishOptions) error {
 	body, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("failed to marshal event: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
 	defer cancel()
 
-	err = r.channel.PublishWithContext(
+	for {
+		_, channel, err := r.session(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to publish message: %w", err)
+		}
+
+		err = publishConfirmed(ctx, channel, body, opts)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("failed to publish message: %w", err)
+		}
+		log.Printf("Retrying publish: %v", err)
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-ctx.Done():
+			return fmt.Errorf("failed to publish message: %w", err)
+		}
+	}
+}
+
+// publishConfirmed publishes a persistent message and waits for the broker's confirm
+func publishConfirmed(ctx context.Context, channel *amqp.Channel, body []byte, opts PublishOptions) error {
+	key := routingKey
+	if opts.Route == RouteCommand {
+		key = commandRoutingKey
+	}
+	priority := opts.Priority
+	if priority > MaxPriority {
+		priority = MaxPriority
+	}
+
+	confirm, err := channel.PublishWithDeferredConfirmWithContext(
 		ctx,
 		exchangeName,
-		routingKey,
+		key,
 		false, // mandatory
 		false, // immediate
 		amqp.Publishing{
 			ContentType:  "application/json",
 			Body:         body,
 			DeliveryMode: amqp.Persistent,
+			Priority:     priority,
 			Timestamp:    time.Now(),
 		},
 	)
 	if err != nil {
-		return fmt.Errorf("failed to publish message: %w", err)
+		return err
 	}
 
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		// Also reported for publishes pending when the channel closed
+		return errors.New("broker did not confirm the message")
+	}
 	return nil
 }
 
-// Consume consumes events from the queue
-func (r *RabbitMQ) Consume(handler func([]byte) error) error {
-	// Set QoS to process one message at a time
-	err := r.channel.Qos(
-		1,     // prefetch count
-		0,     // prefetch size
-		false, // global
+// Consume consumes reviews, handling up to concurrency at once so a newer push
+// can cancel a review that is still running, and commands with their own
+// workers. When the connection is lost the consumers are registered again once
+// it is restored; unacknowledged messages are redelivered by the broker
+func (r *RabbitMQ) Consume(concurrency int, handler func([]byte) error) error {
+	var wg sync.WaitGroup
+	consumers := map[string]int{
+		queueName:        normalizeConcurrency(concurrency),
+		commandQueueName: commandConcurrency,
+	}
+	for queue, n := range consumers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.consume(queue, n, handler)
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// consume consumes one queue until the client is closed
+func (r *RabbitMQ) consume(queue string, concurrency int, handler func([]byte) error) {
+	for {
+		conn, _, err := r.session(context.Background())
+		if errors.Is(err, ErrClosed) {
+			return
+		}
+
+		channel, msgs, err := r.register(conn, queue, concurrency)
+		if err != nil {
+			log.Printf("Failed to register consumer, retrying: %v", err)
+			select {
+			case <-time.After(reconnectMinDelay):
+				continue
+			case <-r.done:
+				return
+			}
+		}
+
+		log.Printf("Waiting for messages on %s...", queue)
+
+		var wg sync.WaitGroup
+		for i := 0; i < concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for msg := range msgs {
+					if err := handler(msg.Body); err != nil {
+						log.Printf("Error processing message: %v", err)
+						// RabbitMQ redelivers a requeued message at once, so hold it
+						// first to keep a message that always fails from spinning
+						select {
+						case <-time.After(retryDelay):
+						case <-r.done:
+						}
+						msg.Nack(false, true)
+					} else {
+						// Ack the message
+						msg.Ack(false)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		channel.Close()
+
+		select {
+		case <-r.done:
+			return
+		default:
+			log.Printf("Consumer channel for %s closed; registering again", queue)
+		}
+	}
+}
+
+// register opens a consumer channel on conn and starts consuming the queue
+func (r *RabbitMQ) register(conn *amqp.Connection, queue string, concurrency int) (*amqp.Channel, <-chan amqp.Delivery, error) {
+	channel, err := conn.Channel()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	// Only take as many messages as can be processed at once
+	err = channel.Qos(
+		concurrency, // prefetch count
+		0,           // prefetch size
+		false,       // global
 	)
 	if err != nil {
-		return fmt.Errorf("failed to set QoS: %w", err)
+		channel.Close()
+		return nil, nil, fmt.Errorf("failed to set QoS: %w", err)
 	}
 
-	msgs, err := r.channel.Consume(
-		queueName,
+	msgs, err := channel.Consume(
+		queue,
 		"",    // consumer tag
 		false, // auto-ack
 		false, // exclusive
@@ -138,32 +445,22 @@ func (r *RabbitMQ) Consume(handler func([]byte) error) error {
 		nil,   // args
 	)
 	if err != nil {
-		return fmt.Errorf("failed to register consumer: %w", err)
-	}
-
-	log.Println("Waiting for messages...")
-
-	for msg := range msgs {
-		if err := handler(msg.Body); err != nil {
-			log.Printf("Error processing message: %v", err)
-			// Nack and requeue the message
-			msg.Nack(false, true)
-		} else {
-			// Ack the message
-			msg.Ack(false)
-		}
+		channel.Close()
+		return nil, nil, fmt.Errorf("failed to register consumer: %w", err)
 	}
 
-	return nil
+	return channel, msgs, nil
 }
 
-// Close closes the RabbitMQ connection
+// Close stops reconnecting and consuming and closes the RabbitMQ connection
 func (r *RabbitMQ) Close() error {
-	if r.channel != nil {
-		r.channel.Close()
-	}
-	if r.conn != nil {
-		return r.conn.Close()
+	r.once.Do(func() { close(r.done) })
+
+	r.mu.Lock()
+	conn := r.conn
+	r.mu.Unlock()
+	if conn != nil {
+		return conn.Close()
 	}
 	return nil
 }
@@ -0,0 +1,198 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisStream = "codereview:pull_requests"
+	redisGroup  = "workers"
+
+	// redisClaimIdle is how long a message may go without a heartbeat before
+	// another consumer claims it, e.g. after its worker crashed
+	redisClaimIdle = 5 * time.Minute
+	redisBlock     = 5 * time.Second
+)
+
+// Redis implements message queue using a Redis stream and consumer group
+type Redis struct {
+	client   *redis.Client
+	consumer string
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// NewRedis creates a new Redis Streams client
+func NewRedis(url string) (*Redis, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	// Consumers of the group are named per process so stale entries can be
+	// told apart from our own
+	hostname, _ := os.Hostname()
+	consumerCtx, consumerCancel := context.WithCancel(context.Background())
+	return &Redis{
+		client:   client,
+		consumer: fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		ctx:      consumerCtx,
+		cancel:   consumerCancel,
+	}, nil
+}
+
+// Publish publishes an event to the stream
+func (r *Redis) Publish(event interface{}) error {
+	return r.PublishWith(event, defaultOptions)
+}
+
+// PublishWith publishes an event to the stream. Streams are consumed in arrival
+// order, so routes and priorities are ignored
+func (r *Redis) PublishWith(event interface{}, opts PublishOptions) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	return r.add(body)
+}
+
+// add appends a message body to the stream
+func (r *Redis) add(body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := r.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: redisStream,
+		Values: map[string]interface{}{"body": body},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+	return nil
+}
+
+// Consume consumes events from the stream until the queue is closed. Messages
+// left pending by a consumer that stopped are claimed after redisClaimIdle
+func (r *Redis) Consume(concurrency int, handler func([]byte) error) error {
+	err := r.client.XGroupCreateMkStream(r.ctx, redisStream, redisGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group: %w", err)
+	}
+
+	log.Println("Waiting for messages...")
+
+	var wg sync.WaitGroup
+	for i := 0; i < normalizeConcurrency(concurrency); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r.ctx.Err() == nil {
+				msg, ok := r.next()
+				if ok {
+					r.handle(msg, handler)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// next claims an abandoned message or waits for a new one
+func (r *Redis) next() (redis.XMessage, bool) {
+	claimed, _, err := r.client.XAutoClaim(r.ctx, &redis.XAutoClaimArgs{
+		Stream:   redisStream,
+		Group:    redisGroup,
+		Consumer: r.consumer,
+		MinIdle:  redisClaimIdle,
+		Start:    "0-0",
+		Count:    1,
+	}).Result()
+	if err == nil && len(claimed) > 0 {
+		return claimed[0], true
+	}
+
+	streams, err := r.client.XReadGroup(r.ctx, &redis.XReadGroupArgs{
+		Group:    redisGroup,
+		Consumer: r.consumer,
+		Streams:  []string{redisStream, ">"},
+		Count:    1,
+		Block:    redisBlock,
+	}).Result()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) && r.ctx.Err() == nil {
+			log.Printf("Error reading from Redis: %v", err)
+			time.Sleep(time.Second)
+		}
+		return redis.XMessage{}, false
+	}
+	for _, stream := range streams {
+		for _, msg := range stream.Messages {
+			return msg, true
+		}
+	}
+	return redis.XMessage{}, false
+}
+
+// handle runs the handler, acknowledging the message on success and appending
+// it to the stream again after retryDelay on failure
+func (r *Redis) handle(msg redis.XMessage, handler func([]byte) error) {
+	stop := heartbeat(redisClaimIdle/3, func() {
+		// Claiming our own message resets its idle time
+		r.client.XClaimJustID(context.Background(), &redis.XClaimArgs{
+			Stream:   redisStream,
+			Group:    redisGroup,
+			Consumer: r.consumer,
+			Messages: []string{msg.ID},
+		})
+	})
+	body, _ := msg.Values["body"].(string)
+	err := handler([]byte(body))
+	stop()
+
+	if err != nil {
+		log.Printf("Error processing message: %v", err)
+		select {
+		case <-time.After(retryDelay):
+		case <-r.ctx.Done():
+			// Left pending so another consumer claims it
+			return
+		}
+		if err := r.add([]byte(body)); err != nil {
+			log.Printf("Failed to requeue message %s: %v", msg.ID, err)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.client.XAck(ctx, redisStream, redisGroup, msg.ID).Err(); err != nil {
+		log.Printf("Failed to acknowledge message %s: %v", msg.ID, err)
+	}
+	r.client.XDel(ctx, redisStream, msg.ID)
+}
+
+// Close stops consumers and closes the Redis connection
+func (r *Redis) Close() error {
+	r.cancel()
+	return r.client.Close()
+}
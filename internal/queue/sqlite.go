@@ -0,0 +1,192 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/carlr/codereviewtool/internal/sqlite"
+)
+
+const (
+	// sqliteLease is how long a job may go without a heartbeat before it is
+	// handed out again, e.g. after the process crashed
+	sqliteLease = 5 * time.Minute
+	// sqlitePoll is how often consumers look for jobs published by other processes
+	sqlitePoll = time.Second
+)
+
+// SQLite is a queue kept in a SQLite file, for single-binary deployments whose
+// jobs should survive restarts. Commands are delivered first, then reviews by
+// priority
+type SQLite struct {
+	db     *sql.DB
+	notify chan struct{}
+	done   chan struct{}
+	once   sync.Once
+
+	// RetryDelay is how long a failed message waits before redelivery
+	RetryDelay time.Duration
+}
+
+// NewSQLite opens the queue in the SQLite database at path, creating it if needed
+func NewSQLite(path string) (*SQLite, error) {
+	db, err := sqlite.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS queue_jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		body BLOB NOT NULL,
+		available_at INTEGER NOT NULL, -- Unix milliseconds
+		locked_until INTEGER NOT NULL DEFAULT 0
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create queue table: %w", err)
+	}
+
+	// Queues created before priorities were supported lack the column
+	var hasPriority bool
+	err = db.QueryRow(`SELECT COUNT(*) > 0 FROM pragma_table_info('queue_jobs') WHERE name = 'priority'`).Scan(&hasPriority)
+	if err == nil && !hasPriority {
+		_, err = db.Exec(`ALTER TABLE queue_jobs ADD COLUMN priority INTEGER NOT NULL DEFAULT 0`)
+	}
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to add queue priorities: %w", err)
+	}
+
+	return &SQLite{
+		db:         db,
+		notify:     make(chan struct{}, 1),
+		done:       make(chan struct{}),
+		RetryDelay: retryDelay,
+	}, nil
+}
+
+// Publish publishes an event to the queue as a normal priority review
+func (s *SQLite) Publish(event interface{}) error {
+	return s.PublishWith(event, defaultOptions)
+}
+
+// PublishWith publishes an event to the queue; commands rank above every review
+func (s *SQLite) PublishWith(event interface{}, opts PublishOptions) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	priority := int(opts.Priority)
+	if opts.Route == RouteCommand {
+		priority = int(MaxPriority) + 1
+	}
+	_, err = s.db.Exec(`INSERT INTO queue_jobs (body, available_at, priority) VALUES (?, ?, ?)`,
+		body, time.Now().UnixMilli(), priority)
+	if err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+
+	// Wake a consumer in this process instead of waiting for the next poll
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Consume consumes events from the queue until it is closed, retrying messages
+// the handler fails after RetryDelay
+func (s *SQLite) Consume(concurrency int, handler func([]byte) error) error {
+	log.Println("Waiting for messages...")
+
+	var wg sync.WaitGroup
+	for i := 0; i < normalizeConcurrency(concurrency); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				id, body, err := s.claim()
+				if err != nil {
+					log.Printf("Error reading from queue: %v", err)
+				}
+				if err == nil && body != nil {
+					s.handle(id, body, handler)
+					continue
+				}
+
+				select {
+				case <-s.notify:
+				case <-time.After(sqlitePoll):
+				case <-s.done:
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// claim leases the oldest available job, returning a nil body if there is none
+func (s *SQLite) claim() (int64, []byte, error) {
+	now := time.Now()
+	var id int64
+	var body []byte
+	err := s.db.QueryRow(`
+		UPDATE queue_jobs SET locked_until = ?
+		WHERE id = (
+			SELECT id FROM queue_jobs
+			WHERE available_at <= ? AND locked_until <= ?
+			ORDER BY priority DESC, id LIMIT 1
+		)
+		RETURNING id, body`,
+		now.Add(sqliteLease).UnixMilli(), now.UnixMilli(), now.UnixMilli(),
+	).Scan(&id, &body)
+	if err == sql.ErrNoRows {
+		return 0, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	return id, body, nil
+}
+
+// handle runs the handler, deleting the job on success and making it
+// available again after RetryDelay on failure
+func (s *SQLite) handle(id int64, body []byte, handler func([]byte) error) {
+	stop := heartbeat(sqliteLease/3, func() {
+		s.db.Exec(`UPDATE queue_jobs SET locked_until = ? WHERE id = ?`,
+			time.Now().Add(sqliteLease).UnixMilli(), id)
+	})
+	err := handler(body)
+	stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err != nil {
+		log.Printf("Error processing message: %v", err)
+		_, err = s.db.ExecContext(ctx,
+			`UPDATE queue_jobs SET available_at = ?, locked_until = 0 WHERE id = ?`,
+			time.Now().Add(s.RetryDelay).UnixMilli(), id)
+		if err != nil {
+			log.Printf("Failed to requeue message %d: %v", id, err)
+		}
+		return
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM queue_jobs WHERE id = ?`, id); err != nil {
+		log.Printf("Failed to acknowledge message %d: %v", id, err)
+	}
+}
+
+// Close stops consumers and closes the database
+func (s *SQLite) Close() error {
+	s.once.Do(func() { close(s.done) })
+	return s.db.Close()
+}
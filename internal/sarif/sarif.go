@@ -0,0 +1,247 @@
+package sarif
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+const (
+	// Version is the SARIF specification version produced by this package
+	Version = "2.1.0"
+	// SchemaURI is the JSON schema for SARIF 2.1.0 logs
+	SchemaURI = "https://json.schemastore.org/sarif-2.1.0.json"
+
+	defaultCategory = "general"
+	// fingerprintKey names the partial fingerprint code scanning uses to match
+	// results across uploads
+	fingerprintKey = "aiReviewFindingHash/v1"
+)
+
+// Log is the top-level SARIF document
+type Log struct {
+	Schema  string `json:"$schema"`
+	Version string `json:"version"`
+	Runs    []Run  `json:"runs"`
+}
+
+// Run is the output of a single invocation of an analysis tool
+type Run struct {
+	Tool    Tool     `json:"tool"`
+	Results []Result `json:"results"`
+}
+
+// Tool describes the analysis tool that produced a run
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+// Driver is the tool component that ran the analysis
+type Driver struct {
+	Name           string `json:"name"`
+	Version        string `json:"version,omitempty"`
+	InformationURI string `json:"informationUri,omitempty"`
+	Rules          []Rule `json:"rules"`
+}
+
+// Rule describes a class of findings
+type Rule struct {
+	ID                   string               `json:"id"`
+	Name                 string               `json:"name,omitempty"`
+	ShortDescription     Message              `json:"shortDescription"`
+	DefaultConfiguration DefaultConfiguration `json:"defaultConfiguration"`
+	Properties           *RuleProperties      `json:"properties,omitempty"`
+}
+
+// DefaultConfiguration holds the default severity of a rule
+type DefaultConfiguration struct {
+	Level string `json:"level"`
+}
+
+// RuleProperties carries rule metadata understood by GitHub code scanning
+type RuleProperties struct {
+	Tags []string `json:"tags,omitempty"`
+}
+
+// Result is a single finding
+type Result struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             Message           `json:"message"`
+	Locations           []Location        `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+}
+
+// Message is a plain-text SARIF message
+type Message struct {
+	Text string `json:"text"`
+}
+
+// Location points a result at a file and, optionally, a line
+type Location struct {
+	PhysicalLocation PhysicalLocation `json:"physicalLocation"`
+}
+
+// PhysicalLocation identifies a region of an artifact
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Region           *Region          `json:"region,omitempty"`
+}
+
+// ArtifactLocation is the repository-relative URI of a file
+type ArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// Region is a range of lines in a file
+type Region struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine,omitempty"`
+}
+
+// FromReview converts a review into a SARIF log with one rule per comment category
+func FromReview(review *llm.CodeReviewResponse, toolName, toolVersion string) *Log {
+	driver := Driver{
+		Name:    toolName,
+		Version: toolVersion,
+		Rules:   []Rule{},
+	}
+	results := make([]Result, 0, len(review.Comments))
+
+	ruleIndex := make(map[string]int)
+	occurrences := make(map[string]int)
+	for _, comment := range review.Comments {
+		if comment.Filename == "" {
+			continue
+		}
+
+		id := ruleID(comment)
+		index, ok := ruleIndex[id]
+		if !ok {
+			index = len(driver.Rules)
+			ruleIndex[id] = index
+			driver.Rules = append(driver.Rules, newRule(id, comment))
+		}
+
+		location := Location{PhysicalLocation: PhysicalLocation{
+			ArtifactLocation: ArtifactLocation{URI: comment.Filename},
+		}}
+		// Lines on the old side of the diff don't exist in the analyzed revision
+		if comment.Line > 0 && comment.Side != "LEFT" {
+			region := &Region{StartLine: comment.Line}
+			if comment.StartLine > 0 && comment.StartLine < comment.Line {
+				region.StartLine, region.EndLine = comment.StartLine, comment.Line
+			}
+			location.PhysicalLocation.Region = region
+		}
+
+		// Identical findings in one file are told apart by their order, as code
+		// scanning does for its own line hashes
+		hash := fingerprint(id, comment)
+		occurrences[hash]++
+		results = append(results, Result{
+			RuleID:              id,
+			RuleIndex:           index,
+			Level:               Level(comment.Severity),
+			Message:             Message{Text: comment.Body},
+			Locations:           []Location{location},
+			PartialFingerprints: map[string]string{fingerprintKey: hash + ":" + strconv.Itoa(occurrences[hash])},
+		})
+	}
+
+	// Rules take the highest level of any of their results as their default
+	for _, result := range results {
+		rule := &driver.Rules[result.RuleIndex]
+		if levelRank(result.Level) > levelRank(rule.DefaultConfiguration.Level) {
+			rule.DefaultConfiguration.Level = result.Level
+		}
+	}
+
+	return &Log{
+		Schema:  SchemaURI,
+		Version: Version,
+		Runs: []Run{{
+			Tool:    Tool{Driver: driver},
+			Results: results,
+		}},
+	}
+}
+
+// Marshal encodes a SARIF log as indented JSON
+func Marshal(log *Log) ([]byte, error) {
+	return json.MarshalIndent(log, "", "  ")
+}
+
+// Level maps a review severity onto a SARIF result level
+func Level(severity string) string {
+	switch severity {
+	case "error":
+		return "error"
+	case "warning":
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// levelRank orders SARIF levels from least to most severe
+func levelRank(level string) int {
+	switch level {
+	case "error":
+		return 3
+	case "warning":
+		return 2
+	case "note":
+		return 1
+	}
+	return 0
+}
+
+// ruleID derives a stable rule identifier from a comment's category, keeping
+// static analysis findings under their tool's name
+func ruleID(comment llm.ReviewComment) string {
+	category := strings.ToLower(strings.TrimSpace(comment.Category))
+	if category == "" {
+		category = defaultCategory
+	}
+	if comment.Source != "" {
+		return "lint/" + strings.ReplaceAll(comment.Source, " ", "-")
+	}
+	return "ai-review/" + strings.ReplaceAll(category, " ", "-")
+}
+
+// fingerprint identifies a finding across uploads for different commits by its
+// rule, file and the code it is about, ignoring whitespace, so it survives lines
+// moving. Comments without a snippet, such as file-level ones, use their message
+func fingerprint(id string, comment llm.ReviewComment) string {
+	text := comment.Snippet
+	if strings.TrimSpace(text) == "" {
+		text = comment.Body
+	}
+	sum := sha256.Sum256([]byte(id + "\x00" + comment.Filename + "\x00" + strings.Join(strings.Fields(text), " ")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// newRule creates the rule describing comments like the given one
+func newRule(id string, comment llm.ReviewComment) Rule {
+	name := id[strings.LastIndex(id, "/")+1:]
+	description := "AI code review: " + name
+	tags := []string{name}
+	if comment.Source != "" {
+		description = "Static analysis finding reported by " + comment.Source
+		tags = []string{"static-analysis"}
+	}
+
+	return Rule{
+		ID:                   id,
+		Name:                 name,
+		ShortDescription:     Message{Text: description},
+		DefaultConfiguration: DefaultConfiguration{Level: "note"},
+		Properties:           &RuleProperties{Tags: tags},
+	}
+}
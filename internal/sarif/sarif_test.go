@@ -0,0 +1,101 @@
+package sarif
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/carlr/codereviewtool/pkg/llm"
+)
+
+func TestFromReview(t *testing.T) {
+	review := &llm.CodeReviewResponse{
+		Summary: "Looks risky",
+		Comments: []llm.ReviewComment{
+			{Filename: "auth.go", Line: 10, Body: "SQL injection", Severity: "error", Category: "security"},
+			{Filename: "auth.go", Line: 20, Body: "Token logged", Severity: "warning", Category: "Security"},
+			{Filename: "util.go", Line: 0, Body: "Consider splitting this file", Severity: "info"},
+			{Filename: "util.go", Line: 5, Body: "go vet: printf mismatch", Severity: "warning", Source: "go vet"},
+		},
+	}
+
+	log := FromReview(review, "AI Code Review", "1.0")
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("Expected a single SARIF 2.1.0 run, got version %s with %d runs", log.Version, len(log.Runs))
+	}
+	run := log.Runs[0]
+	if len(run.Results) != 4 {
+		t.Fatalf("Expected 4 results, got %d", len(run.Results))
+	}
+
+	rules := make(map[string]Rule)
+	for _, rule := range run.Tool.Driver.Rules {
+		rules[rule.ID] = rule
+	}
+	if len(rules) != 3 {
+		t.Errorf("Expected 3 rules (security, general, go vet), got %d", len(rules))
+	}
+	if rules["ai-review/security"].DefaultConfiguration.Level != "error" {
+		t.Errorf("Expected security rule to default to the highest level, got %s", rules["ai-review/security"].DefaultConfiguration.Level)
+	}
+	if _, ok := rules["lint/go-vet"]; !ok {
+		t.Error("Expected static analysis findings to use a rule per tool")
+	}
+
+	if run.Results[1].RuleID != "ai-review/security" || run.Results[1].Level != "warning" {
+		t.Errorf("Unexpected second result: %+v", run.Results[1])
+	}
+	if run.Results[2].Level != "note" || run.Results[2].Locations[0].PhysicalLocation.Region != nil {
+		t.Errorf("Expected file-level info comment to be a note without a region, got %+v", run.Results[2])
+	}
+	for _, result := range run.Results {
+		if run.Tool.Driver.Rules[result.RuleIndex].ID != result.RuleID {
+			t.Errorf("Result rule index %d doesn't match rule %s", result.RuleIndex, result.RuleID)
+		}
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	data, err := Marshal(FromReview(&llm.CodeReviewResponse{}, "AI Code Review", ""))
+	if err != nil {
+		t.Fatalf("Marshal() failed: %v", err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Expected valid JSON: %v", err)
+	}
+	if decoded["$schema"] != SchemaURI {
+		t.Errorf("Expected $schema %s, got %v", SchemaURI, decoded["$schema"])
+	}
+	runs := decoded["runs"].([]interface{})
+	results := runs[0].(map[string]interface{})["results"]
+	if results == nil {
+		t.Error("Expected an empty results array rather than null")
+	}
+}
+
+func TestFromReview_Fingerprints(t *testing.T) {
+	comment := llm.ReviewComment{Filename: "auth.go", Line: 10, Body: "SQL injection", Severity: "error", Category: "security", Snippet: "\tdb.Query(q + id)"}
+	moved := comment
+	moved.Line, moved.Body, moved.Snippet = 14, "Possible SQL injection", "db.Query(q  +  id)"
+	other := comment
+	other.Snippet = "db.Exec(q + id)"
+
+	fingerprints := func(comments ...llm.ReviewComment) []string {
+		var values []string
+		for _, result := range FromReview(&llm.CodeReviewResponse{Comments: comments}, "AI Code Review", "").Runs[0].Results {
+			values = append(values, result.PartialFingerprints[fingerprintKey])
+		}
+		return values
+	}
+
+	first, second := fingerprints(comment), fingerprints(moved)
+	if first[0] == "" || first[0] != second[0] {
+		t.Errorf("Expected a moved finding to keep its fingerprint, got %q and %q", first[0], second[0])
+	}
+	both := fingerprints(comment, moved, other)
+	if both[0] == both[1] || both[0] == both[2] {
+		t.Errorf("Expected distinct fingerprints for distinct results, got %v", both)
+	}
+}
@@ -0,0 +1,297 @@
+package scm
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxCachedBodySize is the largest response body kept in the cache
+	maxCachedBodySize = 1 << 20
+	// postgresCacheTTL is how long a shared cache entry is kept after it was last written
+	postgresCacheTTL = 7 * 24 * time.Hour
+	// postgresCacheCleanupInterval is how often a worker deletes expired shared entries
+	postgresCacheCleanupInterval = time.Hour
+)
+
+// commitSHA matches a full commit SHA, in its SHA-1 or SHA-256 form
+var commitSHA = regexp.MustCompile(`^([0-9a-f]{40}|[0-9a-f]{64})$`)
+
+// CachedResponse is a GitHub API response stored for conditional requests
+type CachedResponse struct {
+	ETag   string
+	Header http.Header
+	Body   []byte
+}
+
+// ResponseCache stores responses by request so they can be revalidated with an
+// ETag instead of downloaded again. Responses to conditional requests that come
+// back 304 Not Modified don't count against the rate limit
+type ResponseCache interface {
+	// Get returns the cached response for key, or nil if there is none
+	Get(ctx context.Context, key string) (*CachedResponse, error)
+	// Set stores a response under key
+	Set(ctx context.Context, key string, resp *CachedResponse) error
+}
+
+// etagTransport sends conditional GET requests for cached responses and serves
+// the cached copy when GitHub reports it unchanged
+type etagTransport struct {
+	base  http.RoundTripper
+	cache ResponseCache
+	token string // Fingerprint of the token, so cached responses aren't shared between tokens
+}
+
+// RoundTrip sends the request, revalidating a cached response if there is one
+func (t *etagTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" || pinnedToCommit(req) {
+		return t.base.RoundTrip(req)
+	}
+
+	key := t.key(req)
+	cached, err := t.cache.Get(req.Context(), key)
+	if err != nil {
+		log.Printf("Failed to read GitHub response cache: %v", err)
+		cached = nil
+	}
+	if cached != nil {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		cacheHits.Add(1)
+		resp.Body.Close()
+		header := cached.Header.Clone()
+		// Keep the current rate limit headers rather than the cached ones
+		for name, values := range resp.Header {
+			if strings.HasPrefix(name, "X-Ratelimit-") {
+				header[name] = values
+			}
+		}
+		// The convention of HTTP caches, which go-github honors by not taking the
+		// response's rate limit as its own
+		header.Set("X-From-Cache", "1")
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Proto:         resp.Proto,
+			ProtoMajor:    resp.ProtoMajor,
+			ProtoMinor:    resp.ProtoMinor,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(cached.Body)),
+			ContentLength: int64(len(cached.Body)),
+			Request:       req,
+		}, nil
+	}
+	cacheMisses.Add(1)
+
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" || resp.ContentLength > maxCachedBodySize {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCachedBodySize+1))
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if len(body) <= maxCachedBodySize {
+		entry := &CachedResponse{ETag: etag, Header: resp.Header.Clone(), Body: body}
+		if err := t.cache.Set(req.Context(), key, entry); err != nil {
+			log.Printf("Failed to write GitHub response cache: %v", err)
+		}
+	}
+	return resp, nil
+}
+
+// key identifies a request by token, URL and requested media type
+func (t *etagTransport) key(req *http.Request) string {
+	return t.token + " " + req.Header.Get("Accept") + " " + req.URL.String()
+}
+
+// pinnedToCommit reports whether a request reads content at a fixed commit, e.g.
+// a file at the head SHA. Such responses never change, so caching them would
+// only grow the cache
+func pinnedToCommit(req *http.Request) bool {
+	return commitSHA.MatchString(req.URL.Query().Get("ref"))
+}
+
+// tokenFingerprint returns a short, non-reversible identifier for a token
+func tokenFingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:4])
+}
+
+// memoryCache is an in-process ResponseCache that evicts the least recently used
+// entries once their total size exceeds maxBytes
+type memoryCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	entries  map[string]*list.Element
+	order    *list.List
+}
+
+type memoryEntry struct {
+	key  string
+	resp *CachedResponse
+	size int64
+}
+
+// NewMemoryCache creates an in-process response cache holding up to maxBytes of
+// responses, counting their keys, headers and bodies
+func NewMemoryCache(maxBytes int64) ResponseCache {
+	return &memoryCache{
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// entrySize approximates the memory a cached response takes
+func entrySize(key string, resp *CachedResponse) int64 {
+	size := len(key) + len(resp.ETag) + len(resp.Body)
+	for name, values := range resp.Header {
+		size += len(name)
+		for _, value := range values {
+			size += len(value)
+		}
+	}
+	return int64(size)
+}
+
+// Get returns the cached response for key, or nil if there is none
+func (c *memoryCache) Get(ctx context.Context, key string) (*CachedResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*memoryEntry).resp, nil
+}
+
+// Set stores a response under key
+func (c *memoryCache) Set(ctx context.Context, key string, resp *CachedResponse) error {
+	size := entrySize(key, resp)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	if size > c.maxBytes {
+		return nil
+	}
+	c.entries[key] = c.order.PushFront(&memoryEntry{key: key, resp: resp, size: size})
+	c.size += size
+	for c.size > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+	return nil
+}
+
+// remove drops an entry; the caller must hold the lock
+func (c *memoryCache) remove(elem *list.Element) {
+	entry := elem.Value.(*memoryEntry)
+	c.order.Remove(elem)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+}
+
+// postgresCache is a ResponseCache shared by all workers through Postgres. Entries
+// expire postgresCacheTTL after they were last written
+type postgresCache struct {
+	db *sql.DB
+
+	mu          sync.Mutex
+	lastCleanup time.Time
+}
+
+// NewPostgresCache creates a response cache in the github_response_cache table,
+// which migrations/002_github_response_cache.sql creates
+func NewPostgresCache(ctx context.Context, db *sql.DB) (ResponseCache, error) {
+	if _, err := db.ExecContext(ctx, `SELECT 1 FROM github_response_cache LIMIT 0`); err != nil {
+		return nil, fmt.Errorf("response cache table unavailable (apply migrations/002_github_response_cache.sql): %w", err)
+	}
+	return &postgresCache{db: db}, nil
+}
+
+// Get returns the cached response for key, or nil if there is none
+func (c *postgresCache) Get(ctx context.Context, key string) (*CachedResponse, error) {
+	var resp CachedResponse
+	var header []byte
+	err := c.db.QueryRowContext(ctx, `
+		SELECT etag, header, body FROM github_response_cache
+		WHERE cache_key = $1 AND updated_at > NOW() - $2 * INTERVAL '1 second'`,
+		key, int(postgresCacheTTL.Seconds()),
+	).Scan(&resp.ETag, &header, &resp.Body)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached response: %w", err)
+	}
+	if err := json.Unmarshal(header, &resp.Header); err != nil {
+		return nil, fmt.Errorf("failed to decode cached headers: %w", err)
+	}
+	return &resp, nil
+}
+
+// Set stores a response under key
+func (c *postgresCache) Set(ctx context.Context, key string, resp *CachedResponse) error {
+	header, err := json.Marshal(resp.Header)
+	if err != nil {
+		return fmt.Errorf("failed to encode headers: %w", err)
+	}
+	_, err = c.db.ExecContext(ctx, `
+		INSERT INTO github_response_cache (cache_key, etag, header, body, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (cache_key) DO UPDATE
+		SET etag = EXCLUDED.etag, header = EXCLUDED.header, body = EXCLUDED.body, updated_at = NOW()`,
+		key, resp.ETag, header, resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to write cached response: %w", err)
+	}
+	return c.cleanup(ctx)
+}
+
+// cleanup deletes expired entries, at most once per postgresCacheCleanupInterval
+// per worker
+func (c *postgresCache) cleanup(ctx context.Context) error {
+	c.mu.Lock()
+	if time.Since(c.lastCleanup) < postgresCacheCleanupInterval {
+		c.mu.Unlock()
+		return nil
+	}
+	c.lastCleanup = time.Now()
+	c.mu.Unlock()
+
+	_, err := c.db.ExecContext(ctx,
+		`DELETE FROM github_response_cache WHERE updated_at < NOW() - $1 * INTERVAL '1 second'`,
+		int(postgresCacheTTL.Seconds()))
+	if err != nil {
+		return fmt.Errorf("failed to expire cached responses: %w", err)
+	}
+	return nil
+}
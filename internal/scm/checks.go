@@ -0,0 +1,91 @@
+package scm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+)
+
+// maxAnnotationsPerRequest is GitHub's limit on annotations in one check run update
+const maxAnnotationsPerRequest = 50
+
+// CheckAnnotation is a finding attached to a line range of a check run
+type CheckAnnotation struct {
+	Path      string
+	StartLine int
+	EndLine   int
+	Level     string // "notice", "warning", "failure"
+	Title     string
+	Message   string
+}
+
+// CheckRunOutput is the report shown on a check run's page
+type CheckRunOutput struct {
+	Title       string
+	Summary     string
+	Annotations []CheckAnnotation
+}
+
+// CreateCheckRun starts an in-progress check run on a commit and returns its ID.
+// Check runs can only be created with a GitHub App installation token
+func (g *GitHubClient) CreateCheckRun(ctx context.Context, owner, repo, name, headSHA string) (int64, error) {
+	checkRun, _, err := g.client.Checks.CreateCheckRun(ctx, owner, repo, github.CreateCheckRunOptions{
+		Name:      name,
+		HeadSHA:   headSHA,
+		Status:    github.String("in_progress"),
+		StartedAt: &github.Timestamp{Time: time.Now()},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create check run: %w", err)
+	}
+	return checkRun.GetID(), nil
+}
+
+// CompleteCheckRun finishes a check run with a conclusion and output. Annotations
+// beyond GitHub's per-request limit are sent in additional updates first
+func (g *GitHubClient) CompleteCheckRun(ctx context.Context, owner, repo string, checkRunID int64, name, conclusion string, output CheckRunOutput) error {
+	annotations := make([]*github.CheckRunAnnotation, 0, len(output.Annotations))
+	for _, a := range output.Annotations {
+		annotations = append(annotations, &github.CheckRunAnnotation{
+			Path:            github.String(a.Path),
+			StartLine:       github.Int(a.StartLine),
+			EndLine:         github.Int(a.EndLine),
+			AnnotationLevel: github.String(a.Level),
+			Title:           github.String(a.Title),
+			Message:         github.String(a.Message),
+		})
+	}
+
+	checkOutput := func(batch []*github.CheckRunAnnotation) *github.CheckRunOutput {
+		return &github.CheckRunOutput{
+			Title:       github.String(output.Title),
+			Summary:     github.String(output.Summary),
+			Annotations: batch,
+		}
+	}
+
+	for len(annotations) > maxAnnotationsPerRequest {
+		_, _, err := g.client.Checks.UpdateCheckRun(ctx, owner, repo, checkRunID, github.UpdateCheckRunOptions{
+			Name:   name,
+			Output: checkOutput(annotations[:maxAnnotationsPerRequest]),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add check run annotations: %w", err)
+		}
+		annotations = annotations[maxAnnotationsPerRequest:]
+	}
+
+	_, _, err := g.client.Checks.UpdateCheckRun(ctx, owner, repo, checkRunID, github.UpdateCheckRunOptions{
+		Name:        name,
+		Status:      github.String("completed"),
+		Conclusion:  github.String(conclusion),
+		CompletedAt: &github.Timestamp{Time: time.Now()},
+		Output:      checkOutput(annotations),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete check run: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package scm
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newEnterpriseServer emulates the GitHub Enterprise Server API under /api/v3/
+// over TLS with a self-signed certificate, returning the server and a CA bundle
+// that trusts it
+func newEnterpriseServer(t *testing.T) (*httptest.Server, string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/repos/octo/app/pulls/7/files", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ghes-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, `[{"filename":"b.go","status":"added"}]`)
+			return
+		}
+		next := fmt.Sprintf("https://%s/api/v3/repos/octo/app/pulls/7/files?page=2&per_page=100", r.Host)
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next))
+		fmt.Fprint(w, `[{"filename":"a.go","status":"modified"}]`)
+	})
+	mux.HandleFunc("/api/v3/repos/octo/app/contents/README.md", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("ref") != "abc123" {
+			t.Errorf("Expected ref abc123, got %s", r.URL.Query().Get("ref"))
+		}
+		fmt.Fprint(w, `{"type":"file","encoding":"base64","content":"aGVsbG8K"}`)
+	})
+	server := httptest.NewTLSServer(mux)
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(bundle, cert, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return server, bundle
+}
+
+func TestNewGitHubClient_Enterprise(t *testing.T) {
+	server, bundle := newEnterpriseServer(t)
+	defer server.Close()
+
+	client, err := NewGitHubClient("ghes-token", ClientOptions{
+		BaseURL: server.URL,
+		CAFile:  bundle,
+	})
+	if err != nil {
+		t.Fatalf("NewGitHubClient() failed: %v", err)
+	}
+
+	ctx := context.Background()
+	files, err := client.GetPullRequestFiles(ctx, "octo", "app", 7)
+	if err != nil {
+		t.Fatalf("GetPullRequestFiles() failed: %v", err)
+	}
+	if len(files) != 2 || files[0].GetFilename() != "a.go" || files[1].GetFilename() != "b.go" {
+		t.Errorf("Expected a.go and b.go across two pages, got %v", files)
+	}
+
+	content, err := client.GetFileContent(ctx, "octo", "app", "README.md", "abc123")
+	if err != nil {
+		t.Fatalf("GetFileContent() failed: %v", err)
+	}
+	if content != "hello\n" {
+		t.Errorf("Expected decoded content, got %q", content)
+	}
+
+	if client.webURL != server.URL {
+		t.Errorf("Expected clone base %s, got %s", server.URL, client.webURL)
+	}
+}
+
+func TestNewGitHubClient_UntrustedCertificate(t *testing.T) {
+	server, _ := newEnterpriseServer(t)
+	defer server.Close()
+
+	client, err := NewGitHubClient("ghes-token", ClientOptions{BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("NewGitHubClient() failed: %v", err)
+	}
+	if _, err := client.GetFileContent(context.Background(), "octo", "app", "README.md", "abc123"); err == nil {
+		t.Error("Expected TLS error without the CA bundle")
+	}
+}
+
+func TestNewGitHubClient_InvalidCABundle(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "empty.pem")
+	os.WriteFile(bundle, []byte("not a certificate"), 0o644)
+
+	if _, err := NewGitHubClient("token", ClientOptions{CAFile: bundle}); err == nil {
+		t.Error("Expected error for a bundle without certificates")
+	}
+}
+
+func TestEnterpriseWebURL(t *testing.T) {
+	tests := map[string]string{
+		"https://github.example.com/api/v3/": "https://github.example.com",
+		"https://example.com/github/api/v3/": "https://example.com/github",
+		"http://localhost:8080/api/v3/":      "http://localhost:8080",
+	}
+	for apiURL, expected := range tests {
+		u, _ := url.Parse(apiURL)
+		if got := enterpriseWebURL(u); got != expected {
+			t.Errorf("enterpriseWebURL(%s): expected %s, got %s", apiURL, expected, got)
+		}
+	}
+}
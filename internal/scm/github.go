@@ -1,23 +1,129 @@
 package scm
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/carlr/codereviewtool/internal/workspace"
 	"github.com/google/go-github/v57/github"
 )
 
+// defaultWebURL is where repositories are cloned from when no Enterprise URL is set
+const defaultWebURL = "https://github.com"
+
 // GitHubClient wraps the GitHub API client
 type GitHubClient struct {
 	client *github.Client
+	token  string
+	webURL string // Base of clone URLs, e.g. https://github.example.com
+	caFile string
+
+	loginOnce sync.Once
+	login     string // Account the token belongs to; empty for GitHub App installations
+}
+
+// ClientOptions controls how the client talks to the GitHub API
+type ClientOptions struct {
+	// Cache stores responses for conditional requests; nil disables caching
+	Cache ResponseCache
+	// MaxRateLimitWait is the longest the client waits for a rate limit to reset
+	// before returning the error; zero never waits
+	MaxRateLimitWait time.Duration
+
+	// BaseURL and UploadURL point the client at GitHub Enterprise Server, e.g.
+	// https://github.example.com/api/v3/; empty uses github.com. UploadURL
+	// defaults to BaseURL
+	BaseURL   string
+	UploadURL string
+	// CAFile is a PEM bundle trusted in addition to the system roots, for servers
+	// with certificates from a private CA
+	CAFile string
 }
 
 // NewGitHubClient creates a new GitHub API client
-func NewGitHubClient(token string) *GitHubClient {
-	client := github.NewClient(nil).WithAuthToken(token)
+func NewGitHubClient(token string, options ClientOptions) (*GitHubClient, error) {
+	base := http.DefaultTransport
+	if options.CAFile != "" {
+		tlsTransport, err := transportWithCA(options.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		base = tlsTransport
+	}
+
+	fingerprint := tokenFingerprint(token)
+	var transport http.RoundTripper = newRateLimitTransport(base, fingerprint, options.MaxRateLimitWait)
+	if options.Cache != nil {
+		transport = &etagTransport{base: transport, cache: options.Cache, token: fingerprint}
+	}
+
+	client := github.NewClient(&http.Client{Transport: transport}).WithAuthToken(token)
+	webURL := defaultWebURL
+	if options.BaseURL != "" {
+		uploadURL := options.UploadURL
+		if uploadURL == "" {
+			uploadURL = options.BaseURL
+		}
+		var err error
+		client, err = client.WithEnterpriseURLs(options.BaseURL, uploadURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GitHub Enterprise URL: %w", err)
+		}
+		webURL = enterpriseWebURL(client.BaseURL)
+	}
+
 	return &GitHubClient{
 		client: client,
+		token:  token,
+		webURL: webURL,
+		caFile: options.CAFile,
+	}, nil
+}
+
+// transportWithCA returns an HTTP transport that also trusts the certificates in caFile
+func transportWithCA(caFile string) (*http.Transport, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
 	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", caFile)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return transport, nil
+}
+
+// enterpriseWebURL derives the web address of a GitHub Enterprise Server from its
+// API URL by dropping the /api/v3/ suffix
+func enterpriseWebURL(apiURL *url.URL) string {
+	web := *apiURL
+	web.Path = strings.TrimSuffix(strings.TrimSuffix(web.Path, "/"), "/api/v3")
+	return strings.TrimSuffix(web.String(), "/")
+}
+
+// Checkout clones a repository at the given commit into a temporary workspace.
+// The caller must Close the workspace when done
+func (g *GitHubClient) Checkout(ctx context.Context, owner, repo, sha string) (*workspace.Workspace, error) {
+	cloneURL := fmt.Sprintf("%s/%s/%s.git", g.webURL, owner, repo)
+	return workspace.Checkout(ctx, cloneURL, sha, workspace.Auth{Token: g.token, CAFile: g.caFile})
 }
 
 // GetPullRequestDiff retrieves the diff for a pull request
@@ -35,23 +141,192 @@ func (g *GitHubClient) GetPullRequestDiff(ctx context.Context, owner, repo strin
 	return diff, nil
 }
 
-// GetPullRequestFiles retrieves the list of files changed in a PR
+// GetPullRequestFiles retrieves the list of files changed in a PR, following
+// pagination. GitHub lists at most 3000 files
 func (g *GitHubClient) GetPullRequestFiles(ctx context.Context, owner, repo string, prNumber int) ([]*github.CommitFile, error) {
-	files, _, err := g.client.PullRequests.ListFiles(ctx, owner, repo, prNumber, nil)
+	var files []*github.CommitFile
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := g.client.PullRequests.ListFiles(ctx, owner, repo, prNumber, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list PR files: %w", err)
+		}
+		files = append(files, page...)
+
+		if resp.NextPage == 0 {
+			return files, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// GetFileContent retrieves the contents of a file at the given ref
+func (g *GitHubClient) GetFileContent(ctx context.Context, owner, repo, path, ref string) (string, error) {
+	file, _, _, err := g.client.Repositories.GetContents(ctx, owner, repo, path, &github.RepositoryContentGetOptions{Ref: ref})
+	if err != nil {
+		return "", fmt.Errorf("failed to get contents of %s: %w", path, err)
+	}
+	if file == nil {
+		return "", fmt.Errorf("%s is a directory", path)
+	}
+
+	content, err := file.GetContent()
+	if err != nil {
+		return "", fmt.Errorf("failed to decode contents of %s: %w", path, err)
+	}
+	return content, nil
+}
+
+// ListDirectory returns the paths of the files directly inside a directory at the given ref
+func (g *GitHubClient) ListDirectory(ctx context.Context, owner, repo, dir, ref string) ([]string, error) {
+	_, entries, _, err := g.client.Repositories.GetContents(ctx, owner, repo, dir, &github.RepositoryContentGetOptions{Ref: ref})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list directory %s: %w", dir, err)
+	}
+
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.GetType() == "file" {
+			paths = append(paths, entry.GetPath())
+		}
+	}
+	return paths, nil
+}
+
+// GetPullRequestHead returns the SHA of the current head commit of a pull request
+func (g *GitHubClient) GetPullRequestHead(ctx context.Context, owner, repo string, prNumber int) (string, error) {
+	pr, _, err := g.client.PullRequests.Get(ctx, owner, repo, prNumber)
+	if err != nil {
+		return "", fmt.Errorf("failed to get PR: %w", err)
+	}
+	return pr.GetHead().GetSHA(), nil
+}
+
+// IsAncestor reports whether commit ancestor is reachable from descendant
+func (g *GitHubClient) IsAncestor(ctx context.Context, owner, repo, ancestor, descendant string) (bool, error) {
+	comparison, _, err := g.client.Repositories.CompareCommits(ctx, owner, repo, ancestor, descendant, &github.ListOptions{PerPage: 1})
+	if err != nil {
+		return false, fmt.Errorf("failed to compare commits: %w", err)
+	}
+	status := comparison.GetStatus()
+	return status == "ahead" || status == "identical", nil
+}
+
+// GetPullRequest retrieves a pull request
+func (g *GitHubClient) GetPullRequest(ctx context.Context, owner, repo string, prNumber int) (*github.PullRequest, error) {
+	pr, _, err := g.client.PullRequests.Get(ctx, owner, repo, prNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PR: %w", err)
+	}
+	return pr, nil
+}
+
+// ListOpenPullRequests retrieves every open pull request of a repository
+func (g *GitHubClient) ListOpenPullRequests(ctx context.Context, owner, repo string) ([]*github.PullRequest, error) {
+	var prs []*github.PullRequest
+	opts := &github.PullRequestListOptions{State: "open", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := g.client.PullRequests.List(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list PRs: %w", err)
+		}
+		prs = append(prs, page...)
+
+		if resp.NextPage == 0 {
+			return prs, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// ListRepositories returns the names of the unarchived repositories owned by an
+// organization or, if owner isn't one, by a user
+func (g *GitHubClient) ListRepositories(ctx context.Context, owner string) ([]string, error) {
+	var names []string
+	add := func(repos []*github.Repository) {
+		for _, r := range repos {
+			if !r.GetArchived() {
+				names = append(names, r.GetName())
+			}
+		}
+	}
+
+	orgOpts := &github.RepositoryListByOrgOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		repos, resp, err := g.client.Repositories.ListByOrg(ctx, owner, orgOpts)
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list repositories: %w", err)
+		}
+		add(repos)
+
+		if resp.NextPage == 0 {
+			return names, nil
+		}
+		orgOpts.Page = resp.NextPage
+	}
+
+	userOpts := &github.RepositoryListByUserOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		repos, resp, err := g.client.Repositories.ListByUser(ctx, owner, userOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list repositories: %w", err)
+		}
+		add(repos)
+
+		if resp.NextPage == 0 {
+			return names, nil
+		}
+		userOpts.Page = resp.NextPage
+	}
+}
+
+// GetPermissionLevel returns a user's permission on a repository: "admin",
+// "write", "read" or "none"
+func (g *GitHubClient) GetPermissionLevel(ctx context.Context, owner, repo, user string) (string, error) {
+	level, _, err := g.client.Repositories.GetPermissionLevel(ctx, owner, repo, user)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list PR files: %w", err)
+		return "", fmt.Errorf("failed to get permission level: %w", err)
 	}
-	return files, nil
+	return level.GetPermission(), nil
 }
 
-// PostReviewComment posts a review comment on a specific line of a file
+// SetCommitStatus sets a commit status; state is "pending", "success", "failure" or "error"
+func (g *GitHubClient) SetCommitStatus(ctx context.Context, owner, repo, sha, statusContext, state, description string) error {
+	status := &github.RepoStatus{
+		State:       github.String(state),
+		Description: github.String(description),
+		Context:     github.String(statusContext),
+	}
+
+	_, _, err := g.client.Repositories.CreateStatus(ctx, owner, repo, sha, status)
+	if err != nil {
+		return fmt.Errorf("failed to set commit status: %w", err)
+	}
+	return nil
+}
+
+// PostReviewComment posts a review comment on a line or range of a file, or on
+// the file as a whole when EndLine is zero
 func (g *GitHubClient) PostReviewComment(ctx context.Context, owner, repo string, prNumber int, comment *ReviewComment) error {
 	githubComment := &github.PullRequestComment{
 		Body:     github.String(comment.Body),
 		Path:     github.String(comment.Filename),
-		Line:     github.Int(comment.Line),
 		CommitID: github.String(comment.CommitID),
 	}
+	switch {
+	case comment.EndLine <= 0:
+		githubComment.SubjectType = github.String("file")
+	case comment.IsMultiLine():
+		githubComment.StartLine = github.Int(comment.StartLine)
+		githubComment.StartSide = github.String(comment.side())
+		fallthrough
+	default:
+		githubComment.Line = github.Int(comment.EndLine)
+		githubComment.Side = github.String(comment.side())
+	}
 
 	_, _, err := g.client.PullRequests.CreateComment(ctx, owner, repo, prNumber, githubComment)
 	if err != nil {
@@ -73,20 +348,45 @@ func (g *GitHubClient) PostReviewSummary(ctx context.Context, owner, repo string
 	return nil
 }
 
+// CreatePullRequest opens a pull request merging head into base and returns its
+// number and URL
+func (g *GitHubClient) CreatePullRequest(ctx context.Context, owner, repo, title, head, base, body string) (int, string, error) {
+	pr, _, err := g.client.PullRequests.Create(ctx, owner, repo, &github.NewPullRequest{
+		Title: github.String(title),
+		Head:  github.String(head),
+		Base:  github.String(base),
+		Body:  github.String(body),
+	})
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create pull request: %w", err)
+	}
+	return pr.GetNumber(), pr.GetHTMLURL(), nil
+}
+
 // CreateReview creates a review with multiple comments
 func (g *GitHubClient) CreateReview(ctx context.Context, owner, repo string, prNumber int, review *Review) error {
 	comments := make([]*github.DraftReviewComment, 0, len(review.Comments))
 	for _, c := range review.Comments {
-		comments = append(comments, &github.DraftReviewComment{
+		draft := &github.DraftReviewComment{
 			Path: github.String(c.Filename),
-			Line: github.Int(c.Line),
+			Line: github.Int(c.EndLine),
+			Side: github.String(c.side()),
 			Body: github.String(c.Body),
-		})
+		}
+		if c.IsMultiLine() {
+			draft.StartLine = github.Int(c.StartLine)
+			draft.StartSide = github.String(c.side())
+		}
+		comments = append(comments, draft)
 	}
 
+	event := review.Event
+	if event == "" {
+		event = ReviewEventComment
+	}
 	githubReview := &github.PullRequestReviewRequest{
 		Body:     github.String(review.Summary),
-		Event:    github.String("COMMENT"),
+		Event:    github.String(event),
 		Comments: comments,
 		CommitID: github.String(review.CommitID),
 	}
@@ -98,17 +398,122 @@ func (g *GitHubClient) CreateReview(ctx context.Context, owner, repo string, prN
 	return nil
 }
 
+// DismissReviews dismisses the pull request's outstanding change requests that the
+// token's account submitted with marker in the body, and returns how many were
+// dismissed. Reviews by people are never dismissed, even if they quote the marker
+func (g *GitHubClient) DismissReviews(ctx context.Context, owner, repo string, prNumber int, marker, message string) (int, error) {
+	login := g.authenticatedLogin(ctx)
+	dismissed := 0
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		reviews, resp, err := g.client.PullRequests.ListReviews(ctx, owner, repo, prNumber, opts)
+		if err != nil {
+			return dismissed, fmt.Errorf("failed to list reviews: %w", err)
+		}
+
+		for _, r := range reviews {
+			if r.GetState() != "CHANGES_REQUESTED" || !strings.Contains(r.GetBody(), marker) || !ownReview(r.GetUser(), login) {
+				continue
+			}
+			request := &github.PullRequestReviewDismissalRequest{Message: github.String(message)}
+			if _, _, err := g.client.PullRequests.DismissReview(ctx, owner, repo, prNumber, r.GetID(), request); err != nil {
+				return dismissed, fmt.Errorf("failed to dismiss review %d: %w", r.GetID(), err)
+			}
+			dismissed++
+		}
+
+		if resp.NextPage == 0 {
+			return dismissed, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// authenticatedLogin returns the login of the token's account, or "" if it can't
+// be looked up, as for GitHub App installation tokens
+func (g *GitHubClient) authenticatedLogin(ctx context.Context) string {
+	g.loginOnce.Do(func() {
+		user, _, err := g.client.Users.Get(ctx, "")
+		if err == nil {
+			g.login = user.GetLogin()
+		}
+	})
+	return g.login
+}
+
+// ownReview reports whether a review's author is the token's account. Without a
+// login, any bot counts, as only apps post reviews as bots
+func ownReview(author *github.User, login string) bool {
+	if login == "" {
+		return author.GetType() == "Bot"
+	}
+	return strings.EqualFold(author.GetLogin(), login)
+}
+
+// UploadSARIF uploads a SARIF log to GitHub code scanning for the given commit and ref
+func (g *GitHubClient) UploadSARIF(ctx context.Context, owner, repo, commitSHA, ref string, sarif []byte) error {
+	// The API expects the log gzip-compressed and base64-encoded
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(sarif); err != nil {
+		return fmt.Errorf("failed to compress SARIF: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to compress SARIF: %w", err)
+	}
+
+	analysis := &github.SarifAnalysis{
+		CommitSHA: github.String(commitSHA),
+		Ref:       github.String(ref),
+		Sarif:     github.String(base64.StdEncoding.EncodeToString(compressed.Bytes())),
+	}
+
+	_, _, err := g.client.CodeScanning.UploadSarif(ctx, owner, repo, analysis)
+	if err != nil {
+		// The upload is processed asynchronously, so GitHub answers 202 Accepted
+		var accepted *github.AcceptedError
+		if errors.As(err, &accepted) {
+			return nil
+		}
+		return fmt.Errorf("failed to upload SARIF: %w", err)
+	}
+	return nil
+}
+
 // ReviewComment represents a single review comment
 type ReviewComment struct {
-	Filename string
-	Line     int
-	Body     string
-	CommitID string
+	Filename  string
+	StartLine int    // First line of a multi-line comment; zero for a single line
+	EndLine   int    // Line the comment is attached to, or the last line of a range; zero for the whole file
+	Side      string // "RIGHT" for the new version of the file, "LEFT" for the old; defaults to RIGHT
+	Body      string
+	CommitID  string
 }
 
+// IsMultiLine reports whether the comment spans more than one line
+func (c *ReviewComment) IsMultiLine() bool {
+	return c.StartLine > 0 && c.StartLine < c.EndLine
+}
+
+// side returns the side of the diff the comment applies to
+func (c *ReviewComment) side() string {
+	if c.Side == "LEFT" {
+		return "LEFT"
+	}
+	return "RIGHT"
+}
+
+// Review events decide whether a review approves, blocks or just comments on a pull request
+const (
+	ReviewEventComment        = "COMMENT"
+	ReviewEventRequestChanges = "REQUEST_CHANGES"
+	ReviewEventApprove        = "APPROVE"
+)
+
 // Review represents a complete review with multiple comments
 type Review struct {
 	Summary  string
 	Comments []ReviewComment
 	CommitID string
+	Event    string // One of the ReviewEvent constants; empty means ReviewEventComment
 }
@@ -0,0 +1,43 @@
+package scm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDismissReviews_OnlyOwnReviews(t *testing.T) {
+	var dismissed []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/user", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"login":"review-bot"}`)
+	})
+	mux.HandleFunc("/api/v3/repos/o/r/pulls/1/reviews", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[
+			{"id":1,"state":"CHANGES_REQUESTED","body":"Fix it <!-- marker -->","user":{"login":"review-bot"}},
+			{"id":2,"state":"CHANGES_REQUESTED","body":"> Fix it <!-- marker -->\nAgreed","user":{"login":"maintainer"}},
+			{"id":3,"state":"COMMENTED","body":"<!-- marker -->","user":{"login":"review-bot"}}
+		]`)
+	})
+	mux.HandleFunc("/api/v3/repos/o/r/pulls/1/reviews/", func(w http.ResponseWriter, r *http.Request) {
+		dismissed = append(dismissed, r.URL.Path)
+		fmt.Fprint(w, `{}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := NewGitHubClient("token", ClientOptions{BaseURL: server.URL + "/api/v3/"})
+	if err != nil {
+		t.Fatalf("NewGitHubClient() failed: %v", err)
+	}
+
+	n, err := client.DismissReviews(context.Background(), "o", "r", 1, "<!-- marker -->", "Resolved")
+	if err != nil {
+		t.Fatalf("DismissReviews() failed: %v", err)
+	}
+	if n != 1 || len(dismissed) != 1 || dismissed[0] != "/api/v3/repos/o/r/pulls/1/reviews/1/dismissals" {
+		t.Errorf("Expected only the bot's change request to be dismissed, got %v", dismissed)
+	}
+}
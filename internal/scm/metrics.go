@@ -0,0 +1,26 @@
+package scm
+
+import "expvar"
+
+// Metrics are published with expvar, served at /debug/vars when a metrics address
+// is configured
+var (
+	rateLimitRemaining = expvar.NewMap("github_rate_limit_remaining")
+	rateLimitLimit     = expvar.NewMap("github_rate_limit_limit")
+	rateLimitWaits     = expvar.NewInt("github_rate_limit_waits")
+	cacheHits          = expvar.NewInt("github_cache_hits")
+	cacheMisses        = expvar.NewInt("github_cache_misses")
+)
+
+// recordRateLimit publishes the remaining quota of a token's rate limit resource
+func recordRateLimit(token, resource string, limit, remaining int) {
+	key := token + "/" + resource
+
+	value := new(expvar.Int)
+	value.Set(int64(remaining))
+	rateLimitRemaining.Set(key, value)
+
+	total := new(expvar.Int)
+	total.Set(int64(limit))
+	rateLimitLimit.Set(key, total)
+}
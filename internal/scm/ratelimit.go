@@ -0,0 +1,203 @@
+package scm
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxRateLimitRetries is how often a request is retried after being rate limited
+	maxRateLimitRetries = 3
+	// defaultSecondaryWait is used when a secondary rate limit response has no Retry-After
+	defaultSecondaryWait = time.Minute
+)
+
+// rateLimit is the quota of one GitHub rate limit resource, such as "core" or "search"
+type rateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// rateLimitTransport tracks GitHub's rate limit headers for one token and waits
+// for the limit to reset instead of failing requests once the quota is exhausted
+// or a secondary rate limit is hit
+type rateLimitTransport struct {
+	base    http.RoundTripper
+	token   string // Fingerprint of the token, used to label metrics
+	maxWait time.Duration
+	sleep   func(ctx context.Context, d time.Duration) error
+
+	mu     sync.Mutex
+	limits map[string]rateLimit
+}
+
+func newRateLimitTransport(base http.RoundTripper, token string, maxWait time.Duration) *rateLimitTransport {
+	return &rateLimitTransport{
+		base:    base,
+		token:   token,
+		maxWait: maxWait,
+		sleep:   sleepContext,
+		limits:  make(map[string]rateLimit),
+	}
+}
+
+// RoundTrip sends the request, waiting out exhausted or secondary rate limits
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resource := rateLimitResource(req.URL.Path)
+	if wait := t.untilReset(resource); wait > 0 && wait <= t.maxWait {
+		log.Printf("GitHub %s rate limit exhausted, waiting %s for reset", resource, wait.Round(time.Second))
+		rateLimitWaits.Add(1)
+		if err := t.sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		t.update(resp.Header)
+
+		wait, limited := retryAfter(resp)
+		if !limited {
+			return t.holdLastQuota(req, resp)
+		}
+		if attempt >= maxRateLimitRetries || wait > t.maxWait || !rewindable(req) {
+			return resp, nil
+		}
+
+		log.Printf("GitHub rate limited %s %s, retrying in %s", req.Method, req.URL.Path, wait.Round(time.Second))
+		rateLimitWaits.Add(1)
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if err := t.sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// holdLastQuota returns a response, first waiting for the reset when it used up
+// the last of the quota and the reset is within maxWait. go-github refuses to send
+// requests while the last response it saw had no quota left, so waiting here
+// rather than before the next request keeps that from failing requests this
+// transport would have waited for
+func (t *rateLimitTransport) holdLastQuota(req *http.Request, resp *http.Response) (*http.Response, error) {
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return resp, nil
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return resp, nil
+	}
+	wait := time.Until(time.Unix(reset, 0)) + time.Second
+	if wait <= 0 || wait > t.maxWait {
+		return resp, nil
+	}
+
+	log.Printf("GitHub rate limit used up by %s %s, waiting %s for reset", req.Method, req.URL.Path, wait.Round(time.Second))
+	rateLimitWaits.Add(1)
+	if err := t.sleep(req.Context(), wait); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// update records the rate limit reported in response headers
+func (t *rateLimitTransport) update(header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	limit, _ := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	reset, _ := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	resource := header.Get("X-RateLimit-Resource")
+	if resource == "" {
+		resource = "core"
+	}
+
+	t.mu.Lock()
+	t.limits[resource] = rateLimit{Limit: limit, Remaining: remaining, Reset: time.Unix(reset, 0)}
+	t.mu.Unlock()
+
+	recordRateLimit(t.token, resource, limit, remaining)
+}
+
+// untilReset returns how long to wait before the resource has quota again, or zero
+func (t *rateLimitTransport) untilReset(resource string) time.Duration {
+	t.mu.Lock()
+	limit, ok := t.limits[resource]
+	t.mu.Unlock()
+	if !ok || limit.Remaining > 0 {
+		return 0
+	}
+	return time.Until(limit.Reset)
+}
+
+// retryAfter reports whether a response was rejected by a rate limit and how long
+// to wait before retrying
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	// Secondary rate limits say how long to back off
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		// Leave a second for clock skew between us and GitHub
+		return time.Until(time.Unix(reset, 0)) + time.Second, true
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return defaultSecondaryWait, true
+	}
+	return 0, false
+}
+
+// rateLimitResource guesses which rate limit a request counts against
+func rateLimitResource(path string) string {
+	switch {
+	case strings.HasPrefix(path, "/search/"), strings.Contains(path, "/api/v3/search/"):
+		return "search"
+	case strings.HasSuffix(path, "/graphql"):
+		return "graphql"
+	}
+	return "core"
+}
+
+// rewindable reports whether a request can be sent again
+func rewindable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,183 @@
+package scm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimitTransport_RetriesSecondaryLimit(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	transport := newRateLimitTransport(http.DefaultTransport, "test", time.Minute)
+	var slept time.Duration
+	transport.sleep = func(ctx context.Context, d time.Duration) error {
+		slept += d
+		return nil
+	}
+
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL + "/repos/o/r")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || calls != 2 {
+		t.Errorf("Expected success after 2 calls, got status %d after %d calls", resp.StatusCode, calls)
+	}
+	if slept != 30*time.Second {
+		t.Errorf("Expected to wait 30s, waited %s", slept)
+	}
+	if transport.limits["core"].Remaining != 4999 {
+		t.Errorf("Expected 4999 remaining, got %d", transport.limits["core"].Remaining)
+	}
+}
+
+func TestRateLimitTransport_GivesUpBeyondMaxWait(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	transport := newRateLimitTransport(http.DefaultTransport, "test", time.Minute)
+	transport.sleep = func(ctx context.Context, d time.Duration) error {
+		t.Errorf("Unexpected wait of %s", d)
+		return nil
+	}
+
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL + "/repos/o/r")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected the 403 to be returned, got %d", resp.StatusCode)
+	}
+}
+
+func TestRateLimitTransport_HoldsLastQuota(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(30*time.Second).Unix(), 10))
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	transport := newRateLimitTransport(http.DefaultTransport, "test", time.Minute)
+	var slept time.Duration
+	transport.sleep = func(ctx context.Context, d time.Duration) error {
+		slept += d
+		return nil
+	}
+
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL + "/repos/o/r")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if slept < 25*time.Second || slept > 35*time.Second {
+		t.Errorf("Expected to wait about 30s for the reset, waited %s", slept)
+	}
+	if resp.Header.Get("X-From-Cache") != "" {
+		t.Error("Expected a network response not to be marked as cached")
+	}
+}
+
+func TestETagTransport(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, "payload")
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &etagTransport{base: http.DefaultTransport, cache: NewMemoryCache(1 << 20), token: "test"}}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL + "/repos/o/r")
+		if err != nil {
+			t.Fatalf("Request %d failed: %v", i, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != "payload" {
+			t.Errorf("Request %d: expected cached payload, got %d %q", i, resp.StatusCode, body)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 calls to the server, got %d", calls)
+	}
+}
+
+func TestETagTransport_SkipsPinnedRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, "payload")
+	}))
+	defer server.Close()
+
+	cache := NewMemoryCache(1 << 20)
+	client := &http.Client{Transport: &etagTransport{base: http.DefaultTransport, cache: cache, token: "test"}}
+	sha := strings.Repeat("a", 40)
+	for _, ref := range []string{sha, "main"} {
+		resp, err := client.Get(server.URL + "/repos/o/r/contents/f.go?ref=" + ref)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	if cache.(*memoryCache).order.Len() != 1 {
+		t.Errorf("Expected only the branch request to be cached, got %d entries", cache.(*memoryCache).order.Len())
+	}
+}
+
+func TestMemoryCache_Evicts(t *testing.T) {
+	ctx := context.Background()
+	body := []byte(strings.Repeat("x", 10))
+	// Each entry is 12 bytes: its key, ETag and body
+	cache := NewMemoryCache(30)
+	cache.Set(ctx, "a", &CachedResponse{ETag: "a", Body: body})
+	cache.Set(ctx, "b", &CachedResponse{ETag: "b", Body: body})
+	cache.Get(ctx, "a")
+	cache.Set(ctx, "c", &CachedResponse{ETag: "c", Body: body})
+
+	if resp, _ := cache.Get(ctx, "b"); resp != nil {
+		t.Error("Expected least recently used entry to be evicted")
+	}
+	if resp, _ := cache.Get(ctx, "a"); resp == nil {
+		t.Error("Expected recently used entry to be kept")
+	}
+
+	cache.Set(ctx, "d", &CachedResponse{ETag: "d", Body: []byte(strings.Repeat("x", 100))})
+	if resp, _ := cache.Get(ctx, "d"); resp != nil {
+		t.Error("Expected an entry larger than the cache not to be kept")
+	}
+	if size := cache.(*memoryCache).size; size != 24 {
+		t.Errorf("Expected 24 bytes cached, got %d", size)
+	}
+}
@@ -1,6 +1,7 @@
 package webhook
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -8,17 +9,23 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
+
+	"github.com/carlr/codereviewtool/internal/dedup"
+	"github.com/carlr/codereviewtool/internal/queue"
 )
 
 // Handler handles GitHub webhook events
 type Handler struct {
-	secret string
-	queue  QueuePublisher
+	secret     string
+	queue      QueuePublisher
+	store      dedup.Store // Optional; records deliveries and pull request heads
+	priorities PriorityRules
 }
 
 // QueuePublisher defines the interface for publishing events to a queue
 type QueuePublisher interface {
-	Publish(event interface{}) error
+	PublishWith(event interface{}, opts queue.PublishOptions) error
 }
 
 // NewHandler creates a new webhook handler
@@ -29,6 +36,17 @@ func NewHandler(secret string, queue QueuePublisher) *Handler {
 	}
 }
 
+// SetStore enables ignoring redelivered webhooks and records each pull request's
+// newest head so workers can skip jobs for superseded commits
+func (h *Handler) SetStore(store dedup.Store) {
+	h.store = store
+}
+
+// SetPriorities sets the rules deciding which events are handled first
+func (h *Handler) SetPriorities(rules PriorityRules) {
+	h.priorities = rules
+}
+
 // HandleGitHub processes GitHub webhook events
 func (h *Handler) HandleGitHub(w http.ResponseWriter, r *http.Request) {
 	// Read the request body
@@ -46,10 +64,23 @@ func (h *Handler) HandleGitHub(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// GitHub retries deliveries it considers failed, reusing the delivery ID
+	if h.isDuplicate(r.Context(), r.Header.Get("X-GitHub-Delivery")) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "Duplicate delivery ignored")
+		return
+	}
+
 	// Get event type
 	eventType := r.Header.Get("X-GitHub-Event")
 
-	// We only care about pull request events
+	// Comments on pull requests can carry commands for the bot
+	if eventType == "issue_comment" {
+		h.handleIssueComment(w, r, body)
+		return
+	}
+
+	// Otherwise we only care about pull request events
 	if eventType != "pull_request" {
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprintf(w, "Event type %s ignored", eventType)
@@ -66,9 +97,13 @@ func (h *Handler) HandleGitHub(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Only process opened, synchronize, and reopened events
+	// Only process opened, synchronize, reopened and ready_for_review events, plus
+	// labels which can trigger a review or override the merge policy and closed
+	// events which cancel running reviews. Draft and opt-out filtering happens in
+	// the worker, which owns the label configuration
 	action := event.Action
-	if action != "opened" && action != "synchronize" && action != "reopened" {
+	if action != "opened" && action != "synchronize" && action != "reopened" &&
+		action != "ready_for_review" && action != "labeled" && action != "closed" {
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprintf(w, "PR action %s ignored", action)
 		return
@@ -79,7 +114,7 @@ func (h *Handler) HandleGitHub(w http.ResponseWriter, r *http.Request) {
 		event.Number, action, event.Repository.Owner.Login, event.Repository.Name)
 
 	// Publish to queue for processing
-	if err := h.queue.Publish(event); err != nil {
+	if err := h.publish(r, event); err != nil {
 		fmt.Printf("[ERROR] Failed to queue event: %v\n", err)
 		http.Error(w, "Failed to queue event", http.StatusInternalServerError)
 		return
@@ -90,6 +125,91 @@ func (h *Handler) HandleGitHub(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Event queued for processing")
 }
 
+// handleIssueComment forwards slash commands posted on pull requests to the queue
+func (h *Handler) handleIssueComment(w http.ResponseWriter, r *http.Request, body []byte) {
+	var comment GitHubIssueCommentEvent
+	if err := json.Unmarshal(body, &comment); err != nil {
+		fmt.Printf("Failed to parse GitHub event: %v\n", err)
+		http.Error(w, "Failed to parse event", http.StatusBadRequest)
+		return
+	}
+
+	// Issue comments fire for issues too; only PR comments that look like commands matter
+	if comment.Action != "created" || comment.Issue.PullRequest == nil || !strings.HasPrefix(strings.TrimSpace(comment.Comment.Body), "/") {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "Comment ignored")
+		return
+	}
+
+	// Normalize into a pull request event so the worker has a single message format
+	event := GitHubPullRequestEvent{
+		Action:     ActionCommand,
+		Number:     comment.Issue.Number,
+		Repository: comment.Repository,
+		Sender:     comment.Sender,
+		Comment:    &comment.Comment,
+	}
+	event.PullRequest.Number = comment.Issue.Number
+
+	fmt.Printf("[INFO] Processing command on PR #%d from %s/%s by %s\n",
+		event.Number, event.Repository.Owner.Login, event.Repository.Name, event.Sender.Login)
+
+	if err := h.publish(r, event); err != nil {
+		fmt.Printf("[ERROR] Failed to queue event: %v\n", err)
+		http.Error(w, "Failed to queue event", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Command queued for processing")
+}
+
+// isDuplicate claims a delivery and reports whether it was claimed already. Store
+// failures are logged and the delivery is treated as new, since workers skip
+// duplicate reviews too
+func (h *Handler) isDuplicate(ctx context.Context, deliveryID string) bool {
+	if h.store == nil || deliveryID == "" {
+		return false
+	}
+	claimed, err := h.store.ClaimDelivery(ctx, deliveryID)
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to claim delivery %s: %v\n", deliveryID, err)
+		return false
+	}
+	if !claimed {
+		fmt.Printf("[INFO] Ignoring duplicate delivery %s\n", deliveryID)
+	}
+	return !claimed
+}
+
+// publish queues an event, recording the pull request's head beforehand so it is
+// visible to workers. If queueing fails the delivery's claim is dropped so
+// GitHub's retry is not ignored
+func (h *Handler) publish(r *http.Request, event GitHubPullRequestEvent) (err error) {
+	ctx := r.Context()
+	if h.store != nil && event.PullRequest.Head.Sha != "" {
+		key := dedup.Key(event.Repository.Owner.Login, event.Repository.Name, event.PullRequest.Number)
+		if err := h.store.SetHead(ctx, key, event.PullRequest.Head.Sha); err != nil {
+			fmt.Printf("[ERROR] Failed to record head of PR #%d: %v\n", event.PullRequest.Number, err)
+		}
+	}
+
+	// The delivery ID ties the worker's logs to GitHub's delivery log
+	deliveryID := r.Header.Get("X-GitHub-Delivery")
+	defer func() {
+		if err != nil && h.store != nil && deliveryID != "" {
+			if forgetErr := h.store.ForgetDelivery(ctx, deliveryID); forgetErr != nil {
+				fmt.Printf("[ERROR] Failed to forget delivery %s: %v\n", deliveryID, forgetErr)
+			}
+		}
+	}()
+	j, err := NewJob(&event, event.Action, deliveryID)
+	if err != nil {
+		return err
+	}
+	return h.queue.PublishWith(j, h.priorities.Options(&event))
+}
+
 // verifySignature verifies the HMAC signature from GitHub
 func (h *Handler) verifySignature(body []byte, signature string) bool {
 	if signature == "" {
@@ -111,6 +231,14 @@ func (h *Handler) verifySignature(body []byte, signature string) bool {
 	return hmac.Equal([]byte(expectedHash), []byte(actualHash))
 }
 
+// ActionCommand is the action of a GitHubPullRequestEvent that carries a slash
+// command from a PR comment rather than a change to the PR itself
+const ActionCommand = "command"
+
+// ActionRequested is the action of a GitHubPullRequestEvent queued on demand, e.g.
+// by reviewctl, which is reviewed even if it is a draft or was already reviewed
+const ActionRequested = "requested"
+
 // GitHubPullRequestEvent represents a GitHub pull request webhook event
 type GitHubPullRequestEvent struct {
 	Action      string `json:"action"`
@@ -120,12 +248,19 @@ type GitHubPullRequestEvent struct {
 		Number int     `json:"number"`
 		Title  string  `json:"title"`
 		Body   *string `json:"body"` // Pointer to handle null values
+		Draft  bool    `json:"draft"`
 		User   struct {
 			Login string `json:"login"`
 		} `json:"user"`
-		Head struct {
-			Sha string `json:"sha"`
-			Ref string `json:"ref"`
+		Labels    []Label `json:"labels"`
+		Additions int     `json:"additions"`
+		Deletions int     `json:"deletions"`
+		Head      struct {
+			Sha  string `json:"sha"`
+			Ref  string `json:"ref"`
+			Repo struct {
+				FullName string `json:"full_name"`
+			} `json:"repo"`
 		} `json:"head"`
 		Base struct {
 			Sha  string `json:"sha"`
@@ -139,11 +274,48 @@ type GitHubPullRequestEvent struct {
 			} `json:"repo"`
 		} `json:"base"`
 	} `json:"pull_request"`
-	Repository struct {
-		Name     string `json:"name"`
-		FullName string `json:"full_name"`
-		Owner    struct {
-			Login string `json:"login"`
-		} `json:"owner"`
-	} `json:"repository"`
+	Repository Repository `json:"repository"`
+	Sender     User       `json:"sender"`
+	Label      *Label     `json:"label,omitempty"`   // Set for labeled events
+	Comment    *Comment   `json:"comment,omitempty"` // Set for ActionCommand events
+}
+
+// GitHubIssueCommentEvent represents a GitHub issue_comment webhook event
+type GitHubIssueCommentEvent struct {
+	Action string `json:"action"`
+	Issue  struct {
+		Number      int `json:"number"`
+		PullRequest *struct {
+			URL string `json:"url"`
+		} `json:"pull_request"` // Only present when the issue is a pull request
+	} `json:"issue"`
+	Comment    Comment    `json:"comment"`
+	Repository Repository `json:"repository"`
+	Sender     User       `json:"sender"`
+}
+
+// Repository identifies the repository an event belongs to
+type Repository struct {
+	Name     string `json:"name"`
+	FullName string `json:"full_name"`
+	Owner    struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+}
+
+// User is a GitHub account referenced by an event
+type User struct {
+	Login string `json:"login"`
+}
+
+// Label is a label applied to a pull request
+type Label struct {
+	Name string `json:"name"`
+}
+
+// Comment is a comment posted on a pull request
+type Comment struct {
+	Body              string `json:"body"`
+	AuthorAssociation string `json:"author_association"`
+	User              User   `json:"user"`
 }
@@ -2,21 +2,33 @@ package webhook
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/carlr/codereviewtool/internal/dedup"
+	"github.com/carlr/codereviewtool/internal/job"
+	"github.com/carlr/codereviewtool/internal/queue"
 )
 
 type mockQueue struct {
 	published []interface{}
+	options   []queue.PublishOptions
+	err       error
 }
 
-func (m *mockQueue) Publish(event interface{}) error {
+func (m *mockQueue) PublishWith(event interface{}, opts queue.PublishOptions) error {
+	if m.err != nil {
+		return m.err
+	}
 	m.published = append(m.published, event)
+	m.options = append(m.options, opts)
 	return nil
 }
 
@@ -114,7 +126,7 @@ func TestHandleGitHub_IgnoredAction(t *testing.T) {
 	queue := &mockQueue{}
 	handler := NewHandler(secret, queue)
 
-	event := GitHubPullRequestEvent{Action: "closed"}
+	event := GitHubPullRequestEvent{Action: "edited"}
 	body, _ := json.Marshal(event)
 
 	mac := hmac.New(sha256.New, []byte(secret))
@@ -136,3 +148,198 @@ func TestHandleGitHub_IgnoredAction(t *testing.T) {
 		t.Errorf("Expected 0 events published for ignored action, got %d", len(queue.published))
 	}
 }
+
+func TestHandleGitHub_ReadyForReview(t *testing.T) {
+	secret := "test-secret"
+	queue := &mockQueue{}
+	handler := NewHandler(secret, queue)
+
+	body := []byte(`{"action":"ready_for_review","number":3,"pull_request":{"number":3,"draft":false}}`)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	req := httptest.NewRequest("POST", "/webhook/github", bytes.NewReader(body))
+	req.Header.Set("X-GitHub-Event", "pull_request")
+	req.Header.Set("X-Hub-Signature-256", signature)
+
+	w := httptest.NewRecorder()
+	handler.HandleGitHub(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	if len(queue.published) != 1 {
+		t.Fatalf("Expected 1 event published, got %d", len(queue.published))
+	}
+	j, ok := queue.published[0].(*job.Job)
+	if !ok || j.Type != job.TypeReview || j.Trigger != "ready_for_review" {
+		t.Errorf("Expected ready_for_review review job, got %#v", queue.published[0])
+	}
+}
+
+func TestHandleGitHub_DuplicateDelivery(t *testing.T) {
+	secret := "test-secret"
+	queue := &mockQueue{}
+	store := dedup.NewMemoryStore()
+	handler := NewHandler(secret, queue)
+	handler.SetStore(store)
+
+	event := GitHubPullRequestEvent{Action: "synchronize", Number: 4}
+	event.PullRequest.Number = 4
+	event.PullRequest.Head.Sha = "abc123"
+	event.Repository.Name = "repo"
+	event.Repository.Owner.Login = "octo"
+	body, _ := json.Marshal(event)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/webhook/github", bytes.NewReader(body))
+		req.Header.Set("X-GitHub-Event", "pull_request")
+		req.Header.Set("X-GitHub-Delivery", "delivery-1")
+		req.Header.Set("X-Hub-Signature-256", signature)
+
+		w := httptest.NewRecorder()
+		handler.HandleGitHub(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", w.Code)
+		}
+	}
+
+	if len(queue.published) != 1 {
+		t.Fatalf("Expected redelivery to be ignored, got %d events published", len(queue.published))
+	}
+	if j := queue.published[0].(*job.Job); j.TraceID != "delivery-1" || j.HeadSHA != "abc123" {
+		t.Errorf("Expected job traced by its delivery at abc123, got %+v", j)
+	}
+	if head, _ := store.Head(context.Background(), dedup.Key("octo", "repo", 4)); head != "abc123" {
+		t.Errorf("Expected head abc123 to be recorded, got %q", head)
+	}
+}
+
+func TestHandleGitHub_RetryAfterFailedPublish(t *testing.T) {
+	secret := "test-secret"
+	queue := &mockQueue{err: errors.New("broker unavailable")}
+	handler := NewHandler(secret, queue)
+	handler.SetStore(dedup.NewMemoryStore())
+
+	event := GitHubPullRequestEvent{Action: "opened", Number: 5}
+	event.PullRequest.Number = 5
+	body, _ := json.Marshal(event)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	deliver := func() int {
+		req := httptest.NewRequest("POST", "/webhook/github", bytes.NewReader(body))
+		req.Header.Set("X-GitHub-Event", "pull_request")
+		req.Header.Set("X-GitHub-Delivery", "delivery-2")
+		req.Header.Set("X-Hub-Signature-256", signature)
+		w := httptest.NewRecorder()
+		handler.HandleGitHub(w, req)
+		return w.Code
+	}
+
+	if code := deliver(); code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", code)
+	}
+
+	// GitHub's retry of the failed delivery must not be ignored as a duplicate
+	queue.err = nil
+	if code := deliver(); code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", code)
+	}
+	if len(queue.published) != 1 {
+		t.Errorf("Expected retried delivery to be queued, got %d events published", len(queue.published))
+	}
+}
+
+func TestHandleGitHub_IssueCommentCommand(t *testing.T) {
+	secret := "test-secret"
+	queue := &mockQueue{}
+	handler := NewHandler(secret, queue)
+
+	payload := map[string]interface{}{
+		"action": "created",
+		"issue": map[string]interface{}{
+			"number":       7,
+			"pull_request": map[string]string{"url": "https://api.github.com/repos/o/r/pulls/7"},
+		},
+		"comment":    map[string]interface{}{"body": "/ai-review override", "user": map[string]string{"login": "maintainer"}},
+		"repository": map[string]interface{}{"name": "r", "owner": map[string]string{"login": "o"}},
+		"sender":     map[string]string{"login": "maintainer"},
+	}
+	body, _ := json.Marshal(payload)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	req := httptest.NewRequest("POST", "/webhook/github", bytes.NewReader(body))
+	req.Header.Set("X-GitHub-Event", "issue_comment")
+	req.Header.Set("X-Hub-Signature-256", signature)
+
+	w := httptest.NewRecorder()
+	handler.HandleGitHub(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	if len(queue.published) != 1 {
+		t.Fatalf("Expected 1 event published, got %d", len(queue.published))
+	}
+
+	j := queue.published[0].(*job.Job)
+	if j.Type != job.TypeCommand || j.Owner != "o" || j.Repo != "r" || j.PR != 7 {
+		t.Errorf("Unexpected command job: %+v", j)
+	}
+	event, err := EventFromJob(j)
+	if err != nil {
+		t.Fatalf("Failed to decode command event: %v", err)
+	}
+	if event.Action != ActionCommand || event.PullRequest.Number != 7 || event.Sender.Login != "maintainer" {
+		t.Errorf("Unexpected command event: %+v", event)
+	}
+	if event.Comment == nil || event.Comment.Body != "/ai-review override" {
+		t.Error("Expected the command body to be forwarded")
+	}
+}
+
+func TestHandleGitHub_IssueCommentIgnored(t *testing.T) {
+	secret := "test-secret"
+	queue := &mockQueue{}
+	handler := NewHandler(secret, queue)
+
+	// A plain comment on an issue, not a pull request
+	payload := map[string]interface{}{
+		"action":  "created",
+		"issue":   map[string]interface{}{"number": 3},
+		"comment": map[string]interface{}{"body": "/ai-review override"},
+	}
+	body, _ := json.Marshal(payload)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	req := httptest.NewRequest("POST", "/webhook/github", bytes.NewReader(body))
+	req.Header.Set("X-GitHub-Event", "issue_comment")
+	req.Header.Set("X-Hub-Signature-256", signature)
+
+	w := httptest.NewRecorder()
+	handler.HandleGitHub(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	if len(queue.published) != 0 {
+		t.Errorf("Expected 0 events published for an issue comment, got %d", len(queue.published))
+	}
+}
@@ -0,0 +1,56 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/carlr/codereviewtool/internal/job"
+)
+
+// NewJob wraps a pull request event in a GitHub job. trigger records why it was
+// queued and traceID, e.g. the webhook delivery ID, may be empty
+func NewJob(event *GitHubPullRequestEvent, trigger, traceID string) (*job.Job, error) {
+	j, err := job.New(jobType(event), job.SCMGitHub, trigger, traceID, event)
+	if err != nil {
+		return nil, err
+	}
+	j.Owner = event.Repository.Owner.Login
+	j.Repo = event.Repository.Name
+	j.PR = event.PullRequest.Number
+	j.HeadSHA = event.PullRequest.Head.Sha
+	return j, nil
+}
+
+// EventFromJob returns the pull request event of a GitHub job. Version 0 jobs,
+// which are raw events, get the envelope fields a current job would carry
+func EventFromJob(j *job.Job) (*GitHubPullRequestEvent, error) {
+	var event GitHubPullRequestEvent
+	if err := json.Unmarshal(j.Payload, &event); err != nil {
+		return nil, fmt.Errorf("failed to decode pull request event: %w", err)
+	}
+
+	if j.Version == 0 {
+		j.Type = jobType(&event)
+		j.Owner = event.Repository.Owner.Login
+		j.Repo = event.Repository.Name
+		j.PR = event.PullRequest.Number
+		j.HeadSHA = event.PullRequest.Head.Sha
+		j.Trigger = event.Action
+	}
+	return &event, nil
+}
+
+// jobType returns the type of job an event asks for. Whether a label requests a
+// review is left to the worker, which owns the label configuration
+func jobType(event *GitHubPullRequestEvent) string {
+	switch event.Action {
+	case ActionCommand:
+		return job.TypeCommand
+	case "labeled":
+		return job.TypeLabel
+	case "closed":
+		return job.TypeCancel
+	default:
+		return job.TypeReview
+	}
+}
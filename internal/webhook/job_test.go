@@ -0,0 +1,63 @@
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/carlr/codereviewtool/internal/job"
+)
+
+func TestNewJob_Types(t *testing.T) {
+	tests := map[string]string{
+		"opened":        job.TypeReview,
+		ActionRequested: job.TypeReview,
+		ActionCommand:   job.TypeCommand,
+		"labeled":       job.TypeLabel,
+		"closed":        job.TypeCancel,
+	}
+	for action, want := range tests {
+		event := GitHubPullRequestEvent{Action: action}
+		j, err := NewJob(&event, action, "")
+		if err != nil {
+			t.Fatalf("Failed to create job: %v", err)
+		}
+		if j.Type != want {
+			t.Errorf("Expected %s to be a %s job, got %s", action, want, j.Type)
+		}
+	}
+}
+
+func TestEventFromJob(t *testing.T) {
+	event := GitHubPullRequestEvent{Action: "synchronize", Number: 5}
+	event.PullRequest.Number = 5
+	event.PullRequest.Head.Sha = "abc123"
+	event.Repository.Name = "repo"
+	event.Repository.Owner.Login = "octo"
+
+	j, err := NewJob(&event, "backfill", "trace-1")
+	if err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+	body, _ := json.Marshal(j)
+	decoded, _ := job.Decode(body)
+	got, err := EventFromJob(decoded)
+	if err != nil {
+		t.Fatalf("Failed to decode event: %v", err)
+	}
+	if got.Action != "synchronize" || got.PullRequest.Head.Sha != "abc123" {
+		t.Errorf("Unexpected event: %+v", got)
+	}
+	if decoded.Trigger != "backfill" || decoded.TraceID != "trace-1" || decoded.Owner != "octo" {
+		t.Errorf("Unexpected job: %+v", decoded)
+	}
+
+	// Raw events queued by older listeners get their envelope filled in
+	legacy, _ := json.Marshal(event)
+	decoded, _ = job.Decode(legacy)
+	if _, err := EventFromJob(decoded); err != nil {
+		t.Fatalf("Failed to decode legacy event: %v", err)
+	}
+	if decoded.Type != job.TypeReview || decoded.Repo != "repo" || decoded.PR != 5 || decoded.Trigger != "synchronize" {
+		t.Errorf("Expected legacy envelope to be filled in, got %+v", decoded)
+	}
+}
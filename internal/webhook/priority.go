@@ -0,0 +1,62 @@
+package webhook
+
+import (
+	"path"
+
+	"github.com/carlr/codereviewtool/internal/queue"
+)
+
+// PriorityRules decide how events are queued. Commands, labels and closed pull
+// requests take the command route so they never wait behind reviews, while
+// reviews people asked for and pull requests matching a rule are reviewed before
+// other pushes
+type PriorityRules struct {
+	Branches     []string // Base branch globs, e.g. release/*
+	Labels       []string // e.g. urgent
+	SmallDiff    int      // Changed lines at or below which a PR goes first; 0 disables
+	TriggerLabel string   // Label that requests a review rather than a command
+}
+
+// Options returns the route and priority of an event
+func (r PriorityRules) Options(event *GitHubPullRequestEvent) queue.PublishOptions {
+	command := queue.PublishOptions{Route: queue.RouteCommand, Priority: queue.PriorityHigh}
+	requested := queue.PublishOptions{Route: queue.RouteReview, Priority: queue.PriorityHigh}
+
+	switch event.Action {
+	case ActionCommand, "closed":
+		return command
+	case "labeled":
+		if r.TriggerLabel == "" || event.Label == nil || event.Label.Name != r.TriggerLabel {
+			return command
+		}
+		return requested
+	case ActionRequested:
+		return requested
+	}
+
+	if r.matches(event) {
+		return queue.PublishOptions{Route: queue.RouteReview, Priority: queue.PriorityHigh}
+	}
+	return queue.PublishOptions{Route: queue.RouteReview, Priority: queue.PriorityNormal}
+}
+
+// matches reports whether a pull request targets a priority branch, carries a
+// priority label or is small
+func (r PriorityRules) matches(event *GitHubPullRequestEvent) bool {
+	pr := &event.PullRequest
+	for _, pattern := range r.Branches {
+		if matched, _ := path.Match(pattern, pr.Base.Ref); matched {
+			return true
+		}
+	}
+	for _, label := range pr.Labels {
+		for _, name := range r.Labels {
+			if label.Name == name {
+				return true
+			}
+		}
+	}
+	// Payloads without line counts, e.g. from listing pull requests, report 0
+	changed := pr.Additions + pr.Deletions
+	return r.SmallDiff > 0 && changed > 0 && changed <= r.SmallDiff
+}
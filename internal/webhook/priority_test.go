@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/carlr/codereviewtool/internal/queue"
+)
+
+func TestPriorityRules_Options(t *testing.T) {
+	rules := PriorityRules{
+		Branches:     []string{"release/*"},
+		Labels:       []string{"urgent"},
+		SmallDiff:    20,
+		TriggerLabel: "ai-review",
+	}
+
+	event := func(action string) *GitHubPullRequestEvent {
+		e := &GitHubPullRequestEvent{Action: action}
+		e.PullRequest.Base.Ref = "main"
+		e.PullRequest.Additions = 100
+		return e
+	}
+
+	releaseBranch := event("synchronize")
+	releaseBranch.PullRequest.Base.Ref = "release/1.2"
+	urgent := event("opened")
+	urgent.PullRequest.Labels = []Label{{Name: "urgent"}}
+	small := event("opened")
+	small.PullRequest.Additions, small.PullRequest.Deletions = 5, 3
+	unknownSize := event("opened")
+	unknownSize.PullRequest.Additions = 0
+	trigger := event("labeled")
+	trigger.Label = &Label{Name: "ai-review"}
+	override := event("labeled")
+	override.Label = &Label{Name: "ai-review-override"}
+
+	tests := []struct {
+		name  string
+		event *GitHubPullRequestEvent
+		want  queue.PublishOptions
+	}{
+		{"push", event("synchronize"), queue.PublishOptions{Route: queue.RouteReview, Priority: queue.PriorityNormal}},
+		{"release branch", releaseBranch, queue.PublishOptions{Route: queue.RouteReview, Priority: queue.PriorityHigh}},
+		{"urgent label", urgent, queue.PublishOptions{Route: queue.RouteReview, Priority: queue.PriorityHigh}},
+		{"small diff", small, queue.PublishOptions{Route: queue.RouteReview, Priority: queue.PriorityHigh}},
+		{"unknown size", unknownSize, queue.PublishOptions{Route: queue.RouteReview, Priority: queue.PriorityNormal}},
+		{"trigger label", trigger, queue.PublishOptions{Route: queue.RouteReview, Priority: queue.PriorityHigh}},
+		{"requested", event(ActionRequested), queue.PublishOptions{Route: queue.RouteReview, Priority: queue.PriorityHigh}},
+		{"other label", override, queue.PublishOptions{Route: queue.RouteCommand, Priority: queue.PriorityHigh}},
+		{"command", event(ActionCommand), queue.PublishOptions{Route: queue.RouteCommand, Priority: queue.PriorityHigh}},
+		{"closed", event("closed"), queue.PublishOptions{Route: queue.RouteCommand, Priority: queue.PriorityHigh}},
+	}
+	for _, tt := range tests {
+		if got := rules.Options(tt.event); got != tt.want {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package workspace
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// inheritedEnv are the variables of the worker's environment that commands run on
+// repository code may see; everything else, such as tokens and API keys, is withheld
+var inheritedEnv = []string{"PATH", "TMPDIR", "GOPATH", "GOCACHE", "GOMODCACHE", "GOPROXY", "GOPRIVATE", "GONOSUMDB"}
+
+// Workspace is a temporary checkout of a repository at a single commit
+type Workspace struct {
+	Dir string
+
+	root       string
+	home       string
+	authHeader string
+	caFile     string
+}
+
+// Auth holds what git needs to reach the remote
+type Auth struct {
+	Token  string // Access token; empty for public repositories
+	CAFile string // PEM bundle used to verify the server, for private CAs
+}
+
+// Checkout clones cloneURL into a new temporary directory and checks out sha.
+// The token, if set, is sent as an HTTP header so it is never written to the
+// repository's remote configuration
+func Checkout(ctx context.Context, cloneURL, sha string, auth Auth) (*Workspace, error) {
+	root, err := os.MkdirTemp("", "codereview-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+
+	// HOME is kept outside the checkout so git never reads a .gitconfig from it
+	ws := &Workspace{Dir: filepath.Join(root, "repo"), root: root, home: filepath.Join(root, "home"), caFile: auth.CAFile}
+	for _, dir := range []string{ws.Dir, ws.home} {
+		if err := os.Mkdir(dir, 0o700); err != nil {
+			ws.Close()
+			return nil, fmt.Errorf("failed to create workspace: %w", err)
+		}
+	}
+	if auth.Token != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + auth.Token))
+		ws.authHeader = "Authorization: Basic " + credentials
+	}
+
+	steps := [][]string{
+		{"init", "--quiet"},
+		{"remote", "add", "origin", cloneURL},
+		{"fetch", "--quiet", "--depth", "1", "origin", sha},
+		{"checkout", "--quiet", "--detach", "FETCH_HEAD"},
+	}
+	for _, args := range steps {
+		if _, err := ws.Git(ctx, args...); err != nil {
+			ws.Close()
+			return nil, fmt.Errorf("failed to check out %s: %w", sha, err)
+		}
+	}
+
+	return ws, nil
+}
+
+// Git runs a git command in the workspace, authenticating remote operations
+func (w *Workspace) Git(ctx context.Context, args ...string) (string, error) {
+	if w.authHeader != "" {
+		args = append([]string{"-c", "http.extraHeader=" + w.authHeader}, args...)
+	}
+	if w.caFile != "" {
+		args = append([]string{"-c", "http.sslCAInfo=" + w.caFile}, args...)
+	}
+	stdout, stderr, err := w.Run(ctx, "git", args...)
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", redact(args), err, strings.TrimSpace(string(stderr)))
+	}
+	return string(stdout), nil
+}
+
+// Run executes a command in the workspace directory with the environment from Env
+// and returns its output. A non-zero exit status is reported as an *exec.ExitError
+// alongside the output
+func (w *Workspace) Run(ctx context.Context, name string, args ...string) ([]byte, []byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = w.Dir
+	cmd.Env = Env(w.home)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// Close removes the workspace from disk
+func (w *Workspace) Close() error {
+	return os.RemoveAll(w.root)
+}
+
+// Env returns the environment for commands run on repository code, which may
+// execute it: linters load config and plugins from the checkout and test commands
+// run its tests. Only PATH and Go's settings are inherited, HOME is replaced by
+// home, and Go's caches stay the worker's so builds aren't started from scratch
+func Env(home string) []string {
+	env := []string{"HOME=" + home, "GIT_TERMINAL_PROMPT=0"}
+	for _, name := range inheritedEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+
+	// Go derives unset cache locations from HOME
+	if os.Getenv("GOCACHE") == "" {
+		if dir, err := os.UserCacheDir(); err == nil {
+			env = append(env, "GOCACHE="+filepath.Join(dir, "go-build"))
+		}
+	}
+	if os.Getenv("GOPATH") == "" {
+		if dir, err := os.UserHomeDir(); err == nil {
+			env = append(env, "GOPATH="+filepath.Join(dir, "go"))
+		}
+	}
+	return env
+}
+
+// redact hides credentials from git arguments included in error messages
+func redact(args []string) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "http.extraHeader=") {
+			arg = "http.extraHeader=<redacted>"
+		}
+		parts[i] = arg
+	}
+	return strings.Join(parts, " ")
+}
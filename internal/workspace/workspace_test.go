@@ -0,0 +1,22 @@
+package workspace
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnv_WithholdsSecrets(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "secret")
+	t.Setenv("OPENAI_API_KEY", "secret")
+	t.Setenv("GOPROXY", "https://proxy.example.com")
+
+	env := strings.Join(Env("/tmp/home"), "\n")
+	if strings.Contains(env, "secret") {
+		t.Errorf("Expected secrets to be withheld, got %s", env)
+	}
+	for _, expected := range []string{"HOME=/tmp/home", "GIT_TERMINAL_PROMPT=0", "GOPROXY=https://proxy.example.com", "GOCACHE="} {
+		if !strings.Contains(env, expected) {
+			t.Errorf("Expected environment to contain %s, got %s", expected, env)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+-- Cache of GitHub API responses revalidated with ETags
+CREATE TABLE IF NOT EXISTS github_response_cache (
+    cache_key TEXT PRIMARY KEY,
+    etag TEXT NOT NULL,
+    header JSONB NOT NULL,
+    body BYTEA NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Workers delete entries that weren't written for a week
+CREATE INDEX IF NOT EXISTS idx_github_response_cache_updated_at ON github_response_cache(updated_at);
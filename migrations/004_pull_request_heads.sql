@@ -0,0 +1,6 @@
+-- Newest head SHA pushed to each pull request; jobs for older heads are skipped
+CREATE TABLE IF NOT EXISTS pull_request_heads (
+    pr_key TEXT PRIMARY KEY,
+    head_sha TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,8 @@
+-- Review state per pull request head, so each commit is reviewed once
+CREATE TABLE IF NOT EXISTS review_jobs (
+    pr_key TEXT NOT NULL,
+    head_sha TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL, -- 'running', 'done', 'cancelled'
+    claimed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (pr_key, head_sha)
+);
@@ -0,0 +1,76 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FixRequest asks the model for edits that resolve review comments
+type FixRequest struct {
+	RepositoryName string
+	PullRequestID  int
+	Title          string
+	Files          []SourceFile
+	Issues         []ReviewComment
+}
+
+// SourceFile is the full content of a file at the head of the PR
+type SourceFile struct {
+	Filename string
+	Content  string
+}
+
+// FixResponse holds the edits proposed by the model
+type FixResponse struct {
+	Summary string    `json:"summary"`
+	Edits   []FixEdit `json:"edits"`
+}
+
+// FixEdit replaces a range of lines in one file
+type FixEdit struct {
+	Filename    string `json:"filename"`
+	StartLine   int    `json:"start_line"`
+	EndLine     int    `json:"end_line"`
+	Original    string `json:"original"`
+	Replacement string `json:"replacement"`
+}
+
+// BuildFixPrompt creates a prompt asking for minimal edits that fix the given issues
+func BuildFixPrompt(req FixRequest) string {
+	prompt := fmt.Sprintf(`You are an expert software engineer. A code review of pull request #%d (%s) in %s found the issues below. Produce minimal edits that fix them.
+
+Issues:
+`, req.PullRequestID, req.Title, req.RepositoryName)
+
+	for _, issue := range req.Issues {
+		prompt += fmt.Sprintf("- %s:%d [%s] %s\n", issue.Filename, issue.Line, issue.Category, issue.Body)
+	}
+
+	prompt += "\nFiles (with line numbers):\n"
+	for _, file := range req.Files {
+		prompt += fmt.Sprintf("\n%s:\n```\n", file.Filename)
+		for i, line := range strings.Split(file.Content, "\n") {
+			prompt += fmt.Sprintf("%d: %s\n", i+1, line)
+		}
+		prompt += "```\n"
+	}
+
+	prompt += `
+Format your response as JSON with the following structure:
+{
+  "summary": "One sentence describing the fixes",
+  "edits": [
+    {
+      "filename": "path/to/file.go",
+      "start_line": 42,
+      "end_line": 43,
+      "original": "exact current text of lines 42-43",
+      "replacement": "code that should replace lines 42-43"
+    }
+  ]
+}
+
+Only fix the listed issues and keep the rest of the code unchanged. Line numbers refer to the files above and edits must not overlap. "original" must repeat the replaced lines exactly, without the line number prefixes. Skip any issue you can't fix with confidence; return an empty "edits" list if none can be fixed.`
+
+	return prompt
+}
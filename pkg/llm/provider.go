@@ -22,30 +22,111 @@ type CodeReviewRequest struct {
 	Author         string
 	Title          string
 	Description    string
+	Context        []ContextSnippet
+	APIChanges     []APIChange
+	StaticFindings []StaticFinding
+	SkippedFiles   []SkippedFile
 }
 
 // FileChange represents a changed file in a PR
 type FileChange struct {
+	Filename         string
+	PreviousFilename string // Set when the file was renamed
+	Status           string // "added", "modified", "removed", "renamed"
+	Additions        int
+	Deletions        int
+	Changes          int
+	Patch            string
+}
+
+// SkippedFile is a changed file that couldn't be included in the review
+type SkippedFile struct {
+	Filename string
+	Reason   string
+}
+
+// ContextSnippet is a piece of source code from the head of the PR that helps
+// the reviewer understand a change, such as the function a hunk lives in
+type ContextSnippet struct {
 	Filename  string
-	Status    string // "added", "modified", "removed"
-	Additions int
-	Deletions int
-	Changes   int
-	Patch     string
+	StartLine int
+	EndLine   int
+	Kind      string // "enclosing", "definition", "caller", "file"
+	Symbol    string // Name of the declaration, if known
+	Content   string
+}
+
+// APIChange describes a change to an exported declaration detected by static analysis
+type APIChange struct {
+	Filename string
+	Symbol   string
+	Kind     string // "func", "method", "type", "interface", "struct", "const", "var"
+	Change   string // "added", "removed", "changed"
+	Before   string
+	After    string
+	Breaking bool
+}
+
+// StaticFinding is an issue reported by a static analysis tool on a changed line
+type StaticFinding struct {
+	Tool     string
+	Rule     string
+	Filename string
+	Line     int
+	Message  string
+	Severity string // "info", "warning", "error"
+	Category string // Empty when the tool's rule has no known category
 }
 
 // CodeReviewResponse represents the AI's review feedback
 type CodeReviewResponse struct {
 	Summary  string
 	Comments []ReviewComment
+
+	// APIChanges, ChangedFiles and SkippedFiles are filled in by the analyzer, not by
+	// the model. ChangedFiles includes the old names of renamed files
+	APIChanges   []APIChange   `json:"-"`
+	ChangedFiles []string      `json:"-"`
+	SkippedFiles []SkippedFile `json:"-"`
+	// FixPullRequestURL links the follow-up pull request opened by auto-fix
+	FixPullRequestURL string `json:"-"`
+	// Unparsed is set when the model's response wasn't the expected JSON. The
+	// summary then holds the raw response and the change wasn't really reviewed
+	Unparsed bool `json:"-"`
 }
 
-// ReviewComment represents a single review comment
+// Comment scopes decide where a review comment is posted
+const (
+	ScopeLine    = "line"    // Attached to a line or range in the diff
+	ScopeFile    = "file"    // About a file as a whole, or a line outside the diff
+	ScopeGeneral = "general" // About the pull request as a whole
+)
+
+// ReviewComment represents a single review comment. A comment covers lines
+// StartLine through Line, or just Line when StartLine is zero
 type ReviewComment struct {
-	Filename string
-	Line     int
-	Body     string
-	Severity string // "info", "warning", "error"
+	Scope     string `json:"scope,omitempty"`
+	Filename  string
+	StartLine int `json:"start_line,omitempty"`
+	Line      int
+	Side      string `json:"side,omitempty"` // "RIGHT" for the new version of the file, "LEFT" for the old
+	Body      string
+	Severity  string // "info", "warning", "error"
+	Category  string // "bug", "security", "performance", "style", "maintainability"
+	Source    string // Static analysis tool that reported the issue; empty for model comments
+	// Snippet is the head text of the lines the comment covers, filled in by the analyzer
+	Snippet string `json:"-"`
+
+	// Suggestion is an optional concrete fix the author can apply in one click
+	Suggestion *Suggestion
+}
+
+// Suggestion replaces a range of lines in the head version of a file
+type Suggestion struct {
+	StartLine   int    `json:"start_line"`
+	EndLine     int    `json:"end_line"`
+	Original    string `json:"original"`    // Current text of the lines, used to validate the range
+	Replacement string `json:"replacement"` // New text for the lines; empty deletes them
 }
 
 // BuildPrompt creates a comprehensive prompt for code review
@@ -68,6 +149,63 @@ Changed Files:
 
 	prompt += "\n\nCode Changes:\n```diff\n" + req.Diff + "\n```\n\n"
 
+	if len(req.APIChanges) > 0 {
+		prompt += "Exported API Changes (detected by parsing the old and new Go sources):\n"
+		for _, change := range req.APIChanges {
+			breaking := ""
+			if change.Breaking {
+				breaking = " [BREAKING]"
+			}
+			prompt += fmt.Sprintf("- %s %s %s in %s%s\n", change.Change, change.Kind, change.Symbol, change.Filename, breaking)
+			if change.Before != "" {
+				prompt += "  before: " + change.Before + "\n"
+			}
+			if change.After != "" {
+				prompt += "  after:  " + change.After + "\n"
+			}
+		}
+		prompt += "Consider whether breaking changes are intentional and whether callers are updated.\n\n"
+	}
+
+	if len(req.StaticFindings) > 0 {
+		prompt += "Static Analysis Findings (reported by linters on changed lines):\n"
+		for _, f := range req.StaticFindings {
+			rule := ""
+			if f.Rule != "" {
+				rule = " " + f.Rule
+			}
+			prompt += fmt.Sprintf("- %s:%d [%s%s, %s] %s\n", f.Filename, f.Line, f.Tool, rule, f.Severity, f.Message)
+		}
+		prompt += "These findings are already known and will be reported. Do not rediscover them; instead, comment on the same file and line only to explain a finding that matters, say why, and adjust its severity. Leave out findings that are false positives.\n\n"
+	}
+
+	if len(req.SkippedFiles) > 0 {
+		prompt += "Files Not Shown (these changes could not be included and will be listed as not reviewed):\n"
+		for _, f := range req.SkippedFiles {
+			prompt += fmt.Sprintf("- %s: %s\n", f.Filename, f.Reason)
+		}
+		prompt += "\n"
+	}
+
+	if len(req.Context) > 0 {
+		prompt += "Additional Context (code from the head of the PR, for reference only - do not comment on unchanged lines):\n"
+		for _, snippet := range req.Context {
+			label := "enclosing declaration"
+			if snippet.Kind == "definition" {
+				label = "definition of " + snippet.Symbol
+			} else if snippet.Kind == "caller" {
+				label = "caller of " + snippet.Symbol
+			} else if snippet.Kind == "file" {
+				label = "full contents; the diff is too large to show"
+			} else if snippet.Symbol != "" {
+				label = "enclosing declaration " + snippet.Symbol
+			}
+			prompt += fmt.Sprintf("\n%s lines %d-%d (%s):\n```\n%s\n```\n", snippet.Filename, snippet.StartLine, snippet.EndLine, label, snippet.Content)
+		}
+		// Callers are only searched near the change, so their absence proves nothing
+		prompt += "\nCallers are only searched in the changed files and a few other files of the same Go package, and only some are shown. Code elsewhere may call the changed declarations too, so do not assume the callers above are the only ones.\n\n"
+	}
+
 	prompt += `Please review this code and provide:
 1. A brief summary of the changes
 2. Potential bugs or issues
@@ -81,14 +219,30 @@ Format your response as JSON with the following structure:
   "summary": "Brief overview of the changes",
   "comments": [
     {
+      "scope": "line|file|general",
       "filename": "path/to/file.go",
+      "start_line": 30,
       "line": 42,
-      "body": "Detailed comment about this line",
-      "severity": "info|warning|error"
+      "side": "RIGHT",
+      "body": "Detailed comment about these lines",
+      "severity": "info|warning|error",
+      "category": "bug|security|performance|style|maintainability",
+      "suggestion": {
+        "start_line": 42,
+        "end_line": 43,
+        "original": "exact current text of lines 42-43",
+        "replacement": "code that should replace lines 42-43"
+      }
     }
   ]
 }
 
+Use "scope": "line" for comments about specific changed lines, "file" for comments about a file as a whole (such as a deleted or binary file; omit "line"), and "general" for comments about the pull request as a whole (omit "filename" and "line").
+
+"line" is the line the comment is about, or the last line of a range. Set "start_line" only when the comment is about several lines, such as a whole function; the range must lie within a single changed hunk. Use "side": "LEFT" with old-file line numbers only for comments about removed lines; otherwise use "RIGHT".
+
+Include "suggestion" only when you can give a concrete, complete fix. Line numbers refer to the new version of the file, must lie within a single changed hunk, and "original" must repeat those lines exactly, including indentation. Omit "suggestion" otherwise.
+
 Focus on being constructive and helpful. Only mention issues if they are significant.`
 
 	return prompt
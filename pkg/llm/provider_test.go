@@ -63,6 +63,46 @@ func TestBuildPrompt(t *testing.T) {
 	}
 }
 
+func TestBuildPrompt_WithContext(t *testing.T) {
+	req := CodeReviewRequest{
+		RepositoryName: "user/repo",
+		Diff:           "diff content here",
+		Context: []ContextSnippet{
+			{Filename: "types.go", StartLine: 3, EndLine: 5, Kind: "definition", Symbol: "Request", Content: "type Request struct{}"},
+		},
+	}
+
+	prompt := BuildPrompt(req)
+
+	for _, expected := range []string{"Additional Context", "types.go lines 3-5 (definition of Request)", "type Request struct{}", "do not assume the callers above are the only ones"} {
+		if !contains(prompt, expected) {
+			t.Errorf("Expected prompt to contain '%s'", expected)
+		}
+	}
+}
+
+func TestCodeReviewResponse_Severities(t *testing.T) {
+	review := &CodeReviewResponse{
+		Comments: []ReviewComment{
+			{Severity: "warning"},
+			{Severity: "info"},
+			{Severity: "unknown"},
+			{Severity: "warning"},
+		},
+	}
+
+	counts := review.SeverityCounts()
+	if counts["warning"] != 2 || counts["info"] != 2 || counts["error"] != 0 {
+		t.Errorf("Unexpected severity counts: %v", counts)
+	}
+	if highest := review.HighestSeverity(); highest != "warning" {
+		t.Errorf("Expected highest severity 'warning', got '%s'", highest)
+	}
+	if highest := (&CodeReviewResponse{}).HighestSeverity(); highest != "" {
+		t.Errorf("Expected no severity for an empty review, got '%s'", highest)
+	}
+}
+
 func TestFactory_CreateOpenAIProvider(t *testing.T) {
 	factory := NewFactory()
 	config := map[string]string{
@@ -0,0 +1,44 @@
+package llm
+
+// SeverityRank orders review severities from least to most severe; unknown
+// severities rank as "info"
+func SeverityRank(severity string) int {
+	switch severity {
+	case "error":
+		return 2
+	case "warning":
+		return 1
+	default:
+		return 0
+	}
+}
+
+// SeverityCounts tallies the review's comments by severity
+func (r *CodeReviewResponse) SeverityCounts() map[string]int {
+	counts := map[string]int{"info": 0, "warning": 0, "error": 0}
+	for _, c := range r.Comments {
+		switch c.Severity {
+		case "error", "warning":
+			counts[c.Severity]++
+		default:
+			counts["info"]++
+		}
+	}
+	return counts
+}
+
+// HighestSeverity returns the most severe severity among the review's comments,
+// or an empty string when there are no comments
+func (r *CodeReviewResponse) HighestSeverity() string {
+	highest := ""
+	for _, c := range r.Comments {
+		severity := c.Severity
+		if SeverityRank(severity) == 0 {
+			severity = "info"
+		}
+		if highest == "" || SeverityRank(severity) > SeverityRank(highest) {
+			highest = severity
+		}
+	}
+	return highest
+}